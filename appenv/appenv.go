@@ -0,0 +1,11 @@
+// Package appenv tells which environment the server runs in, as set with APP_ENV
+package appenv
+
+import "os"
+
+// IsDevelopment reports whether APP_ENV is "development" or "test", which opts into the shortcuts
+// meant for local development and tests, such as ephemeral keys and the mock payment provider
+func IsDevelopment() bool {
+	env := os.Getenv("APP_ENV")
+	return env == "development" || env == "test"
+}
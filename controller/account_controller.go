@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/service"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountController handles GDPR requests: data export and account deletion
+type AccountController struct {
+	accountService *service.AccountService
+}
+
+// NewAccountController creates a new AccountController instance
+func NewAccountController(accountService *service.AccountService) *AccountController {
+	return &AccountController{accountService: accountService}
+}
+
+// Export downloads everything stored about the current user
+// @Summary      Export my data
+// @Description  Download a zip archive with the current user's profile, products, transactions, ratings and comments as JSON, plus their images.
+// @Tags         Users
+// @Produce      application/zip
+// @Security     ApiKeyAuth
+// @Success      200  {file}   file                "Zip archive"
+// @Failure      401  {object} map[string]string  "User ID not found"
+// @Failure      500  {object} map[string]string  "Failed to export data"
+// @Router       /users/me/export [get]
+func (controller *AccountController) Export(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	archive, err := controller.accountService.Export(userID.(string))
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Printf("Export data: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data", "details": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("econova-export-%s.zip", time.Now().UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// Delete closes the current user's account
+// @Summary      Delete my account
+// @Description  Close the current user's account. The profile is anonymized and logged out everywhere right away; remaining personal data is purged after a grace period.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        account  body  models.DeleteAccount  true  "Current password"
+// @Success      202      {object} models.AccountDeletion  "Account closed, purge scheduled"
+// @Failure      400      {object} map[string]string       "Invalid input"
+// @Failure      401      {object} map[string]string       "Invalid password"
+// @Failure      409      {object} map[string]string       "Account already deleted"
+// @Router       /users/me [delete]
+func (controller *AccountController) Delete(c *gin.Context) {
+	var deleteData models.DeleteAccount
+	if err := c.ShouldBindJSON(&deleteData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	deletion, err := controller.accountService.Delete(userID.(string), deleteData.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		case errors.Is(err, service.ErrAccountDeleted):
+			c.JSON(http.StatusConflict, gin.H{"error": "Account already deleted"})
+		case errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			log.Printf("Delete account: service error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, deletion)
+}
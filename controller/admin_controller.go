@@ -0,0 +1,354 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/pagination"
+	"backend/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminController handles HTTP requests for user and listing management
+type AdminController struct {
+	userService    *service.UserService
+	sessionService *service.SessionService
+	productService *service.ProductService
+	lockoutService *service.LockoutService
+}
+
+// NewAdminController creates a new AdminController instance
+func NewAdminController(userService *service.UserService, sessionService *service.SessionService, productService *service.ProductService, lockoutService *service.LockoutService) *AdminController {
+	return &AdminController{
+		userService:    userService,
+		sessionService: sessionService,
+		productService: productService,
+		lockoutService: lockoutService,
+	}
+}
+
+// ListUsers lists users for the admin panel
+// @Summary      List users
+// @Description  List users, optionally filtered by a name or email search term. Requires moderator or admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        search         query  string  false  "Name or email search term"
+// @Param        limit          query  int     false  "Number of users per page (default 20, max 100)"
+// @Param        cursor         query  string  false  "next_cursor of the previous page"
+// @Param        include_total  query  bool    false  "Include the total number of matching users"
+// @Success      200     {object} map[string]interface{}
+// @Failure      400     {object} map[string]string  "Invalid pagination parameters"
+// @Failure      403     {object} map[string]string  "Insufficient permissions"
+// @Router       /admin/users [get]
+func (controller *AdminController) ListUsers(c *gin.Context) {
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	users, page, err := controller.userService.ListUsers(c.Query("search"), params)
+	if err != nil {
+		log.Printf("ListUsers: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page.Response("users", users))
+}
+
+// GetUser retrieves a single user for the admin panel
+// @Summary      Get user
+// @Description  Retrieve a user with their full email address and login lockout state. Requires moderator or admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "User ID"
+// @Success      200  {object} models.User
+// @Failure      404  {object} map[string]string  "User not found"
+// @Router       /admin/users/{id} [get]
+func (controller *AdminController) GetUser(c *gin.Context) {
+	user, err := controller.userService.GetByIDForAdmin(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found", "details": err.Error()})
+		return
+	}
+
+	lockout, err := controller.lockoutService.Status(c.Param("id"))
+	if err != nil {
+		log.Printf("GetUser: failed to read lockout state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve lockout state", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user, "lockout": lockout})
+}
+
+// GetUserLockout retrieves the login lockout state of a user
+// @Summary      Get user lockout
+// @Description  Show whether a user is locked out after failed logins, until when, and their recent failure count. Requires moderator or admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "User ID"
+// @Success      200  {object} models.LockoutStatus
+// @Failure      404  {object} map[string]string  "User not found"
+// @Router       /admin/users/{id}/lockout [get]
+func (controller *AdminController) GetUserLockout(c *gin.Context) {
+	lockout, err := controller.lockoutService.Status(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve lockout state", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lockout)
+}
+
+// UnlockUser lifts the login lockout of a user
+// @Summary      Unlock user
+// @Description  Lift a login lockout and clear the user's failed attempts. Requires moderator or admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "User ID"
+// @Success      200  {object} map[string]string  "User unlocked successfully"
+// @Failure      404  {object} map[string]string  "User not found"
+// @Router       /admin/users/{id}/lockout [delete]
+func (controller *AdminController) UnlockUser(c *gin.Context) {
+	if err := controller.lockoutService.Unlock(c.Param("id")); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// ListLoginAttempts lists the login history of a user
+// @Summary      List login attempts
+// @Description  List a user's login and second-factor attempts, newest first. Requires moderator or admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id             path   string  true   "User ID"
+// @Param        limit          query  int     false  "Number of attempts per page (default 20, max 100)"
+// @Param        cursor         query  string  false  "next_cursor of the previous page"
+// @Param        include_total  query  bool    false  "Include the total number of attempts"
+// @Success      200    {object} map[string]interface{}
+// @Failure      400    {object} map[string]string  "Invalid pagination parameters"
+// @Router       /admin/users/{id}/login-attempts [get]
+func (controller *AdminController) ListLoginAttempts(c *gin.Context) {
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	attempts, page, err := controller.lockoutService.ListAttempts(c.Param("id"), params)
+	if err != nil {
+		log.Printf("ListLoginAttempts: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve login attempts", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page.Response("attempts", attempts))
+}
+
+// UpdateUserRole changes the role of a user
+// @Summary      Update user role
+// @Description  Change a user's role (user, workshop, moderator, admin). Making a user a workshop lets them bid on revitalization requests. The user's sessions are revoked. Requires admin role.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path  string             true  "User ID"
+// @Param        role  body  models.UpdateRole  true  "New role"
+// @Success      200   {object} map[string]string  "Role updated successfully"
+// @Failure      400   {object} map[string]string  "Invalid input"
+// @Failure      404   {object} map[string]string  "User not found"
+// @Router       /admin/users/{id}/role [put]
+func (controller *AdminController) UpdateUserRole(c *gin.Context) {
+	var roleData models.UpdateRole
+	if err := c.ShouldBindJSON(&roleData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := controller.userService.UpdateRole(c.Param("id"), roleData.Role); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role", "details": err.Error()})
+		case errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully"})
+}
+
+// RevokeUserSessions logs a user out on all devices
+// @Summary      Revoke user sessions
+// @Description  Revoke every session of a user. Requires moderator or admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "User ID"
+// @Success      200  {object} map[string]string  "Sessions revoked successfully"
+// @Router       /admin/users/{id}/sessions [delete]
+func (controller *AdminController) RevokeUserSessions(c *gin.Context) {
+	if err := controller.sessionService.RevokeAll(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully"})
+}
+
+// ListProducts lists products for the admin panel
+// @Summary      List products
+// @Description  List products newest first, optionally filtered by status. Requires moderator or admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        status         query  string  false  "Product status"
+// @Param        limit          query  int     false  "Number of products per page (default 20, max 100)"
+// @Param        cursor         query  string  false  "next_cursor of the previous page"
+// @Param        include_total  query  bool    false  "Include the total number of matching products"
+// @Success      200     {object} map[string]interface{}
+// @Failure      400     {object} map[string]string  "Invalid pagination parameters"
+// @Router       /admin/products [get]
+func (controller *AdminController) ListProducts(c *gin.Context) {
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	var products []models.Product
+	var page pagination.Page
+	var err error
+	if status := c.Query("status"); status != "" {
+		products, page, err = controller.productService.GetProductsByStatusPaginated(status, params)
+	} else {
+		products, page, err = controller.productService.GetRandomProductsPaginated(params)
+	}
+	if err != nil {
+		log.Printf("ListProducts: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve products", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page.Response("products", products))
+}
+
+// UpdateProductStatus overrides the status of a product
+// @Summary      Update product status
+// @Description  Set the status of a product, bypassing the lifecycle rules; meant for fixing bad data. The override is recorded as an "overridden" transaction in the product's history. It is refused while an open order holds the product. Requires moderator or admin role.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path  string                      true  "Product ID"
+// @Param        status  body  models.UpdateProductStatus  true  "New status and why"
+// @Success      200     {object} map[string]interface{}  "Status updated successfully"
+// @Failure      400     {object} map[string]string  "Invalid input"
+// @Failure      403     {object} map[string]string  "Forbidden"
+// @Failure      404     {object} map[string]string  "Product not found"
+// @Failure      409     {object} map[string]string  "Product changed in the meantime or held by an open order"
+// @Router       /admin/products/{id}/status [put]
+func (controller *AdminController) UpdateProductStatus(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	var statusData models.UpdateProductStatus
+	if err := c.ShouldBindJSON(&statusData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	if !statusData.Status.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status", "details": service.ErrInvalidStatus.Error()})
+		return
+	}
+
+	entry, err := controller.productService.UpdateStatus(actor, productID, statusData.Status, statusData.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			forbidden(c, err)
+		case errors.Is(err, service.ErrProductNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		case errors.Is(err, service.ErrInvalidStatus):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status", "details": err.Error()})
+		case errors.Is(err, service.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Product cannot be overridden now", "details": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Status updated successfully", "transaction": entry})
+}
+
+// DeleteProduct takes a listing down
+// @Summary      Delete product
+// @Description  Take a product listing down. The product is withdrawn rather than deleted, so its orders, payments and history stay intact. Requires moderator or admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "Product ID"
+// @Success      200  {object} map[string]interface{}  "Product withdrawn successfully"
+// @Failure      400  {object} map[string]string  "Invalid product ID"
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Product not found"
+// @Failure      409  {object} map[string]string  "The product is reserved, sold or already withdrawn"
+// @Router       /admin/products/{id} [delete]
+func (controller *AdminController) DeleteProduct(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	entry, err := controller.productService.Withdraw(actor, productID, "Removed by a moderator")
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			forbidden(c, err)
+		case errors.Is(err, service.ErrProductNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		case errors.Is(err, service.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Product cannot be withdrawn in its current status", "details": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product withdrawn successfully", "transaction": entry})
+}
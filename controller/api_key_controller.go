@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// APIKeyController handles HTTP requests for managing API keys
+type APIKeyController struct {
+	apiKeyService *service.APIKeyService
+}
+
+// NewAPIKeyController creates a new APIKeyController instance
+func NewAPIKeyController(apiKeyService *service.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService}
+}
+
+// Create issues an API key for the current user
+// @Summary      Create API key
+// @Description  Create an API key acting on behalf of the current user, limited to the given scopes. The key is only returned once.
+// @Tags         API Keys
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        key  body  models.CreateAPIKey  true  "Key name, scopes and optional lifetime"
+// @Success      201  {object} models.CreatedAPIKey
+// @Failure      400  {object} map[string]string  "Invalid input"
+// @Router       /users/api-keys [post]
+func (controller *APIKeyController) Create(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	controller.create(c, actor, actor.ID)
+}
+
+// CreateForUser issues an API key on behalf of another user
+// @Summary      Create API key for user
+// @Description  Create an API key acting on behalf of the given user, e.g. for a partner integration. Requires admin role. The key is only returned once.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string               true  "User ID"
+// @Param        key  body  models.CreateAPIKey  true  "Key name, scopes and optional lifetime"
+// @Success      201  {object} models.CreatedAPIKey
+// @Failure      400  {object} map[string]string  "Invalid input"
+// @Failure      404  {object} map[string]string  "User not found"
+// @Router       /admin/users/{id}/api-keys [post]
+func (controller *APIKeyController) CreateForUser(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	ownerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	controller.create(c, actor, ownerID)
+}
+
+func (controller *APIKeyController) create(c *gin.Context, actor service.Actor, ownerID uuid.UUID) {
+	var keyData models.CreateAPIKey
+	if err := c.ShouldBindJSON(&keyData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	created, err := controller.apiKeyService.Create(actor, ownerID, &keyData)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			forbidden(c, err)
+		case errors.Is(err, service.ErrInvalidScope):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope", "details": err.Error(), "allowed_scopes": models.AllScopes})
+		case errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			log.Printf("Create API key: service error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// List lists the API keys of the current user
+// @Summary      List API keys
+// @Description  List the current user's API keys, including revoked and expired ones.
+// @Tags         API Keys
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object} map[string]interface{}
+// @Router       /users/api-keys [get]
+func (controller *APIKeyController) List(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	keys, err := controller.apiKeyService.List(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// ListAll lists API keys for the admin panel
+// @Summary      List all API keys
+// @Description  List API keys of all users, optionally filtered by user. Requires moderator or admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        user_id  query  string  false  "Only keys of this user"
+// @Success      200      {object} map[string]interface{}
+// @Router       /admin/api-keys [get]
+func (controller *APIKeyController) ListAll(c *gin.Context) {
+	keys, err := controller.apiKeyService.List(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// Revoke disables an API key
+// @Summary      Revoke API key
+// @Description  Revoke an API key. Users can revoke their own keys; admins can revoke any key.
+// @Tags         API Keys
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "API key ID"
+// @Success      200  {object} map[string]string  "API key revoked successfully"
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "API key not found"
+// @Router       /users/api-keys/{id} [delete]
+func (controller *APIKeyController) Revoke(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := controller.apiKeyService.Revoke(actor, c.Param("id")); err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			forbidden(c, err)
+		case errors.Is(err, service.ErrAPIKeyNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/service"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CategoryController handles HTTP requests for the product taxonomy
+type CategoryController struct {
+	categoryService *service.CategoryService
+}
+
+// NewCategoryController creates a new CategoryController instance
+func NewCategoryController(categoryService *service.CategoryService) *CategoryController {
+	return &CategoryController{categoryService: categoryService}
+}
+
+// Tree lists the taxonomy
+// @Summary      List categories
+// @Description  Get the category tree with the number of listed products in each category. Names are localized by the lang query parameter or the Accept-Language header.
+// @Tags         Categories
+// @Produce      json
+// @Param        lang  query  string  false  "Language code, e.g. en or tr"
+// @Success      200   {array}  models.CategoryNode
+// @Router       /categories [get]
+func (controller *CategoryController) Tree(c *gin.Context) {
+	tree, err := controller.categoryService.Tree(requestLocale(c))
+	if err != nil {
+		log.Printf("Category tree: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// Create adds a category
+// @Summary      Create a category
+// @Description  Add a top-level category, or a subcategory when parent_slug is given. Requires admin role.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        category  body  models.CreateCategory  true  "Category"
+// @Success      201       {object} models.Category
+// @Failure      400       {object} map[string]string  "Invalid category"
+// @Failure      409       {object} map[string]string  "Slug already exists"
+// @Router       /admin/categories [post]
+func (controller *CategoryController) Create(c *gin.Context) {
+	var categoryData models.CreateCategory
+	if err := c.ShouldBindJSON(&categoryData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	category, err := controller.categoryService.Create(&categoryData)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCategory):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category", "details": err.Error()})
+		case errors.Is(err, service.ErrCategoryExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "A category with this slug already exists"})
+		default:
+			log.Printf("Create category: service error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// Delete removes a category
+// @Summary      Delete a category
+// @Description  Remove a category that has no subcategories and no products. Requires admin role.
+// @Tags         Admin
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "Category ID"
+// @Success      200  {object} map[string]string
+// @Failure      404  {object} map[string]string  "Category not found"
+// @Failure      409  {object} map[string]string  "Category still in use"
+// @Router       /admin/categories/{id} [delete]
+func (controller *CategoryController) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID format"})
+		return
+	}
+
+	if err := controller.categoryService.Delete(id); err != nil {
+		switch {
+		case errors.Is(err, service.ErrCategoryNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		case errors.Is(err, service.ErrCategoryInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "Category is still in use", "details": err.Error()})
+		default:
+			log.Printf("Delete category: service error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// Migrate maps free-text product categories onto the taxonomy
+// @Summary      Migrate product categories
+// @Description  Move products whose category or subcategory is free text onto canonical categories. Explicit mappings win; other values are matched against category slugs and names ignoring case and plurals. Values that match nothing are reported as unmapped. Use dry_run to preview. Requires admin role.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        migration  body  models.CategoryMigration  true  "Mappings and dry-run flag"
+// @Success      200        {object} models.CategoryMigrationReport
+// @Failure      400        {object} map[string]string  "Invalid mapping"
+// @Router       /admin/categories/migrate [post]
+func (controller *CategoryController) Migrate(c *gin.Context) {
+	var migration models.CategoryMigration
+	if err := c.ShouldBindJSON(&migration); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	report, err := controller.categoryService.Migrate(&migration)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCategory) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping", "details": err.Error()})
+			return
+		}
+		log.Printf("Migrate categories: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to migrate categories", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// requestLocale returns the language asked for by the lang query parameter, or else the first language
+// of the Accept-Language header
+func requestLocale(c *gin.Context) string {
+	if lang := c.Query("lang"); lang != "" {
+		return lang
+	}
+	first, _, _ := strings.Cut(c.GetHeader("Accept-Language"), ",")
+	locale, _, _ := strings.Cut(first, ";")
+	return strings.TrimSpace(locale)
+}
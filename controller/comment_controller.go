@@ -3,6 +3,7 @@ package controller
 import (
 	"backend/models"
 	"backend/service"
+	"errors"
 	"log"
 	"net/http"
 
@@ -67,6 +68,8 @@ func (controller *CommentController) Create(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        id   path   string  true   "Comment ID"
+// @Failure      403  {object} map[string]string  "Only the author or a moderator may delete a comment"
+// @Failure      404  {object} map[string]string  "Comment not found"
 // @Router       /comments/{id} [delete]
 func (controller *CommentController) Delete(c *gin.Context) {
 	// Extract the comment ID from the URL parameters
@@ -78,55 +81,43 @@ func (controller *CommentController) Delete(c *gin.Context) {
 		return
 	}
 
-	// Get user_id from context
-	userIDStr, exists := c.Get("user_id")
-	if !exists {
-		log.Println("User ID not found in request")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
-		return
-	}
-
-	// Parse user ID to uuid.UUID
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		log.Printf("Invalid user UUID: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user UUID format"})
-		return
-	}
-
-	// Retrieve the comment from the service
-	comment, err := controller.commentService.GetByID(id)
+	actor, err := actorFromContext(c)
 	if err != nil {
-		log.Printf("Error retrieving comment: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comment", "details": err.Error()})
-		return
-	}
-
-	// Check if the user is the author of the comment
-	if comment.UserID != userID {
-		log.Println("Unauthorized attempt to delete comment")
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to delete this comment"})
+		log.Println("User ID not found in request")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required", "details": err.Error()})
 		return
 	}
 
-	// Proceed to delete the comment
-	if err := controller.commentService.Delete(id); err != nil {
-		log.Printf("Error deleting comment: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment", "details": err.Error()})
+	// Delete the comment; the service checks that the user is the author or a moderator
+	if err := controller.commentService.Delete(id, actor); err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			log.Println("Unauthorized attempt to delete comment")
+			forbidden(c, err)
+		case errors.Is(err, service.ErrCommentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		default:
+			log.Printf("Error deleting comment: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment", "details": err.Error()})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
 }
 
-// GetByProductID retrieves all comments for a specific product, with user demographic information
+// GetByProductID retrieves the comments on a specific product, with user demographic information
 // @Summary      Get comments by product ID
-// @Description  Retrieves all comments for a specific product, with user demographic information
+// @Description  Retrieves a page of the comments on a specific product, newest first, with user demographic information
 // @Tags         Comments
 // @Accept       json
 // @Produce      json
-// @Param        product_id   path    string  true   "Product ID"
-// @Success      200          {array} models.CommentResponse
+// @Param        product_id     path   string  true   "Product ID"
+// @Param        limit          query  int     false  "Number of comments per page (default 20, max 100)"
+// @Param        cursor         query  string  false  "next_cursor of the previous page"
+// @Param        include_total  query  bool    false  "Include the total number of comments"
+// @Success      200          {object} map[string]interface{}
+// @Failure      400          {object} map[string]string  "Invalid pagination parameters"
 // @Router       /comments/product/{product_id} [get]
 func (controller *CommentController) GetByProductID(c *gin.Context) {
 	productIDParam := c.Param("product_id")
@@ -136,9 +127,13 @@ func (controller *CommentController) GetByProductID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid UUID format"})
 		return
 	}
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
 
 	// Retrieve basic comments without User details from the service
-	comments, err := controller.commentService.GetByProductID(productID)
+	comments, page, err := controller.commentService.GetByProductID(productID, params)
 	if err != nil {
 		log.Printf("Error retrieving comments: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments", "details": err.Error()})
@@ -146,11 +141,16 @@ func (controller *CommentController) GetByProductID(c *gin.Context) {
 	}
 
 	// Create a slice to hold comments with full user details
-	var commentsWithUserDetails []models.CommentResponse
+	commentsWithUserDetails := []models.CommentResponse{}
 
 	for _, comment := range comments {
 		// Fetch demographic information for each user associated with a comment
-		user, err := controller.userService.GetDemographicInformation(comment.UserID.String())
+		user, err := controller.userService.GetDemographicInformation(comment.UserID.String(), viewerFromContext(c))
+		if errors.Is(err, service.ErrUserNotFound) {
+			// The author closed their account
+			deleted := models.DeletedUser(comment.UserID)
+			user, err = &deleted, nil
+		}
 		if err != nil {
 			log.Printf("Error fetching user demographic information: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user information", "details": err.Error()})
@@ -170,6 +170,6 @@ func (controller *CommentController) GetByProductID(c *gin.Context) {
 		commentsWithUserDetails = append(commentsWithUserDetails, commentResponse)
 	}
 
-	// Return the list of comments with user demographic information
-	c.JSON(http.StatusOK, gin.H{"comments": commentsWithUserDetails})
+	// Return the page of comments with user demographic information
+	c.JSON(http.StatusOK, page.Response("comments", commentsWithUserDetails))
 }
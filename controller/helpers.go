@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/pagination"
+	"backend/service"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// actorFromContext builds the policy actor from the user ID and role JWTAuth stored in the context
+func actorFromContext(c *gin.Context) (service.Actor, error) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return service.Actor{}, errors.New("user ID not found in context")
+	}
+
+	id, err := uuid.Parse(userID.(string))
+	if err != nil {
+		return service.Actor{}, errors.New("invalid user ID format")
+	}
+
+	role, _ := c.Get("role")
+	actorRole, ok := role.(models.Role)
+	if !ok {
+		actorRole = models.RoleUser
+	}
+
+	return service.Actor{ID: id, Role: actorRole}, nil
+}
+
+// viewerFromContext returns who is making the request on a route with optional authentication,
+// or nil for a visitor who is not logged in
+func viewerFromContext(c *gin.Context) *service.Actor {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		return nil
+	}
+	return &actor
+}
+
+// requestToken returns the single-use token of a request: the one from the body, or the deprecated
+// ?token= query parameter that older clients and emailed links still send. It answers 400 and reports
+// false when neither is given.
+func requestToken(c *gin.Context, bodyToken string) (string, bool) {
+	token := bodyToken
+	if token == "" {
+		token = c.Query("token")
+	}
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": "token is required"})
+		return "", false
+	}
+	return token, true
+}
+
+// forbidden writes the uniform response for a request denied by a policy
+func forbidden(c *gin.Context, err error) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden", "details": err.Error()})
+}
+
+// pageParams reads the pagination query parameters of a list endpoint. It answers 400 and reports
+// false when they are invalid.
+func pageParams(c *gin.Context) (pagination.Params, bool) {
+	params, err := pagination.FromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pagination parameters", "details": err.Error()})
+		return params, false
+	}
+	return params, true
+}
+
+// timeQuery parses an optional time query parameter given as RFC 3339 or as a date, in UTC. Dates are
+// read as UTC days, and a date used as upper bound covers the whole day. Both bounds are inclusive.
+func timeQuery(c *gin.Context, name string, endOfDay bool) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, raw); err != nil {
+			return nil, fmt.Errorf("expected RFC 3339 time or YYYY-MM-DD date, got %q", raw)
+		}
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// respondLogin writes the response of a successful first login step: either the session tokens or,
+// for users with two-factor authentication, the "mfa" token to finish the login with
+func respondLogin(c *gin.Context, result *models.LoginResult, user *models.User) {
+	if result.MFARequired {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    result.MFAToken,
+			"expires_at":   result.MFAExpiresAt,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":              result.Tokens.AccessToken,
+		"expires_at":         result.Tokens.ExpiresAt,
+		"refresh_token":      result.Tokens.RefreshToken,
+		"refresh_expires_at": result.Tokens.RefreshExpiresAt,
+		"user":               user,
+	})
+}
+
+// respondThrottled writes a 429 for a request rejected by the brute-force protection, with a
+// Retry-After header when the wait is known. It reports whether err was such a rejection.
+func respondThrottled(c *gin.Context, err error) bool {
+	if !errors.Is(err, service.ErrTooManyAttempts) {
+		return false
+	}
+
+	var throttled *service.ThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts", "details": err.Error()})
+	return true
+}
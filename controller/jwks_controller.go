@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"backend/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSController publishes the public keys our tokens and provenance certificates are signed with
+type JWKSController struct {
+	keys           *token.KeySet
+	provenanceKeys *token.KeySet
+}
+
+// NewJWKSController creates a new JWKSController instance
+func NewJWKSController(keys, provenanceKeys *token.KeySet) *JWKSController {
+	return &JWKSController{keys: keys, provenanceKeys: provenanceKeys}
+}
+
+// Keys serves the JSON Web Key Set
+// @Summary      JSON Web Key Set
+// @Description  Public keys for verifying the JWTs issued by this API, including provenance certificates. Match a token's "kid" header against the key IDs; retired keys stay listed until their tokens or certificates have expired.
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object} token.JWKS
+// @Router       /.well-known/jwks.json [get]
+func (controller *JWKSController) Keys(c *gin.Context) {
+	// Verifiers may cache the set for a while, but must pick up rotated keys reasonably quickly
+	c.Header("Cache-Control", "public, max-age=300")
+	set := controller.keys.JWKS()
+	set.Keys = append(set.Keys, controller.provenanceKeys.JWKS().Keys...)
+	c.JSON(http.StatusOK, set)
+}
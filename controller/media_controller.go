@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// MediaController handles HTTP requests for product galleries
+type MediaController struct {
+	mediaService *service.MediaService
+}
+
+// NewMediaController creates a new MediaController instance
+func NewMediaController(mediaService *service.MediaService) *MediaController {
+	return &MediaController{mediaService: mediaService}
+}
+
+// List returns a product's gallery
+// @Summary      Get a product's gallery
+// @Description  Get the images of a product and its transactions in display order. When no cover was chosen, the first image is the cover.
+// @Tags         Products
+// @Produce      json
+// @Param        id   path  string  true  "Product ID"
+// @Success      200  {array}  models.Media
+// @Router       /products/{id}/media [get]
+func (controller *MediaController) List(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	gallery, err := controller.mediaService.Gallery(productID)
+	if err != nil {
+		log.Printf("List media: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve gallery"})
+		return
+	}
+	c.JSON(http.StatusOK, gallery)
+}
+
+// Add uploads images to a product's gallery
+// @Summary      Add images to a product
+// @Description  Upload Base64-encoded images with optional captions to the end of a product's gallery, optionally attached to one of its transactions. Only the owner or a moderator may do this.
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id     path  string           true  "Product ID"
+// @Param        media  body  models.AddMedia  true  "Images"
+// @Success      201    {array}  models.Media
+// @Failure      400    {object} map[string]string  "Invalid image"
+// @Failure      403    {object} map[string]string  "Not the owner or a moderator"
+// @Failure      404    {object} map[string]string  "Product not found"
+// @Router       /products/{id}/media [post]
+func (controller *MediaController) Add(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	var mediaData models.AddMedia
+	if err := c.ShouldBindJSON(&mediaData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	media, err := controller.mediaService.Add(actor, productID, &mediaData)
+	if err != nil {
+		respondMediaError(c, err, "Failed to add images")
+		return
+	}
+	c.JSON(http.StatusCreated, media)
+}
+
+// Update changes a gallery image
+// @Summary      Update a gallery image
+// @Description  Change the caption of a gallery image or make it the product's cover. Only the owner or a moderator may do this.
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id        path  string              true  "Product ID"
+// @Param        media_id  path  string              true  "Image ID"
+// @Param        media     body  models.UpdateMedia  true  "Changes"
+// @Success      200       {object} models.Media
+// @Failure      403       {object} map[string]string  "Not the owner, the uploader of a transaction's image or a moderator"
+// @Failure      404       {object} map[string]string  "Image not found"
+// @Router       /products/{id}/media/{media_id} [patch]
+func (controller *MediaController) Update(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+	mediaID, err := uuid.Parse(c.Param("media_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID format"})
+		return
+	}
+
+	var mediaData models.UpdateMedia
+	if err := c.ShouldBindJSON(&mediaData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	media, err := controller.mediaService.Update(actor, productID, mediaID, &mediaData)
+	if err != nil {
+		respondMediaError(c, err, "Failed to update image")
+		return
+	}
+	c.JSON(http.StatusOK, media)
+}
+
+// Reorder changes the order of a product's gallery
+// @Summary      Reorder a product's gallery
+// @Description  Put a product's images in a new order. Every image of the gallery must be listed exactly once. Only the owner or a moderator may do this.
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id     path  string               true  "Product ID"
+// @Param        order  body  models.ReorderMedia  true  "Image IDs in display order"
+// @Success      200    {array}  models.Media
+// @Failure      400    {object} map[string]string  "Order does not match the gallery"
+// @Failure      403    {object} map[string]string  "Not the owner or a moderator"
+// @Router       /products/{id}/media/order [put]
+func (controller *MediaController) Reorder(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	var orderData models.ReorderMedia
+	if err := c.ShouldBindJSON(&orderData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	gallery, err := controller.mediaService.Reorder(actor, productID, orderData.MediaIDs)
+	if err != nil {
+		respondMediaError(c, err, "Failed to reorder gallery")
+		return
+	}
+	c.JSON(http.StatusOK, gallery)
+}
+
+// Delete removes a gallery image
+// @Summary      Delete a gallery image
+// @Description  Remove an image from a product's gallery. Only the owner or a moderator may do this; images of a transaction only whoever uploaded them or a moderator.
+// @Tags         Products
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id        path  string  true  "Product ID"
+// @Param        media_id  path  string  true  "Image ID"
+// @Success      200       {object} map[string]string
+// @Failure      403       {object} map[string]string  "Not the owner, the uploader of a transaction's image or a moderator"
+// @Failure      404       {object} map[string]string  "Image not found"
+// @Router       /products/{id}/media/{media_id} [delete]
+func (controller *MediaController) Delete(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+	mediaID, err := uuid.Parse(c.Param("media_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID format"})
+		return
+	}
+
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := controller.mediaService.Delete(actor, productID, mediaID); err != nil {
+		respondMediaError(c, err, "Failed to delete image")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
+
+// respondMediaError maps the errors of changing a gallery to a response
+func respondMediaError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		forbidden(c, err)
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrMediaNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+	case errors.Is(err, service.ErrInvalidMedia):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image", "details": err.Error()})
+	case errors.Is(err, service.ErrProductNotEditable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Product can no longer be changed", "details": err.Error()})
+	default:
+		log.Printf("%s: %v", message, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAController handles HTTP requests for two-factor authentication
+type MFAController struct {
+	userService *service.UserService
+	mfaService  *service.MFAService
+}
+
+// NewMFAController creates a new MFAController instance
+func NewMFAController(userService *service.UserService, mfaService *service.MFAService) *MFAController {
+	return &MFAController{userService: userService, mfaService: mfaService}
+}
+
+// LoginMFA completes a login for a user with two-factor authentication
+// @Summary      Complete Login With Second Factor
+// @Description  Exchange the mfa_token from /users/login and a TOTP code (or an unused recovery code) for the session tokens.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        login  body  models.MFALogin  true  "MFA token and code"
+// @Success      200    {object} map[string]interface{}  "JWT token and refresh token"
+// @Failure      400    {object} map[string]string       "Invalid input"
+// @Failure      401    {object} map[string]string       "Invalid token or code"
+// @Failure      429    {object} map[string]string       "Too many attempts"
+// @Router       /users/login/mfa [post]
+func (controller *MFAController) LoginMFA(c *gin.Context) {
+	var loginData models.MFALogin
+	if err := c.ShouldBindJSON(&loginData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	if loginData.Code == "" && loginData.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": "code or recovery_code is required"})
+		return
+	}
+
+	tokens, user, err := controller.userService.CompleteMFALogin(loginData.MFAToken, loginData.Code, loginData.RecoveryCode, clientInfo(c))
+	if err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials", "details": err.Error()})
+		return
+	}
+
+	respondLogin(c, &models.LoginResult{Tokens: tokens}, user)
+}
+
+// Enroll starts setting up two-factor authentication
+// @Summary      Enroll In Two-Factor Authentication
+// @Description  Generate a new TOTP secret and its otpauth:// URI for an authenticator app. 2FA is enabled once a code is confirmed with /users/mfa/verify.
+// @Tags         Users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object} models.MFAEnrollment
+// @Failure      409  {object} map[string]string  "Already enabled"
+// @Router       /users/mfa/enroll [post]
+func (controller *MFAController) Enroll(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	enrollment, err := controller.mfaService.Enroll(userID.(string))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMFAAlreadyEnabled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		case errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			log.Printf("MFA enroll: service error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// Confirm enables two-factor authentication with a first code from the authenticator app
+// @Summary      Confirm Two-Factor Authentication
+// @Description  Verify a TOTP code for the pending secret and enable 2FA. Returns the recovery codes; they are only shown once.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        code  body  models.MFACode  true  "TOTP code"
+// @Success      200   {object} map[string]interface{}  "Recovery codes"
+// @Failure      400   {object} map[string]string       "Invalid code"
+// @Failure      409   {object} map[string]string       "Already enabled"
+// @Router       /users/mfa/verify [post]
+func (controller *MFAController) Confirm(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var codeData models.MFACode
+	if err := c.ShouldBindJSON(&codeData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	recoveryCodes, err := controller.mfaService.Confirm(userID.(string), codeData.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMFACode), errors.Is(err, service.ErrMFANotEnrolled):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code", "details": err.Error()})
+		case errors.Is(err, service.ErrMFAAlreadyEnabled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		default:
+			log.Printf("MFA confirm: service error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": recoveryCodes})
+}
+
+// Disable switches off two-factor authentication
+// @Summary      Disable Two-Factor Authentication
+// @Description  Disable 2FA after checking a current TOTP code or an unused recovery code. Remaining recovery codes are deleted.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        code  body  models.MFADisable  true  "TOTP code or recovery code"
+// @Success      200   {object} map[string]string  "Two-factor authentication disabled"
+// @Failure      400   {object} map[string]string  "Invalid code"
+// @Router       /users/mfa/disable [post]
+func (controller *MFAController) Disable(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	var codeData models.MFADisable
+	if err := c.ShouldBindJSON(&codeData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := controller.mfaService.Disable(userID.(string), codeData.Code, codeData.RecoveryCode); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMFACode), errors.Is(err, service.ErrMFANotEnabled):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code", "details": err.Error()})
+		default:
+			log.Printf("MFA disable: service error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
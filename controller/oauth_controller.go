@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthController handles HTTP requests for logging in with external providers
+type OAuthController struct {
+	socialLoginService *service.SocialLoginService
+}
+
+// NewOAuthController creates a new OAuthController instance
+func NewOAuthController(socialLoginService *service.SocialLoginService) *OAuthController {
+	return &OAuthController{socialLoginService: socialLoginService}
+}
+
+// Providers lists the configured login providers
+// @Summary      List login providers
+// @Description  List the external OAuth2/OpenID Connect providers users can log in with.
+// @Tags         Users
+// @Produce      json
+// @Success      200  {object} map[string][]string
+// @Router       /users/oauth/providers [get]
+func (controller *OAuthController) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": controller.socialLoginService.Providers()})
+}
+
+// Start begins a login with an external provider
+// @Summary      Start social login
+// @Description  Start an authorization code + PKCE login with the provider. Send the user to the returned URL; the provider redirects back with a code and state.
+// @Tags         Users
+// @Produce      json
+// @Param        provider  path  string  true  "Provider name, e.g. google"
+// @Success      200  {object} map[string]string  "Authorization URL"
+// @Failure      404  {object} map[string]string  "Unknown provider"
+// @Router       /users/oauth/{provider}/start [get]
+func (controller *OAuthController) Start(c *gin.Context) {
+	authURL, err := controller.socialLoginService.Start(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown provider"})
+			return
+		}
+		log.Printf("OAuth start: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// Callback completes a login with an external provider
+// @Summary      Complete social login
+// @Description  Redeem the code and state the provider redirected back with. Returns the same tokens as a password login.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        provider  path  string                true  "Provider name, e.g. google"
+// @Param        callback  body  models.OAuthCallback  true  "Code and state from the provider redirect"
+// @Success      200  {object} map[string]interface{}  "JWT token and refresh token"
+// @Failure      400  {object} map[string]string       "Invalid input"
+// @Failure      401  {object} map[string]string       "Login failed"
+// @Router       /users/oauth/{provider}/callback [post]
+func (controller *OAuthController) Callback(c *gin.Context) {
+	var callback models.OAuthCallback
+	if err := c.ShouldBindJSON(&callback); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	result, user, err := controller.socialLoginService.Callback(c.Request.Context(), c.Param("provider"), callback.Code, callback.State, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown provider"})
+			return
+		}
+		log.Printf("OAuth callback: login failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed", "details": err.Error()})
+		return
+	}
+
+	respondLogin(c, result, user)
+}
@@ -0,0 +1,340 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// OrderController handles HTTP requests for buyers' orders
+type OrderController struct {
+	orderService *service.OrderService
+}
+
+// NewOrderController creates a new OrderController instance
+func NewOrderController(orderService *service.OrderService) *OrderController {
+	return &OrderController{orderService: orderService}
+}
+
+// Place orders a product for the current user
+// @Summary      Place order
+// @Description  Order an available product. The product is reserved for the buyer until the seller accepts or declines, or the reservation runs out.
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        order  body  models.PlaceOrder  true  "Product to order and an optional note to the seller"
+// @Success      201  {object} models.Order
+// @Failure      400  {object} map[string]string  "Invalid input"
+// @Failure      404  {object} map[string]string  "Product not found"
+// @Failure      409  {object} map[string]string  "Product is not available or a workshop holds a revitalization job on it"
+// @Failure      503  {object} map[string]string  "Payments are disabled"
+// @Router       /orders [post]
+func (controller *OrderController) Place(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.PlaceOrder
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	order, err := controller.orderService.Place(actor, &req)
+	if err != nil {
+		respondOrderError(c, "place order", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// List lists the current user's order history
+// @Summary      List orders
+// @Description  List the orders the current user placed or received, newest first.
+// @Tags         Orders
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        role           query  string  false  "Only orders in which the user is the buyer or the seller"  Enums(buyer, seller)
+// @Param        status         query  string  false  "Only orders in this status"
+// @Param        limit          query  int     false  "Page size (default 20, max 100)"
+// @Param        cursor         query  string  false  "Cursor from the previous page's next_cursor"
+// @Param        include_total  query  bool    false  "Also return the total number of orders"
+// @Success      200  {object} map[string]interface{}
+// @Failure      400  {object} map[string]string  "Invalid role, status or pagination parameters"
+// @Router       /orders [get]
+func (controller *OrderController) List(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	orders, page, err := controller.orderService.List(actor, c.Query("role"), models.OrderStatus(c.Query("status")), params)
+	if err != nil {
+		respondOrderError(c, "list orders", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page.Response("orders", orders))
+}
+
+// Get retrieves an order
+// @Summary      Get order
+// @Description  Get an order. Only its buyer, its seller or a moderator can see it.
+// @Tags         Orders
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "Order ID"
+// @Success      200  {object} models.Order
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Order not found"
+// @Router       /orders/{id} [get]
+func (controller *OrderController) Get(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID format"})
+		return
+	}
+
+	order, err := controller.orderService.Get(actor, id)
+	if err != nil {
+		respondOrderError(c, "get order", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// Payment retrieves the payment of an order
+// @Summary      Get order payment
+// @Description  Get the payment of an order with the ledger entries of every movement of its money. Amounts are in minor units, e.g. cents. Only the buyer, the seller or a moderator can see it.
+// @Tags         Orders
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "Order ID"
+// @Success      200  {object} map[string]interface{}
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Order or payment not found"
+// @Router       /orders/{id}/payment [get]
+func (controller *OrderController) Payment(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID format"})
+		return
+	}
+
+	payment, entries, err := controller.orderService.Payment(actor, id)
+	if err != nil {
+		respondOrderError(c, "get payment", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment": payment, "ledger": entries})
+}
+
+// Accept accepts an order
+// @Summary      Accept order
+// @Description  The seller agrees to sell. The reservation is renewed so the buyer has time to pay.
+// @Tags         Orders
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "Order ID"
+// @Success      200  {object} models.Order
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Order not found"
+// @Failure      409  {object} map[string]string  "The order is not pending"
+// @Router       /orders/{id}/accept [post]
+func (controller *OrderController) Accept(c *gin.Context) {
+	controller.step(c, models.OrderActionAccept)
+}
+
+// Decline declines an order
+// @Summary      Decline order
+// @Description  The seller refuses the order and the product is available again.
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path  string            true   "Order ID"
+// @Param        step  body  models.OrderStep  false  "Why the order is declined"
+// @Success      200  {object} models.Order
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Order not found"
+// @Failure      409  {object} map[string]string  "The order is not pending"
+// @Router       /orders/{id}/decline [post]
+func (controller *OrderController) Decline(c *gin.Context) {
+	controller.step(c, models.OrderActionDecline)
+}
+
+// Pay pays for an order
+// @Summary      Pay order
+// @Description  The buyer pays for an accepted order. The money is held in escrow until the buyer confirms receipt; paid orders no longer expire.
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path  string           true  "Order ID"
+// @Param        payment  body  models.PayOrder  true  "Payment source from the payment provider"
+// @Success      200  {object} models.Order
+// @Failure      400  {object} map[string]string  "Invalid input"
+// @Failure      402  {object} map[string]string  "Payment declined"
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Order not found"
+// @Failure      409  {object} map[string]string  "The order is not accepted or has expired"
+// @Failure      503  {object} map[string]string  "Payments are disabled"
+// @Router       /orders/{id}/pay [post]
+func (controller *OrderController) Pay(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID format"})
+		return
+	}
+
+	var req models.PayOrder
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	order, err := controller.orderService.Pay(c.Request.Context(), actor, id, req.Source)
+	if err != nil {
+		respondOrderError(c, "pay order", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// HandOver marks an order as handed over
+// @Summary      Hand over order
+// @Description  The seller hands the product of a paid order over to the buyer.
+// @Tags         Orders
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "Order ID"
+// @Success      200  {object} models.Order
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Order not found"
+// @Failure      409  {object} map[string]string  "The order is not paid"
+// @Router       /orders/{id}/hand-over [post]
+func (controller *OrderController) HandOver(c *gin.Context) {
+	controller.step(c, models.OrderActionHandOver)
+}
+
+// Complete completes an order
+// @Summary      Complete order
+// @Description  The buyer confirms receipt. The product is recorded as sold, the buyer becomes its owner and the payment is released to the seller minus the platform fee.
+// @Tags         Orders
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "Order ID"
+// @Success      200  {object} models.Order
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Order not found"
+// @Failure      409  {object} map[string]string  "The order was not handed over"
+// @Router       /orders/{id}/complete [post]
+func (controller *OrderController) Complete(c *gin.Context) {
+	controller.step(c, models.OrderActionComplete)
+}
+
+// Cancel cancels an order
+// @Summary      Cancel order
+// @Description  Call an order off and make the product available again. Buyer and seller can cancel until the order is paid; after that only a moderator can, and the buyer is refunded.
+// @Tags         Orders
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path  string            true   "Order ID"
+// @Param        step  body  models.OrderStep  false  "Why the order is cancelled"
+// @Success      200  {object} models.Order
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Order not found"
+// @Failure      409  {object} map[string]string  "The order is already closed"
+// @Failure      503  {object} map[string]string  "Payments are disabled, so a paid order cannot be refunded"
+// @Router       /orders/{id}/cancel [post]
+func (controller *OrderController) Cancel(c *gin.Context) {
+	controller.step(c, models.OrderActionCancel)
+}
+
+// step takes an action on the order in the path, with the optional reason from the request body
+func (controller *OrderController) step(c *gin.Context, action models.OrderAction) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID format"})
+		return
+	}
+
+	var req models.OrderStep
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			return
+		}
+	}
+
+	order, err := controller.orderService.Step(c.Request.Context(), actor, id, action, req.Reason)
+	if err != nil {
+		respondOrderError(c, string(action)+" order", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// respondOrderError writes the response for an error the order service returned while trying to do op
+func respondOrderError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		forbidden(c, err)
+	case errors.Is(err, service.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+	case errors.Is(err, service.ErrPaymentDeclined):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Payment declined", "details": err.Error()})
+	case errors.Is(err, service.ErrPaymentsDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payments are disabled"})
+	case errors.Is(err, service.ErrInvalidOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order", "details": err.Error()})
+	case errors.Is(err, service.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Invalid status transition", "details": err.Error()})
+	default:
+		log.Printf("Failed to %s: service error: %v", op, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + op, "details": err.Error()})
+	}
+}
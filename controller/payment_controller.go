@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"backend/service"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookSize limits the body of a payment provider webhook
+const maxWebhookSize = 1 << 20
+
+// PaymentController handles HTTP requests for payments
+type PaymentController struct {
+	paymentService *service.PaymentService
+}
+
+// NewPaymentController creates a new PaymentController instance
+func NewPaymentController(paymentService *service.PaymentService) *PaymentController {
+	return &PaymentController{paymentService: paymentService}
+}
+
+// Balance returns what the platform owes the current user from their sales
+// @Summary      Get seller balance
+// @Description  Get the total the platform owes the current user from completed sales, after the platform fee. The amount is in minor units, e.g. cents.
+// @Tags         Payments
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object} map[string]interface{}
+// @Router       /payments/balance [get]
+func (controller *PaymentController) Balance(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	balance, currency, err := controller.paymentService.Balance(actor.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve balance", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"balance": balance, "currency": currency})
+}
+
+// Webhook receives notifications from the payment provider
+// @Summary      Payment provider webhook
+// @Description  Called by the payment provider when a payment changes on its side, e.g. a refund made in its dashboard. The request must carry the provider's signature in X-Payment-Signature.
+// @Tags         Payments
+// @Accept       json
+// @Produce      json
+// @Param        X-Payment-Signature  header  string  true  "Signature of the request body"
+// @Success      200  {object} map[string]string  "Webhook processed"
+// @Failure      400  {object} map[string]string  "Invalid signature or payload"
+// @Failure      503  {object} map[string]string  "Payments are disabled"
+// @Router       /payments/webhook [post]
+func (controller *PaymentController) Webhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload", "details": err.Error()})
+		return
+	}
+
+	if err := controller.paymentService.HandleWebhook(payload, c.GetHeader("X-Payment-Signature")); err != nil {
+		if errors.Is(err, service.ErrInvalidWebhook) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook", "details": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrPaymentsDisabled) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payments are disabled"})
+			return
+		}
+		log.Printf("Payment webhook: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed"})
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyController handles HTTP requests for the current user's privacy settings
+type PrivacyController struct {
+	privacyService *service.PrivacyService
+}
+
+// NewPrivacyController creates a new PrivacyController instance
+func NewPrivacyController(privacyService *service.PrivacyService) *PrivacyController {
+	return &PrivacyController{privacyService: privacyService}
+}
+
+// Get returns the current user's privacy settings
+// @Summary      Get privacy settings
+// @Description  Get who may see the current user's profile, and whether their email and rating activity are hidden.
+// @Tags         Users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object} models.UserPrivacy
+// @Failure      401  {object} map[string]string  "User ID not found"
+// @Router       /users/me/privacy [get]
+func (controller *PrivacyController) Get(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	privacy, err := controller.privacyService.Get(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve privacy settings", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, privacy)
+}
+
+// Update changes the current user's privacy settings
+// @Summary      Update privacy settings
+// @Description  Change who may see the current user's profile (public, members or private) and whether their email and rating activity are hidden. Omitted fields are left unchanged.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        privacy  body  models.UpdatePrivacy  true  "Settings to change"
+// @Success      200      {object} models.UserPrivacy
+// @Failure      400      {object} map[string]string  "Invalid input"
+// @Router       /users/me/privacy [put]
+func (controller *PrivacyController) Update(c *gin.Context) {
+	var privacyData models.UpdatePrivacy
+	if err := c.ShouldBindJSON(&privacyData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	privacy, err := controller.privacyService.Update(userID.(string), &privacyData)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVisibility) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visibility", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update privacy settings", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, privacy)
+}
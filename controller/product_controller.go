@@ -3,6 +3,7 @@ package controller
 import (
 	"backend/models"
 	"backend/service"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -17,25 +18,28 @@ type ProductController struct {
 	TransactionService *service.TransactionService
 	UserService        *service.UserService
 	RatingService      *service.RatingService
+	privacyService     *service.PrivacyService
 }
 
 // NewProductController creates a new ProductController instance
-func NewProductController(productService *service.ProductService, transactionService *service.TransactionService, userService *service.UserService, ratingService *service.RatingService) *ProductController {
+func NewProductController(productService *service.ProductService, transactionService *service.TransactionService, userService *service.UserService, ratingService *service.RatingService, privacyService *service.PrivacyService) *ProductController {
 	return &ProductController{
 		productService:     productService,
 		TransactionService: transactionService,
 		UserService:        userService,
 		RatingService:      ratingService,
+		privacyService:     privacyService,
 	}
 }
 
 // @Summary      Create a new product with image
-// @Description  Create a new product with the given details, including Base64-encoded image data
+// @Description  Create a new product with the given details, including Base64-encoded image data. Category and subcategory are slugs from GET /categories.
 // @Tags         Products
 // @Accept       json
 // @Produce      json
 // @Param        product  body      models.ProductRequest  true  "Product data"
 // @Success      201      {object}  models.ProductResponse
+// @Failure      400      {object}  map[string]string  "Invalid input or category"
 // @Router       /products [post]
 func (controller *ProductController) Create(c *gin.Context) {
 	var product models.ProductRequest
@@ -57,24 +61,26 @@ func (controller *ProductController) Create(c *gin.Context) {
 		return
 	}
 
-	createdProduct, err := controller.productService.Create(&product, uid)
+	// The product, its "submitted" transaction and its images are stored together or not at all
+	createdProduct, transactionCreated, err := controller.productService.Create(&product, uid)
 	if err != nil {
-		log.Printf("Create product: service error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		switch {
+		case errors.Is(err, service.ErrInvalidCategory):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category", "details": err.Error()})
+		case errors.Is(err, service.ErrInvalidMedia):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image", "details": err.Error()})
+		default:
+			log.Printf("Create product: service error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		}
 		return
 	}
-
-	transaction := models.TransactionRequest{
-		ItemID:      createdProduct.ID,
-		UserID:      uid,
-		Description: createdProduct.Description,
-		Action:      models.TransactionAction(models.Submitted),
-		ImageData:   product.ImageData,
+	user, err := controller.UserService.GetDemographicInformation(uid.String(), viewerFromContext(c))
+	if err != nil {
+		deleted := models.DeletedUser(uid)
+		user = &deleted
 	}
 
-	transactionCreated, _ := controller.TransactionService.AddTransaction(&transaction)
-	user, _ := controller.UserService.GetDemographicInformation(uid.String())
-
 	productResponse := models.ProductResponse{
 		User:         *user,
 		ID:           createdProduct.ID,
@@ -84,7 +90,9 @@ func (controller *ProductController) Create(c *gin.Context) {
 		Category:     createdProduct.Category,
 		SubCategory:  createdProduct.SubCategory,
 		CreatedAt:    createdProduct.CreatedAt,
+		Status:       createdProduct.Status,
 		Transactions: []models.Transaction{*transactionCreated},
+		Gallery:      transactionCreated.Images,
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Product created successfully", "product": productResponse})
@@ -117,12 +125,12 @@ func (controller *ProductController) GetOne(c *gin.Context) {
 		return
 	}
 
-	productResponse, err := controller.populateAdditionalProductData(product)
+	productResponse, err := controller.populateAdditionalProductData(product, viewerFromContext(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve additional product data"})
 		return
 	}
-	detailedProductResponse, err := controller.populateAdditionalTransactionData(&productResponse)
+	detailedProductResponse, err := controller.populateAdditionalTransactionData(&productResponse, viewerFromContext(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve additional Transaction data"})
 		return
@@ -161,7 +169,7 @@ func (controller *ProductController) GetContentBased(c *gin.Context) {
 		// Prepare product responses for random products
 		var randomProductResponses []models.ProductResponse
 		for _, product := range products {
-			productResponse, err := controller.populateAdditionalProductData(&product)
+			productResponse, err := controller.populateAdditionalProductData(&product, viewerFromContext(c))
 			if err != nil {
 				log.Printf("GetProductsByUserID: failed to fetch additional data for random product %s: %v", product.ID.String(), err)
 				continue // Skip to the next product if there's an error
@@ -185,7 +193,7 @@ func (controller *ProductController) GetContentBased(c *gin.Context) {
 	// Prepare product responses for fetched products
 	var productResponses []models.ProductResponse
 	for _, product := range products {
-		productResponse, err := controller.populateAdditionalProductData(&product)
+		productResponse, err := controller.populateAdditionalProductData(&product, viewerFromContext(c))
 		if err != nil {
 			log.Printf("GetProductsByUserID: failed to fetch additional data for product %s: %v", product.ID.String(), err)
 			continue // Skip to the next product if there's an error
@@ -200,11 +208,13 @@ func (controller *ProductController) GetContentBased(c *gin.Context) {
 // GetProductsByUserID retrieves products by user ID with pagination
 // @Summary Get products by user ID with pagination
 // @Tags Products
-// @Description Get all products for a specific user with pagination support
-// @Param user_id query string true "User ID"
-// @Param count   query int    true "Number of products per page"
-// @Param page    query int    true "Page number"
-// @Success 200 {array} models.ProductResponse
+// @Description Get the products of a specific user, newest first, with cursor pagination
+// @Param user_id       query string true  "User ID"
+// @Param limit         query int    false "Number of products per page (default 20, max 100)"
+// @Param cursor        query string false "next_cursor of the previous page"
+// @Param include_total query bool   false "Include the total number of products"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Invalid user ID or pagination parameters"
 // @Router /products/user [get]
 func (controller *ProductController) GetProductsByUserID(c *gin.Context) {
 	userIDStr := c.Query("user_id") // Retrieve the user ID from the query parameter
@@ -220,29 +230,22 @@ func (controller *ProductController) GetProductsByUserID(c *gin.Context) {
 	}
 
 	// Get pagination parameters
-	count, err := strconv.Atoi(c.DefaultQuery("count", "10")) // Default to 10 if not provided
-	if err != nil || count <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count value"})
-		return
-	}
-
-	page, err := strconv.Atoi(c.DefaultQuery("page", "1")) // Default to 1 if not provided
-	if err != nil || page <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page value"})
+	params, ok := pageParams(c)
+	if !ok {
 		return
 	}
 
 	// Call the service to get products with pagination
-	products, err := controller.productService.GetProductsByUserID(userID, count, page)
+	products, page, err := controller.productService.GetProductsByUserID(userID, params)
 	if err != nil {
 		log.Printf("GetProductsByUserID: service error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user products"})
 		return
 	}
 
-	var productResponses []models.ProductResponse
+	productResponses := []models.ProductResponse{}
 	for _, product := range products {
-		productResponse, err := controller.populateAdditionalProductData(&product)
+		productResponse, err := controller.populateAdditionalProductData(&product, viewerFromContext(c))
 		if err != nil {
 			log.Printf("GetProductsByUserID: failed to fetch additional data for product %s: %v", product.ID.String(), err)
 			continue // Skip to the next product if there's an error
@@ -252,7 +255,7 @@ func (controller *ProductController) GetProductsByUserID(c *gin.Context) {
 	}
 
 	// Return the paginated products
-	c.JSON(http.StatusOK, gin.H{"products": productResponses})
+	c.JSON(http.StatusOK, page.Response("products", productResponses))
 }
 
 // GetCollaborative retrieves products using a collaborative filtering approach
@@ -288,7 +291,7 @@ func (controller *ProductController) GetCollaborative(c *gin.Context) {
 
 	var productResponses []models.ProductResponse
 	for _, product := range products {
-		productResponse, err := controller.populateAdditionalProductData(&product)
+		productResponse, err := controller.populateAdditionalProductData(&product, viewerFromContext(c))
 		if err != nil {
 			log.Printf("GetCollaborative: failed to fetch additional data for product %s: %v", product.ID.String(), err)
 			continue // Skip to the next product if there's an error
@@ -335,7 +338,7 @@ func (controller *ProductController) GetItemBased(c *gin.Context) {
 	// Map the products to responses
 	var productResponses []models.ProductResponse
 	for _, product := range products {
-		productResponse, err := controller.populateAdditionalProductData(&product)
+		productResponse, err := controller.populateAdditionalProductData(&product, viewerFromContext(c))
 		if err != nil {
 			log.Printf("GetItemBased: failed to fetch additional data for product %s: %v", product.ID.String(), err)
 			continue // Skip to the next product if there's an error
@@ -364,7 +367,7 @@ func (controller *ProductController) GetRandomProducts(c *gin.Context) {
 
 	var productResponses []models.ProductResponse
 	for _, product := range products {
-		productResponse, err := controller.populateAdditionalProductData(&product)
+		productResponse, err := controller.populateAdditionalProductData(&product, viewerFromContext(c))
 		if err != nil {
 			log.Printf("GetRandomProducts: failed to fetch additional data for product %s: %v", product.ID.String(), err)
 			continue // Skip to the next product if there's an error
@@ -380,10 +383,12 @@ func (controller *ProductController) GetRandomProducts(c *gin.Context) {
 // @Summary Get products by status
 // @Tags         Products
 // @Description Retrieve products by the specified status with pagination
-// @Param        status  query string true  "Product status (e.g., restored, active, archived)"
-// @Param        limit   query int    false "Number of products per page"
-// @Param        page    query int    false "Page number"
-// @Success 200  {array} models.ProductResponse
+// @Param        status         query string true  "Product status (e.g., restored, active, archived)"
+// @Param        limit          query int    false "Number of products per page (default 20, max 100)"
+// @Param        cursor         query string false "next_cursor of the previous page"
+// @Param        include_total  query bool   false "Include the total number of products"
+// @Success 200  {object} map[string]interface{}
+// @Failure 400  {object} map[string]string "Missing status or invalid pagination parameters"
 // @Router /products/status [get]
 func (controller *ProductController) GetProductsByStatus(c *gin.Context) {
 	// Retrieve the status parameter from the query string
@@ -394,19 +399,13 @@ func (controller *ProductController) GetProductsByStatus(c *gin.Context) {
 	}
 
 	// Parse pagination parameters from the query
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if err != nil || limit < 1 {
-		limit = 10
-	}
-
-	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if err != nil || page < 1 {
-		page = 1
+	params, ok := pageParams(c)
+	if !ok {
+		return
 	}
-	offset := (page - 1) * limit
 
 	// Fetch products by status with pagination
-	products, err := controller.productService.GetProductsByStatusPaginated(status, limit, offset)
+	products, page, err := controller.productService.GetProductsByStatusPaginated(status, params)
 	if err != nil {
 		log.Printf("GetProductsByStatus: failed to fetch products by status '%s': %v", status, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve products"})
@@ -414,9 +413,9 @@ func (controller *ProductController) GetProductsByStatus(c *gin.Context) {
 	}
 
 	// Populate additional data and convert to ProductResponse
-	var productResponses []models.ProductResponse
+	productResponses := []models.ProductResponse{}
 	for _, product := range products {
-		productResponse, err := controller.populateAdditionalProductData(&product)
+		productResponse, err := controller.populateAdditionalProductData(&product, viewerFromContext(c))
 		if err != nil {
 			log.Printf("GetProductsByStatus: failed to fetch additional data for product %s: %v", product.ID.String(), err)
 			continue // Skip to the next product if there's an error
@@ -425,10 +424,211 @@ func (controller *ProductController) GetProductsByStatus(c *gin.Context) {
 	}
 
 	// Respond with the paginated products
-	c.JSON(http.StatusOK, gin.H{"products": productResponses})
+	c.JSON(http.StatusOK, page.Response("products", productResponses))
+}
+
+// Search finds products by text and filters
+// @Summary      Search products
+// @Description  Full-text search over product names and descriptions with optional filters. The response includes facet counts per category and status; each facet ignores its own filter. Withdrawn listings are never returned.
+// @Tags         Products
+// @Produce      json
+// @Param        q             query  string  false  "Search text"
+// @Param        category      query  string  false  "Category"
+// @Param        sub_category  query  string  false  "Subcategory"
+// @Param        status        query  string  false  "Product status (available, sold, restored, revitalized)"
+// @Param        min_price     query  number  false  "Minimum price"
+// @Param        max_price     query  number  false  "Maximum price"
+// @Param        created_from  query  string  false  "Listed on or after (RFC 3339 or YYYY-MM-DD)"
+// @Param        created_to    query  string  false  "Listed on or before (RFC 3339 or YYYY-MM-DD)"
+// @Param        sort          query  string  false  "Sort order (relevance, price_asc, price_desc, newest, rating)"
+// @Param        limit          query  int     false  "Number of products per page (default 20, max 100)"
+// @Param        cursor         query  string  false  "next_cursor of the previous page"
+// @Param        include_total  query  bool    false  "Include the total number of matching products"
+// @Success      200  {object} map[string]interface{}
+// @Failure      400  {object} map[string]string  "Invalid search parameters"
+// @Router       /products/search [get]
+func (controller *ProductController) Search(c *gin.Context) {
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	query := models.ProductSearchQuery{
+		Text:        c.Query("q"),
+		Category:    c.Query("category"),
+		SubCategory: c.Query("sub_category"),
+		Status:      models.ProductStatus(c.Query("status")),
+		Sort:        models.ProductSort(c.Query("sort")),
+	}
+
+	var err error
+	if query.MinPrice, err = floatQuery(c, "min_price"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_price", "details": err.Error()})
+		return
+	}
+	if query.MaxPrice, err = floatQuery(c, "max_price"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_price", "details": err.Error()})
+		return
+	}
+	if query.CreatedFrom, err = timeQuery(c, "created_from", false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid created_from", "details": err.Error()})
+		return
+	}
+	if query.CreatedTo, err = timeQuery(c, "created_to", true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid created_to", "details": err.Error()})
+		return
+	}
+
+	result, page, err := controller.productService.Search(query, params)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSearch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search parameters", "details": err.Error()})
+			return
+		}
+		log.Printf("Search: failed to search products: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search products"})
+		return
+	}
+
+	productResponses := []models.ProductResponse{}
+	for _, product := range result.Products {
+		productResponse, err := controller.populateAdditionalProductData(&product, viewerFromContext(c))
+		if err != nil {
+			log.Printf("Search: failed to fetch additional data for product %s: %v", product.ID.String(), err)
+			continue
+		}
+		productResponses = append(productResponses, productResponse)
+	}
+
+	response := page.Response("products", productResponses)
+	response["facets"] = result.Facets
+	c.JSON(http.StatusOK, response)
+}
+
+// floatQuery parses an optional numeric query parameter
+func floatQuery(c *gin.Context, name string) (*float64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// Update edits a listing
+// @Summary      Update a product
+// @Description  Partially update a listing's name, description, price, category, subcategory or image. Only the owner or a moderator may do this; sold and withdrawn listings can't be changed. The change is recorded as an "updated" transaction.
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path  string                true  "Product ID"
+// @Param        product  body  models.UpdateProduct  true  "Fields to change"
+// @Success      200      {object} map[string]interface{}
+// @Failure      400      {object} map[string]string  "Invalid input"
+// @Failure      403      {object} map[string]string  "Not the owner or a moderator"
+// @Failure      404      {object} map[string]string  "Product not found"
+// @Failure      409      {object} map[string]string  "Product can no longer be changed"
+// @Router       /products/{id} [patch]
+func (controller *ProductController) Update(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	var productData models.UpdateProduct
+	if err := c.ShouldBindJSON(&productData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, transaction, err := controller.productService.Edit(actor, productID, &productData)
+	if err != nil {
+		respondProductChangeError(c, err, "Failed to update product")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product updated successfully", "product": product, "transaction": transaction})
+}
+
+// Withdraw pulls a listing down
+// @Summary      Withdraw a product
+// @Description  Withdraw a listing so it is no longer offered. Only the owner or a moderator may do this. The product and its history are kept with the "withdrawn" status, and a "withdrawn" transaction is recorded. While a workshop holds a revitalization job on the product, the job must be cancelled first.
+// @Tags         Products
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path  string                  true   "Product ID"
+// @Param        reason  body  models.WithdrawProduct  false  "Optional reason"
+// @Success      200     {object} map[string]interface{}
+// @Failure      403     {object} map[string]string  "Not the owner or a moderator"
+// @Failure      404     {object} map[string]string  "Product not found"
+// @Failure      409     {object} map[string]string  "Product can no longer be changed or a workshop holds a revitalization job on it"
+// @Router       /products/{id} [delete]
+func (controller *ProductController) Withdraw(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	// The reason is optional, so an empty body is fine
+	var withdrawData models.WithdrawProduct
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&withdrawData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			return
+		}
+	}
+
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	transaction, err := controller.productService.Withdraw(actor, productID, withdrawData.Reason)
+	if err != nil {
+		respondProductChangeError(c, err, "Failed to withdraw product")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product withdrawn successfully", "transaction": transaction})
+}
+
+// respondProductChangeError maps the errors of editing or withdrawing a product to a response
+func respondProductChangeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		forbidden(c, err)
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrProductNotEditable), errors.Is(err, service.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Product can no longer be changed", "details": err.Error()})
+	case errors.Is(err, service.ErrInvalidAction):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action", "details": err.Error()})
+	case errors.Is(err, service.ErrNoChanges):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes given"})
+	case errors.Is(err, service.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category", "details": err.Error()})
+	case errors.Is(err, service.ErrInvalidMedia):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image", "details": err.Error()})
+	default:
+		log.Printf("%s: %v", message, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
 }
 
-func (controller *ProductController) populateAdditionalTransactionData(product *models.ProductResponse) (models.DetailedProductResponse, error) {
+func (controller *ProductController) populateAdditionalTransactionData(product *models.ProductResponse, viewer *service.Actor) (models.DetailedProductResponse, error) {
 	var productRes models.DetailedProductResponse
 
 	// Fetch transactions for the product
@@ -437,13 +637,22 @@ func (controller *ProductController) populateAdditionalTransactionData(product *
 		return productRes, err
 	}
 
-	// Iterate over each transaction to fetch demographic information for the user
+	// Fetch the demographic information of everyone involved in one go
+	userIDs := make([]uuid.UUID, len(transactions))
+	for i, transaction := range transactions {
+		userIDs[i] = transaction.UserID
+	}
+	users, err := controller.UserService.GetProfiles(userIDs, viewer)
+	if err != nil {
+		return productRes, err
+	}
+
 	var detailedTransactions []models.DetailedTransaction
 	for _, transaction := range transactions {
-		// Fetch the demographic information for the user involved in the transaction
-		user, err := controller.UserService.GetDemographicInformation(transaction.UserID.String())
-		if err != nil {
-			return productRes, err
+		// Closed accounts keep their place in the history, reduced to their ID
+		user, ok := users[transaction.UserID]
+		if !ok {
+			user = models.User{ID: transaction.UserID}
 		}
 
 		// Construct a detailed transaction with user demographic information
@@ -453,7 +662,11 @@ func (controller *ProductController) populateAdditionalTransactionData(product *
 			Description: transaction.Description,
 			Action:      transaction.Action,
 			ImageURL:    transaction.ImageURL,
-			User:        *user, // Attach the user's demographic info
+			Images:      transaction.Images,
+			Price:       transaction.Price,
+			Original:    transaction.Original,
+			Amendments:  transaction.Amendments,
+			User:        user, // Attach the user's demographic info
 		}
 
 		// Add to the slice of detailed transactions
@@ -474,13 +687,14 @@ func (controller *ProductController) populateAdditionalTransactionData(product *
 		CreatedAt:     product.CreatedAt,
 		Status:        product.Status,
 		Transactions:  detailedTransactions,
+		Gallery:       product.Gallery,
 	}
 
 	return productRes, nil
 }
-func (controller *ProductController) populateAdditionalProductData(product *models.Product) (models.ProductResponse, error) {
+func (controller *ProductController) populateAdditionalProductData(product *models.Product, viewer *service.Actor) (models.ProductResponse, error) {
 	var productRes models.ProductResponse
-	transactions, err := controller.TransactionService.GetByProductID(product.ID)
+	transactions, gallery, err := controller.TransactionService.GetWithGallery(product.ID)
 	if err != nil {
 		return productRes, err
 	}
@@ -490,7 +704,12 @@ func (controller *ProductController) populateAdditionalProductData(product *mode
 	if err != nil {
 		return productRes, err
 	}
-	user, _ := controller.UserService.GetDemographicInformation(product.UserID.String())
+	user, err := controller.UserService.GetDemographicInformation(product.UserID.String(), viewer)
+	if err != nil {
+		// The owner closed their account; the product and its history stay listed
+		deleted := models.DeletedUser(product.UserID)
+		user = &deleted
+	}
 
 	UserRating, _ := controller.RatingService.GetPuanByUserIdItemId(product.UserID, product.ID)
 
@@ -508,73 +727,94 @@ func (controller *ProductController) populateAdditionalProductData(product *mode
 		CreatedAt:     product.CreatedAt,
 		Status:        product.Status,
 		Transactions:  transactions,
+		Gallery:       gallery,
 	}
 	return productRes, nil
 }
 
 // GetRatedProductsByUserID godoc
 // @Summary Get rated products by user ID
-// @Description Fetches a list of products rated by the specified user
+// @Description Fetches a page of the products rated by the specified user, most recently rated first
 // @Tags Products
 // @Accept json
 // @Produce json
-// @Param user_id query string true "User ID"
-// @Success 200 {array} models.ProductResponse "List of rated products"
+// @Param user_id       query string true  "User ID"
+// @Param limit         query int    false "Number of products per page (default 20, max 100)"
+// @Param cursor        query string false "next_cursor of the previous page"
+// @Param include_total query bool   false "Include the total number of rated products"
+// @Success 200 {object} map[string]interface{} "Page of rated products"
+// @Failure 400 {object} map[string]string "Invalid user ID or pagination parameters"
+// @Failure 403 {object} map[string]string "The user's activity is private"
 // @Router /products/rated [get]
 func (controller *ProductController) GetRatedProductsByUserID(c *gin.Context) {
-	userID := c.Query("user_id")
+	userID, err := uuid.Parse(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	// Users can hide which products they rated
+	allowed, err := controller.privacyService.CanViewActivity(viewerFromContext(c), userID.String())
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch rated items"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user's activity is private"})
+		return
+	}
 
 	// Fetch the ratings made by the user
-	ratedItems, err := controller.RatingService.GetRatedProductIDsByUserID(userID)
+	ratings, page, err := controller.RatingService.GetRatedProductsByUserId(userID, params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch rated items"})
 		return
 	}
 
 	// Iterate through the rated items and fetch product details for each
-	var ratedProducts []models.ProductResponse
-	for _, id := range ratedItems {
-		productID, _ := uuid.Parse(id)
-		product, err := controller.productService.GetByID(productID)
+	ratedProducts := []models.ProductResponse{}
+	for _, rating := range ratings {
+		product, err := controller.productService.GetByID(rating.ProductID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch product details"})
 			return
 		}
 
 		// Append the product to the result
-		p, _ := controller.populateAdditionalProductData(product)
+		p, _ := controller.populateAdditionalProductData(product, viewerFromContext(c))
 		ratedProducts = append(ratedProducts, p)
 	}
 
-	// Return the list of rated products
-	c.JSON(http.StatusOK, ratedProducts)
+	// Return the page of rated products
+	c.JSON(http.StatusOK, page.Response("products", ratedProducts))
 }
 
-// GetPaginatedRandomProducts retrieves random products with pagination
+// GetPaginatedRandomProducts retrieves listed products with pagination
 // @Summary Get paginated random products
 // @Tags         Products
-// @Description Retrieve random products for unauthenticated users with pagination support
-// @Param        count  query   int  true   "Number of products per page"
-// @Param        page   query   int  true   "Page number"
-// @Success 200 {array} models.ProductResponse
+// @Description Retrieve the products still listed, newest first, for unauthenticated users with cursor pagination
+// @Param        limit          query  int     false  "Number of products per page (default 20, max 100)"
+// @Param        cursor         query  string  false  "next_cursor of the previous page"
+// @Param        include_total  query  bool    false  "Include the total number of products"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Invalid pagination parameters"
 // @Router /products/random/paginated [get]
 func (controller *ProductController) GetPaginatedRandomProducts(c *gin.Context) {
-	// Get 'count' and 'page' query parameters from the request, with defaults if not specified
-	count, err := strconv.Atoi(c.DefaultQuery("count", "10")) // Default to 10 products per page
-	if err != nil || count <= 0 {
-		count = 10
-	}
-
-	page, err := strconv.Atoi(c.DefaultQuery("page", "1")) // Default to the first page
-	if err != nil || page <= 0 {
-		page = 1
+	params, ok := pageParams(c)
+	if !ok {
+		return
 	}
 
-	// Calculate the offset for pagination
-	offset := (page - 1) * count
-
 	// Fetch random paginated products from the product service
-	products, err := controller.productService.GetRandomProductsPaginated(count, offset)
+	products, page, err := controller.productService.GetRandomProductsPaginated(params)
 	if err != nil {
 		log.Printf("GetPaginatedRandomProducts: failed to fetch random products: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve random products"})
@@ -582,9 +822,9 @@ func (controller *ProductController) GetPaginatedRandomProducts(c *gin.Context)
 	}
 
 	// Populate additional product data
-	var productResponses []models.ProductResponse
+	productResponses := []models.ProductResponse{}
 	for _, product := range products {
-		productResponse, err := controller.populateAdditionalProductData(&product)
+		productResponse, err := controller.populateAdditionalProductData(&product, viewerFromContext(c))
 		if err != nil {
 			log.Printf("GetPaginatedRandomProducts: failed to fetch additional data for product %s: %v", product.ID.String(), err)
 			continue // Skip to the next product if there's an error
@@ -593,10 +833,5 @@ func (controller *ProductController) GetPaginatedRandomProducts(c *gin.Context)
 	}
 
 	// Send the paginated products in the response
-	c.JSON(http.StatusOK, gin.H{
-		"products": productResponses,
-		"page":     page,
-		"count":    count,
-		"total":    len(products), // Total products in the current page
-	})
+	c.JSON(http.StatusOK, page.Response("products", productResponses))
 }
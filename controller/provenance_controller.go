@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"backend/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ProvenanceController handles HTTP requests for the provenance of products
+type ProvenanceController struct {
+	provenanceService *service.ProvenanceService
+}
+
+// NewProvenanceController creates a new ProvenanceController instance
+func NewProvenanceController(provenanceService *service.ProvenanceService) *ProvenanceController {
+	return &ProvenanceController{provenanceService: provenanceService}
+}
+
+// Get returns the provenance chain of a product
+// @Summary      Get product provenance
+// @Description  Get the hash-chained history of a product, oldest entry first, with the result of verifying the chain. Each entry's hash is the SHA-256 of its content and the hash of the entry before it, so altered entries show up as problems. Corrections of a transaction are appended as "amended" entries spelling out the corrected values; amendments that do not match their entry show up as problems as well.
+// @Tags         Products
+// @Produce      json
+// @Param        id   path  string  true  "Product ID"
+// @Success      200  {object} models.Provenance
+// @Failure      400  {object} map[string]string  "Invalid product ID"
+// @Failure      404  {object} map[string]string  "Product not found"
+// @Router       /products/{id}/provenance [get]
+func (controller *ProvenanceController) Get(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	result, err := controller.provenanceService.Get(productID)
+	if err != nil {
+		respondProvenanceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Certificate issues a signed provenance certificate for a product
+// @Summary      Get provenance certificate
+// @Description  Issue a signed snapshot of a product's provenance chain as a JWT. It can be verified offline with the key from /.well-known/jwks.json and by recomputing the hashes of the chain in its claims, which includes the "amended" entries. Only chains whose entries and amendments verify are certified. With download=true the raw certificate is returned as a file.
+// @Tags         Products
+// @Produce      json
+// @Param        id        path   string  true   "Product ID"
+// @Param        download  query  bool    false  "Return the certificate as a file"
+// @Success      200  {object} models.ProvenanceCertificate
+// @Failure      400  {object} map[string]string  "Invalid product ID"
+// @Failure      404  {object} map[string]string  "Product not found"
+// @Failure      409  {object} map[string]string  "The chain does not verify"
+// @Router       /products/{id}/provenance/certificate [get]
+func (controller *ProvenanceController) Certificate(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	certificate, err := controller.provenanceService.Certificate(productID)
+	if err != nil {
+		respondProvenanceError(c, err)
+		return
+	}
+
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", "attachment; filename=provenance-"+productID.String()+".jwt")
+		c.Data(http.StatusOK, "application/jwt", []byte(certificate.Certificate))
+		return
+	}
+	c.JSON(http.StatusOK, certificate)
+}
+
+// respondProvenanceError writes the response for an error returned by the provenance service
+func respondProvenanceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrProvenanceBroken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Provenance chain does not verify", "details": err.Error()})
+	default:
+		log.Printf("Provenance: service error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve provenance", "details": err.Error()})
+	}
+}
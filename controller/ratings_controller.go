@@ -3,6 +3,7 @@ package controller
 import (
 	"backend/models"
 	"backend/service"
+	"errors"
 	"log"
 	"net/http"
 
@@ -11,12 +12,13 @@ import (
 )
 
 type RatingController struct {
-	ratingService *service.RatingService
+	ratingService  *service.RatingService
+	privacyService *service.PrivacyService
 }
 
 // NewRatingController creates a new RatingController instance
-func NewRatingController(ratingService *service.RatingService) *RatingController {
-	return &RatingController{ratingService: ratingService}
+func NewRatingController(ratingService *service.RatingService, privacyService *service.PrivacyService) *RatingController {
+	return &RatingController{ratingService: ratingService, privacyService: privacyService}
 }
 
 // Create handles the creation of a new rating
@@ -63,6 +65,8 @@ func (controller *RatingController) Create(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        id   path   string  true   "Rating ID"
+// @Failure      403  {object} map[string]string  "Only the author or a moderator may delete a rating"
+// @Failure      404  {object} map[string]string  "Rating not found"
 // @Router       /ratings/{id} [delete]
 func (controller *RatingController) Delete(c *gin.Context) {
 	idParam := c.Param("id")
@@ -73,23 +77,41 @@ func (controller *RatingController) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := controller.ratingService.Delete(id); err != nil {
-		log.Printf("Error deleting rating: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rating", "details": err.Error()})
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required", "details": err.Error()})
+		return
+	}
+
+	if err := controller.ratingService.Delete(id, actor); err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			forbidden(c, err)
+		case errors.Is(err, service.ErrRatingNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rating not found"})
+		default:
+			log.Printf("Error deleting rating: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rating", "details": err.Error()})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Rating deleted successfully"})
 }
 
-// GetRatedProductsByUserId retrieves all rated products by a user's ID
+// GetRatedProductsByUserId retrieves the ratings a user gave
 // @Summary      Get rated products by user ID
-// @Description  Retrieves all products rated by a specific user
+// @Description  Retrieves a page of the ratings a specific user gave, newest first
 // @Tags         Ratings
 // @Accept       json
 // @Produce      json
-// @Param        user_id   path    string  true   "User ID"
-// @Success      200       {array} models.Rating
+// @Param        user_id        path   string  true   "User ID"
+// @Param        limit          query  int     false  "Number of ratings per page (default 20, max 100)"
+// @Param        cursor         query  string  false  "next_cursor of the previous page"
+// @Param        include_total  query  bool    false  "Include the total number of ratings"
+// @Success      200       {object} map[string]interface{}
+// @Failure      400       {object} map[string]string  "Invalid pagination parameters"
+// @Failure      403       {object} map[string]string  "The user's activity is private"
 // @Router       /ratings/user/{user_id} [get]
 func (controller *RatingController) GetRatedProductsByUserId(c *gin.Context) {
 	userIDParam := c.Param("user_id")
@@ -99,15 +121,60 @@ func (controller *RatingController) GetRatedProductsByUserId(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user UUID format"})
 		return
 	}
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	// Users can hide which products they rated
+	allowed, err := controller.privacyService.CanViewActivity(viewerFromContext(c), userID.String())
+	if err != nil {
+		log.Printf("Error checking privacy settings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rated products", "details": err.Error()})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user's activity is private"})
+		return
+	}
 
-	ratings, err := controller.ratingService.GetRatedProductsByUserId(userID)
+	ratings, page, err := controller.ratingService.GetRatedProductsByUserId(userID, params)
 	if err != nil {
 		log.Printf("Error retrieving rated products: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rated products", "details": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"ratings": ratings})
+	c.JSON(http.StatusOK, page.Response("ratings", ratings))
+}
+
+// Export retrieves all ratings page by page
+// @Summary      Export ratings
+// @Description  Export all ratings, oldest first. Requires moderator or admin role; API keys need the ratings:export scope.
+// @Tags         Ratings
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        limit          query  int     false  "Number of ratings per page (default 20, max 100)"
+// @Param        cursor         query  string  false  "next_cursor of the previous page"
+// @Param        include_total  query  bool    false  "Include the total number of ratings"
+// @Success      200    {object} map[string]interface{}
+// @Failure      400    {object} map[string]string  "Invalid pagination parameters"
+// @Failure      403    {object} map[string]string  "Insufficient permissions"
+// @Router       /ratings/export [get]
+func (controller *RatingController) Export(c *gin.Context) {
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	ratings, page, err := controller.ratingService.Export(params)
+	if err != nil {
+		log.Printf("Error exporting ratings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export ratings", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page.Response("ratings", ratings))
 }
 
 // GetAverageRatingByProductId retrieves the average rating and the count of ratings for a product
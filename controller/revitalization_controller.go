@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"backend/models"
+	"backend/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RevitalizationController handles HTTP requests for revitalization requests and workshops' bids
+type RevitalizationController struct {
+	revitalizationService *service.RevitalizationService
+}
+
+// NewRevitalizationController creates a new RevitalizationController instance
+func NewRevitalizationController(revitalizationService *service.RevitalizationService) *RevitalizationController {
+	return &RevitalizationController{revitalizationService: revitalizationService}
+}
+
+// Publish publishes a revitalization request for a product
+// @Summary      Publish revitalization request
+// @Description  Ask workshops to revitalize a product. Only its owner or a moderator can do this, while the product may be revitalized, and a product has one request going at a time.
+// @Tags         Revitalizations
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        request  body  models.PublishRevitalization  true  "Product and what should be done"
+// @Success      201  {object} models.RevitalizationRequest
+// @Failure      400  {object} map[string]string  "Invalid input or the product already has a request"
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Product not found"
+// @Failure      409  {object} map[string]string  "The product cannot be revitalized in its status"
+// @Router       /revitalizations [post]
+func (controller *RevitalizationController) Publish(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.PublishRevitalization
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	request, err := controller.revitalizationService.Publish(actor, &req)
+	if err != nil {
+		respondRevitalizationError(c, "publish revitalization request", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// List lists revitalization requests
+// @Summary      List revitalization requests
+// @Description  List revitalization requests in a status, newest first. Without a status the open requests workshops can bid on are listed.
+// @Tags         Revitalizations
+// @Produce      json
+// @Param        status         query  string  false  "Only requests in this status (default open)"  Enums(open, accepted, in_progress, delivered, cancelled)
+// @Param        limit          query  int     false  "Page size (default 20, max 100)"
+// @Param        cursor         query  string  false  "Cursor from the previous page's next_cursor"
+// @Param        include_total  query  bool    false  "Also return the total number of requests"
+// @Success      200  {object} map[string]interface{}
+// @Failure      400  {object} map[string]string  "Invalid status or pagination parameters"
+// @Router       /revitalizations [get]
+func (controller *RevitalizationController) List(c *gin.Context) {
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	requests, page, err := controller.revitalizationService.List(models.RevitalizationStatus(c.Query("status")), params)
+	if err != nil {
+		respondRevitalizationError(c, "list revitalization requests", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page.Response("revitalizations", requests))
+}
+
+// Get retrieves a revitalization request
+// @Summary      Get revitalization request
+// @Description  Get a revitalization request. The owner and moderators see every bid on it, a workshop only its own.
+// @Tags         Revitalizations
+// @Produce      json
+// @Param        id   path  string  true  "Revitalization request ID"
+// @Success      200  {object} models.RevitalizationRequest
+// @Failure      400  {object} map[string]string  "Invalid ID"
+// @Failure      404  {object} map[string]string  "Revitalization request not found"
+// @Router       /revitalizations/{id} [get]
+func (controller *RevitalizationController) Get(c *gin.Context) {
+	id, ok := revitalizationID(c)
+	if !ok {
+		return
+	}
+
+	request, err := controller.revitalizationService.Get(viewerFromContext(c), id)
+	if err != nil {
+		respondRevitalizationError(c, "retrieve revitalization request", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// Bid places a bid on a revitalization request
+// @Summary      Bid on revitalization request
+// @Description  Offer to revitalize a product for a price within an estimated number of days. Only users with the workshop role may bid. The portfolio lists revitalized transactions the workshop posted before. A workshop bids once per request.
+// @Tags         Revitalizations
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string           true  "Revitalization request ID"
+// @Param        bid  body  models.PlaceBid  true  "Price, estimated duration and portfolio"
+// @Success      201  {object} models.RevitalizationBid
+// @Failure      400  {object} map[string]string  "Invalid input, portfolio or a second bid"
+// @Failure      403  {object} map[string]string  "Not a workshop"
+// @Failure      404  {object} map[string]string  "Revitalization request not found"
+// @Failure      409  {object} map[string]string  "The request is no longer open"
+// @Router       /revitalizations/{id}/bids [post]
+func (controller *RevitalizationController) Bid(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, ok := revitalizationID(c)
+	if !ok {
+		return
+	}
+
+	var req models.PlaceBid
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	bid, err := controller.revitalizationService.Bid(actor, id, &req)
+	if err != nil {
+		respondRevitalizationError(c, "place bid", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, bid)
+}
+
+// Accept accepts a bid on a revitalization request
+// @Summary      Accept bid
+// @Description  The owner accepts a bid, which gives the job to its workshop and rejects all other bids. From then on only that workshop may post the product's revitalized transaction.
+// @Tags         Revitalizations
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path  string  true  "Revitalization request ID"
+// @Param        bid_id  path  string  true  "Bid ID"
+// @Success      200  {object} models.RevitalizationRequest
+// @Failure      400  {object} map[string]string  "Invalid ID or unknown bid"
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Revitalization request not found"
+// @Failure      409  {object} map[string]string  "The request is no longer open or the product cannot be revitalized"
+// @Router       /revitalizations/{id}/bids/{bid_id}/accept [post]
+func (controller *RevitalizationController) Accept(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, ok := revitalizationID(c)
+	if !ok {
+		return
+	}
+	bidID, err := uuid.Parse(c.Param("bid_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bid ID format"})
+		return
+	}
+
+	request, err := controller.revitalizationService.Accept(actor, id, bidID)
+	if err != nil {
+		respondRevitalizationError(c, "accept bid", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// Start starts a revitalization job
+// @Summary      Start revitalization job
+// @Description  The workshop whose bid was accepted marks the job as in progress. It delivers the job by posting the product's revitalized transaction.
+// @Tags         Revitalizations
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path  string  true  "Revitalization request ID"
+// @Success      200  {object} models.RevitalizationRequest
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Revitalization request not found"
+// @Failure      409  {object} map[string]string  "The job is not accepted"
+// @Router       /revitalizations/{id}/start [post]
+func (controller *RevitalizationController) Start(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, ok := revitalizationID(c)
+	if !ok {
+		return
+	}
+
+	request, err := controller.revitalizationService.Start(actor, id)
+	if err != nil {
+		respondRevitalizationError(c, "start job", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// Cancel cancels a revitalization request
+// @Summary      Cancel revitalization request
+// @Description  Call a request off and reject its bids. The owner can cancel until the workshop starts the job; after that only a moderator can.
+// @Tags         Revitalizations
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path  string                       true   "Revitalization request ID"
+// @Param        cancel  body  models.CancelRevitalization  false  "Why the request is cancelled"
+// @Success      200  {object} models.RevitalizationRequest
+// @Failure      403  {object} map[string]string  "Forbidden"
+// @Failure      404  {object} map[string]string  "Revitalization request not found"
+// @Failure      409  {object} map[string]string  "The request can no longer be cancelled"
+// @Router       /revitalizations/{id}/cancel [post]
+func (controller *RevitalizationController) Cancel(c *gin.Context) {
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, ok := revitalizationID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CancelRevitalization
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			return
+		}
+	}
+
+	request, err := controller.revitalizationService.Cancel(actor, id, req.Reason)
+	if err != nil {
+		respondRevitalizationError(c, "cancel revitalization request", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// revitalizationID reads the request ID from the path. It answers 400 and reports false when it is invalid.
+func revitalizationID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revitalization request ID format"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// respondRevitalizationError writes the response for an error returned by the revitalization service
+func respondRevitalizationError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		forbidden(c, err)
+	case errors.Is(err, service.ErrRevitalizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revitalization request not found"})
+	case errors.Is(err, service.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, service.ErrInvalidRevitalization):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revitalization request", "details": err.Error()})
+	case errors.Is(err, service.ErrInvalidBid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bid", "details": err.Error()})
+	case errors.Is(err, service.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Invalid status transition", "details": err.Error()})
+	default:
+		log.Printf("Failed to %s: service error: %v", op, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + op, "details": err.Error()})
+	}
+}
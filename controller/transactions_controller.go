@@ -3,9 +3,10 @@ package controller
 import (
 	"backend/models"
 	"backend/service"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -15,22 +16,33 @@ import (
 type TransactionController struct {
 	transactionService *service.TransactionService
 	productService     *service.ProductService
+	userService        *service.UserService
+	privacyService     *service.PrivacyService
 }
 
 // NewTransactionController creates a new TransactionController instance
-func NewTransactionController(transactionService *service.TransactionService, productService *service.ProductService) *TransactionController {
-	return &TransactionController{transactionService: transactionService, productService: productService}
+func NewTransactionController(transactionService *service.TransactionService, productService *service.ProductService, userService *service.UserService, privacyService *service.PrivacyService) *TransactionController {
+	return &TransactionController{
+		transactionService: transactionService,
+		productService:     productService,
+		userService:        userService,
+		privacyService:     privacyService,
+	}
 }
 
 // AddTransactionToItem adds a transaction to an item
 // @Summary      Add transaction to item
-// @Description  Adds a transaction (submitted, revitalized, or sold) to an item
+// @Description  Records a lifecycle step of an item and moves it to the matching status: revitalized (available or sold -> restored, needs an image) or submittedRevitalized (restored -> restoredAvailable). Only the current owner may do this, except that while a workshop holds a revitalization job on the item only that workshop may record it revitalized, which delivers the job. A price other than 0 becomes the item's new price. Other steps have their own endpoints: reservations and sales happen through orders, and edits and withdrawals through the product endpoints; sending those actions here is rejected.
 // @Tags         Transactions
 // @Accept       json
 // @Produce      json
 // @Param        item_id      path      string  true   "Item ID"
 // @Param        body         body      models.AddTransactionRequest  true   "Transaction details"
 // @Success      201          {object}  models.Transaction
+// @Failure      400          {object}  map[string]string  "Invalid input or image, or an action recorded through another endpoint"
+// @Failure      403          {object}  map[string]string  "Only the current owner, or the workshop holding the revitalization job, may add transactions"
+// @Failure      404          {object}  map[string]string  "Product not found"
+// @Failure      409          {object}  map[string]string  "The item's status does not allow the action"
 // @Router       /transactions/{item_id} [post]
 func (controller *TransactionController) AddTransactionToItem(c *gin.Context) {
 	var transactionReq models.AddTransactionRequest
@@ -48,72 +60,273 @@ func (controller *TransactionController) AddTransactionToItem(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+	actor, err := actorFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context", "details": err.Error()})
 		return
 	}
 
-	// Convert userID to UUID
-	uid, err := uuid.Parse(userID.(string))
+	// The service checks the action against the product lifecycle and that the caller owns the product
+	product, t, err := controller.productService.AddTransaction(actor, itemID, &transactionReq)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			forbidden(c, err)
+		case errors.Is(err, service.ErrProductNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		case errors.Is(err, service.ErrInvalidAction):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action", "details": err.Error()})
+		case errors.Is(err, service.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Action not allowed in the product's current status", "details": err.Error()})
+		case errors.Is(err, service.ErrInvalidMedia):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image", "details": err.Error()})
+		default:
+			log.Printf("Error adding transaction: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add transaction", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Transaction added successfully", "transaction": t, "status": product.Status})
+}
+
+// List lists the transactions of a product
+// @Summary      List product transactions
+// @Description  List the history of a product, newest first, with a summary of the user behind each entry.
+// @Tags         Transactions
+// @Produce      json
+// @Param        product_id     query  string  true   "Product ID"
+// @Param        action         query  string  false  "Only these actions, comma separated"
+// @Param        from           query  string  false  "Only entries from this time on (RFC 3339 or YYYY-MM-DD)"
+// @Param        to             query  string  false  "Only entries up to this time (RFC 3339, or YYYY-MM-DD to include that day)"
+// @Param        limit          query  int     false  "Page size (default 20, max 100)"
+// @Param        cursor         query  string  false  "Cursor from the previous page's next_cursor"
+// @Param        include_total  query  bool    false  "Also return the total number of transactions"
+// @Success      200  {object} map[string]interface{}
+// @Failure      400  {object} map[string]string  "Invalid product ID, filter or pagination parameters"
+// @Router       /transactions [get]
+func (controller *TransactionController) List(c *gin.Context) {
+	productID, err := uuid.Parse(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+	filter, ok := transactionFilter(c)
+	if !ok {
+		return
+	}
+	filter.ProductID = &productID
+
+	controller.list(c, filter)
+}
+
+// ListByUser lists the transactions a user recorded
+// @Summary      List user transactions
+// @Description  List what a user submitted, revitalized, sold and otherwise recorded, newest first. Users can hide their activity.
+// @Tags         Transactions
+// @Produce      json
+// @Param        id             path   string  true   "User ID"
+// @Param        action         query  string  false  "Only these actions, comma separated"
+// @Param        from           query  string  false  "Only entries from this time on (RFC 3339 or YYYY-MM-DD)"
+// @Param        to             query  string  false  "Only entries up to this time (RFC 3339, or YYYY-MM-DD to include that day)"
+// @Param        limit          query  int     false  "Page size (default 20, max 100)"
+// @Param        cursor         query  string  false  "Cursor from the previous page's next_cursor"
+// @Param        include_total  query  bool    false  "Also return the total number of transactions"
+// @Success      200  {object} map[string]interface{}
+// @Failure      400  {object} map[string]string  "Invalid user ID, filter or pagination parameters"
+// @Failure      403  {object} map[string]string  "The user's activity is private"
+// @Router       /users/{id}/transactions [get]
+func (controller *TransactionController) ListByUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
 		return
 	}
+	filter, ok := transactionFilter(c)
+	if !ok {
+		return
+	}
+	filter.UserID = &userID
+
+	// Users can hide their activity
+	allowed, err := controller.privacyService.CanViewActivity(viewerFromContext(c), userID.String())
+	if err != nil {
+		log.Printf("Error checking privacy settings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions", "details": err.Error()})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user's activity is private"})
+		return
+	}
+
+	controller.list(c, filter)
+}
+
+// Get retrieves a transaction
+// @Summary      Get transaction
+// @Description  Get a transaction with the images uploaded with it and a summary of the user who recorded it.
+// @Tags         Transactions
+// @Produce      json
+// @Param        id   path  string  true  "Transaction ID"
+// @Success      200  {object} models.TransactionEntry
+// @Failure      400  {object} map[string]string  "Invalid transaction ID"
+// @Failure      404  {object} map[string]string  "Transaction not found"
+// @Router       /transactions/{id} [get]
+func (controller *TransactionController) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID format"})
+		return
+	}
+
+	transaction, err := controller.transactionService.Get(id)
+	if err != nil {
+		if errors.Is(err, service.ErrTransactionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transaction", "details": err.Error()})
+		return
+	}
 
-	// Retrieve product details by ID
-	product, err := controller.productService.GetByID(itemID)
+	entries, err := controller.withUsers(c, []models.Transaction{*transaction})
 	if err != nil {
-		log.Printf("Error retrieving product: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transaction", "details": err.Error()})
 		return
 	}
-	if product == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+
+	c.JSON(http.StatusOK, entries[0])
+}
+
+// Amend records a correction of a transaction
+// @Summary      Amend transaction
+// @Description  Correct the description, photo or price of a submitted, revitalized or submittedRevitalized transaction; the entries the platform records, such as reservations and sales, cannot be amended. The transaction stays as it was posted and the correction is kept with its reason and author; responses show the corrected values, the original ones and every amendment. Each correction is also appended to the product's provenance chain as an "amended" entry spelling out the corrected values. Only the author of the transaction or a moderator may do this. A corrected price becomes the product's price, recorded as an "updated" entry, only when the product's owner corrects the newest transaction posted on it while it is for sale.
+// @Tags         Transactions
+// @Accept       json
+// @Produce      json
+// @Param        id    path  string                   true  "Transaction ID"
+// @Param        body  body  models.AmendTransaction  true  "Correction and its reason"
+// @Success      201  {object} models.TransactionEntry
+// @Failure      400  {object} map[string]string  "Invalid input, image, nothing to correct or a transaction that cannot be amended"
+// @Failure      403  {object} map[string]string  "Only the author or a moderator may amend the transaction"
+// @Failure      404  {object} map[string]string  "Transaction not found"
+// @Failure      409  {object} map[string]string  "The product changed in the meantime"
+// @Router       /transactions/{id}/amendments [post]
+func (controller *TransactionController) Amend(c *gin.Context) {
+	// The route shares its wildcard with AddTransactionToItem, so the transaction ID is named item_id
+	id, err := uuid.Parse(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID format"})
 		return
 	}
 
-	// Prepare transaction data
-	transaction := models.TransactionRequest{
-		ItemID:      itemID,
-		UserID:      uid,
-		Description: transactionReq.Description, // Use the Description from the request
-		Action:      transactionReq.Action,      // Use the Action from the request (TransactionAction type)
-		ImageData:   transactionReq.ImageData,   // Use the ImageURL from the request
+	var req models.AmendTransaction
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
 	}
 
-	// Add the transaction
-	t, err := controller.transactionService.AddTransaction(&transaction)
+	actor, err := actorFromContext(c)
 	if err != nil {
-		log.Printf("Error adding transaction: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add transaction", "details": err.Error()})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context", "details": err.Error()})
+		return
+	}
+
+	transaction, err := controller.transactionService.Amend(actor, id, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			forbidden(c, err)
+		case errors.Is(err, service.ErrTransactionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		case errors.Is(err, service.ErrInvalidAmendment):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amendment", "details": err.Error()})
+		case errors.Is(err, service.ErrInvalidMedia):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image", "details": err.Error()})
+		case errors.Is(err, service.ErrInvalidTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": "Product changed in the meantime", "details": err.Error()})
+		default:
+			log.Printf("Error amending transaction: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to amend transaction", "details": err.Error()})
+		}
+		return
+	}
+
+	entries, err := controller.withUsers(c, []models.Transaction{*transaction})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transaction", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entries[0])
+}
+
+// list writes a page of the transactions matching the filter
+func (controller *TransactionController) list(c *gin.Context, filter models.TransactionFilter) {
+	params, ok := pageParams(c)
+	if !ok {
 		return
 	}
-	product.UserID = transaction.UserID
-	product.Price = transactionReq.Price
-	if transactionReq.Action == models.Revitalized {
-		// If the action is "revitalized", set the status to "restored"
-		product.Status = models.StatusRestored
-	} else {
-		// If the action is "submitted" or "submittedRevitalized", set the status to "available"
-		if transactionReq.Action == models.Submitted {
-			product.Status = models.StatusAvailable
-		} else if transactionReq.Action == models.Sold {
-			// If the action is "sold", set the status to "sold"
-			product.Status = models.StatusSold
-		} else {
-			// If none of the above conditions are met, set the status to "restoredAvailable"
-			product.Status = models.StatusRestoredAvailable
+
+	transactions, page, err := controller.transactionService.List(filter, params)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidFilter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter", "details": err.Error()})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions", "details": err.Error()})
+		return
 	}
 
-	err = controller.productService.Update(product)
+	entries, err := controller.withUsers(c, transactions)
 	if err != nil {
-		log.Printf("Error updating product status: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product status", "details": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions", "details": err.Error()})
 		return
 	}
-	fmt.Println(product.Status)
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Transaction added successfully", "transaction": t})
+	c.JSON(http.StatusOK, page.Response("transactions", entries))
+}
+
+// withUsers pairs each transaction with a summary of its user, resolving all users in one query
+func (controller *TransactionController) withUsers(c *gin.Context, transactions []models.Transaction) ([]models.TransactionEntry, error) {
+	userIDs := make([]uuid.UUID, len(transactions))
+	for i, transaction := range transactions {
+		userIDs[i] = transaction.UserID
+	}
+	users, err := controller.userService.GetProfiles(userIDs, viewerFromContext(c))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.TransactionEntry, len(transactions))
+	for i, transaction := range transactions {
+		user, ok := users[transaction.UserID]
+		if !ok {
+			user = models.User{ID: transaction.UserID}
+		}
+		entries[i] = models.TransactionEntry{Transaction: transaction, User: user.Summary()}
+	}
+	return entries, nil
+}
+
+// transactionFilter reads the action and date range query parameters of a transaction list. Dates
+// without a time cover the whole day. It answers 400 and reports false when they are invalid.
+func transactionFilter(c *gin.Context) (models.TransactionFilter, bool) {
+	var filter models.TransactionFilter
+	for _, action := range strings.Split(c.Query("action"), ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			filter.Actions = append(filter.Actions, models.TransactionAction(action))
+		}
+	}
+
+	var err error
+	if filter.From, err = timeQuery(c, "from", false); err == nil {
+		filter.To, err = timeQuery(c, "to", true)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter", "details": err.Error()})
+		return filter, false
+	}
+	return filter, true
 }
@@ -4,21 +4,31 @@ package controller
 import (
 	"backend/models"
 	"backend/service"
+	"errors"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // UserController handles HTTP requests related to users
 type UserController struct {
-	userService *service.UserService
+	userService        *service.UserService
+	sessionService     *service.SessionService
+	emailChangeService *service.EmailChangeService
 }
 
 // NewUserController creates a new UserController instance
-func NewUserController(userService *service.UserService) *UserController {
-	return &UserController{userService: userService}
+func NewUserController(userService *service.UserService, sessionService *service.SessionService, emailChangeService *service.EmailChangeService) *UserController {
+	return &UserController{userService: userService, sessionService: sessionService, emailChangeService: emailChangeService}
+}
+
+// clientInfo collects the device details a new session is recorded with
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
 }
 
 // SignUp handles user registration or creation
@@ -50,14 +60,15 @@ func (controller *UserController) SignUp(c *gin.Context) {
 
 // Login handles user authentication
 // @Summary      User Login
-// @Description  Authenticate a user and return a JWT token.
+// @Description  Authenticate a user and return a short-lived JWT access token and a refresh token. Users with two-factor authentication get "mfa_required" and an "mfa_token" for /users/login/mfa instead.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
 // @Param        login  body  models.Login  true  "Login credentials for authentication"
-// @Success      200    {object} map[string]interface{} "JWT token"
+// @Success      200    {object} map[string]interface{} "JWT token and refresh token"
 // @Failure      400    {object} map[string]string       "Invalid input"
 // @Failure      401    {object} map[string]string       "Invalid credentials"
+// @Failure      429    {object} map[string]string       "Too many attempts"
 // @Router       /users/login [post]
 func (controller *UserController) Login(c *gin.Context) {
 	var loginData models.Login
@@ -66,19 +77,104 @@ func (controller *UserController) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := controller.userService.Authenticate(loginData.Email, loginData.Password)
+	result, err := controller.userService.Authenticate(loginData.Email, loginData.Password, clientInfo(c))
 	if err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials", "details": err.Error()})
 		return
 	}
-	user, _ := controller.userService.GetByEmail(loginData.Email)
+	user, _ := controller.userService.GetOwnProfile(result.UserID)
+
+	respondLogin(c, result, user)
+}
+
+// Refresh rotates a refresh token and returns a new token pair
+// @Summary      Refresh Session
+// @Description  Exchange a refresh token for a new access token. The refresh token is rotated on every call and the old one stops working.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        refresh  body  models.RefreshSession  true  "Refresh token"
+// @Success      200      {object} models.AuthTokens       "New token pair"
+// @Failure      400      {object} map[string]string       "Invalid input"
+// @Failure      401      {object} map[string]string       "Invalid, expired or revoked refresh token"
+// @Router       /users/refresh [post]
+func (controller *UserController) Refresh(c *gin.Context) {
+	var refreshData models.RefreshSession
+	if err := c.ShouldBindJSON(&refreshData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	tokens, err := controller.sessionService.Refresh(refreshData.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the session the current access token belongs to
+// @Summary      Logout
+// @Description  Revoke the current session. Its access and refresh tokens stop working immediately.
+// @Tags         Users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object} map[string]string  "Logged out successfully"
+// @Failure      401  {object} map[string]string  "User ID not found"
+// @Failure      500  {object} map[string]string  "Failed to log out"
+// @Router       /users/logout [post]
+func (controller *UserController) Logout(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session ID not found in context"})
+		return
+	}
+
+	if err := controller.sessionService.Revoke(userID.(string), sessionID.(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll revokes every session of the current user
+// @Summary      Logout All Devices
+// @Description  Revoke all sessions of the current user, logging them out on every device.
+// @Tags         Users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object} map[string]string  "Logged out from all devices"
+// @Failure      401  {object} map[string]string  "User ID not found"
+// @Failure      500  {object} map[string]string  "Failed to log out"
+// @Router       /users/logout/all [post]
+func (controller *UserController) LogoutAll(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		return
+	}
+
+	if err := controller.sessionService.RevokeAll(userID.(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out", "details": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token, "expires_at": time.Now().Add(3 * time.Hour), "user": user})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
 }
 
 // GetDemographicInformation retrieves demographic information for a user
 // @Summary      Get User Demographics
-// @Description  Retrieve demographic information for a specific user by ID.
+// @Description  Retrieve demographic information for a specific user by ID. What is shown depends on the user's privacy settings and on who is asking; send a token to be recognized as a member.
 // @Tags         Users
 // @Produce      json
 // @Param        id  path  string  true  "User ID"
@@ -88,7 +184,7 @@ func (controller *UserController) Login(c *gin.Context) {
 func (controller *UserController) GetDemographicInformation(c *gin.Context) {
 	id := c.Param("id")
 
-	user, err := controller.userService.GetDemographicInformation(id)
+	user, err := controller.userService.GetDemographicInformation(id, viewerFromContext(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found", "details": err.Error()})
 		return
@@ -130,16 +226,18 @@ func (controller *UserController) UpdateUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": user})
 }
 
-// UpdateEmail handles updating a user's email address
+// UpdateEmail handles requesting a change of the user's email address
 // @Summary      Update User Email
-// @Description  Update user's email address with a new email.
+// @Description  Request a change of the user's email address. A confirmation link is sent to the new address and a cancellation link to the current one; the email only changes once the new address is confirmed.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
+// @Security     ApiKeyAuth
 // @Param        email  body  models.UpdateEmail  true  "New Email for update"
-// @Success      200    {object} map[string]string       "Email updated successfully"
+// @Success      202    {object} map[string]interface{}  "Confirmation email sent"
 // @Failure      400    {object} map[string]string       "Invalid input"
 // @Failure      401    {object} map[string]string       "User ID not found"
+// @Failure      409    {object} map[string]string       "Email already in use"
 // @Failure      500    {object} map[string]string       "Failed to update email"
 // @Router       /users/email [put]
 func (controller *UserController) UpdateEmail(c *gin.Context) {
@@ -155,46 +253,125 @@ func (controller *UserController) UpdateEmail(c *gin.Context) {
 		return
 	}
 
-	if err := controller.userService.UpdateEmail(userID.(string), emailData.NewEmail); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email", "details": err.Error()})
+	change, err := controller.emailChangeService.Request(userID.(string), emailData.NewEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidInput):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": "new email is the same as the current one"})
+		case errors.Is(err, service.ErrEmailAlreadyExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		case errors.Is(err, service.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Confirmation link sent to the new email address",
+		"new_email":  change.NewEmail,
+		"expires_at": change.ExpiresAt,
+	})
+}
+
+// ConfirmEmailChange completes an email change
+// @Summary      Confirm Email Change
+// @Description  Confirm a requested email change with the token from the link sent to the new address. The new address is marked as verified.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        token  body  models.EmailChangeToken  true  "Confirmation token"
+// @Success      200    {object} map[string]string  "Email updated successfully"
+// @Failure      400    {object} map[string]string  "Invalid token"
+// @Failure      404    {object} map[string]string  "No pending email change"
+// @Failure      409    {object} map[string]string  "Email already in use"
+// @Router       /users/email/confirm [post]
+func (controller *UserController) ConfirmEmailChange(c *gin.Context) {
+	var tokenData models.EmailChangeToken
+	if err := c.ShouldBindJSON(&tokenData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := controller.emailChangeService.Confirm(tokenData.Token); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token", "details": err.Error()})
+		case errors.Is(err, service.ErrEmailChangeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "No pending email change"})
+		case errors.Is(err, service.ErrEmailAlreadyExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email", "details": err.Error()})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Email updated successfully"})
 }
 
+// CancelEmailChange cancels an email change from the link sent to the current address
+// @Summary      Cancel Email Change
+// @Description  Cancel a requested email change with the token from the link sent to the current address. All sessions of the user are revoked.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        token  body  models.EmailChangeToken  true  "Cancellation token"
+// @Success      200    {object} map[string]string  "Email change cancelled"
+// @Failure      400    {object} map[string]string  "Invalid token"
+// @Router       /users/email/cancel [post]
+func (controller *UserController) CancelEmailChange(c *gin.Context) {
+	var tokenData models.EmailChangeToken
+	if err := c.ShouldBindJSON(&tokenData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := controller.emailChangeService.Cancel(tokenData.Token); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel email change", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email change cancelled, all devices have been logged out"})
+}
+
 // UpdatePassword handles updating a user's password using a reset token
 // @Summary      Update User Password
-// @Description  Update user's password with a new password using a reset token.
+// @Description  Update user's password with a new password using a reset token. The token works once; all sessions of the user are revoked.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
-// @Param        token  query  string  true  "JWT token for user authentication"
-// @Param        password  body  models.UpdatePassword  true  "New Password for update"
+// @Param        token     query string                 false "Reset token (deprecated, send it in the body)"
+// @Param        password  body  models.UpdatePassword  true  "Reset token and new password"
 // @Success      200      {object} map[string]string      "Password updated successfully"
 // @Failure      400      {object} map[string]string      "Invalid input"
-// @Failure      401      {object} map[string]string      "Invalid or expired token"
+// @Failure      401      {object} map[string]string      "Invalid, expired or already used token"
 // @Failure      500      {object} map[string]string      "Failed to update password"
 // @Router       /users/password [put]
 func (controller *UserController) UpdatePassword(c *gin.Context) {
-	token := c.Query("token")
 	var passwordData models.UpdatePassword
 
-	// Validate the JWT token using the service layer
-	userID, err := controller.userService.ValidateToken(token, "password_reset")
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Bind the new password
+	// Bind the reset token and the new password
 	if err := c.ShouldBindJSON(&passwordData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
 		return
 	}
+	token, ok := requestToken(c, passwordData.Token)
+	if !ok {
+		return
+	}
 
-	// Update the user's password
-	if err := controller.userService.UpdatePassword(userID, passwordData.NewPassword); err != nil {
+	// Redeem the token and update the user's password
+	if err := controller.userService.ResetPassword(token, passwordData.NewPassword); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token", "details": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password", "details": err.Error()})
 		return
 	}
@@ -204,13 +381,14 @@ func (controller *UserController) UpdatePassword(c *gin.Context) {
 
 // SendPasswordResetEmail handles sending a password reset email
 // @Summary      Send Password Reset Email
-// @Description  Sends a password reset email to the user with provided email.
+// @Description  Sends a password reset email to the user with provided email. The response is the same whether or not an account exists.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
 // @Param        email  body  models.SendPasswordResetEmail  true  "User Email for password reset"
 // @Success      200    {object} map[string]string          "Password reset email sent successfully"
 // @Failure      400    {object} map[string]string          "Invalid input"
+// @Failure      429    {object} map[string]string          "Too many attempts"
 // @Failure      500    {object} map[string]string          "Failed to send reset email"
 // @Router       /users/password/reset [post]
 func (controller *UserController) SendPasswordResetEmail(c *gin.Context) {
@@ -221,27 +399,41 @@ func (controller *UserController) SendPasswordResetEmail(c *gin.Context) {
 	}
 
 	// Send the password reset email
-	if err := controller.userService.SendPasswordResetEmail(emailData.Email); err != nil {
+	if err := controller.userService.SendPasswordResetEmail(emailData.Email, clientInfo(c)); err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset email", "details": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset email sent successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
 }
 
 // VerifyEmail handles verifying a user's email address
 // @Summary      Verify User Email
-// @Description  Verify the user's email address using a verification token.
+// @Description  Verify the user's email address using a verification token. The token works once.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
-// @Param        token  query  string  true  "Verification token"
+// @Param        token  query string              false  "Verification token (deprecated, send it in the body)"
+// @Param        body   body  models.VerifyEmail  false  "Verification token"
 // @Success      200    {object} map[string]string       "Email verified successfully"
 // @Failure      400    {object} map[string]string       "Invalid token"
 // @Failure      500    {object} map[string]string       "Failed to verify email"
 // @Router       /users/verify [post]
 func (controller *UserController) VerifyEmail(c *gin.Context) {
-	token := c.Query("token")
+	var tokenData models.VerifyEmail
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&tokenData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+			return
+		}
+	}
+	token, ok := requestToken(c, tokenData.Token)
+	if !ok {
+		return
+	}
 
 	// Verify the email using the token
 	if err := controller.userService.VerifyEmail(token); err != nil {
@@ -261,6 +453,7 @@ func (controller *UserController) VerifyEmail(c *gin.Context) {
 // @Param        email  body  models.SendEmailVerification  true  "User Email"
 // @Success      200    {object} map[string]string  "Verification email sent successfully"
 // @Failure      400    {object} map[string]string  "Invalid input"
+// @Failure      429    {object} map[string]string  "Too many attempts"
 // @Failure      500    {object} map[string]string  "Failed to send verification email"
 // @Router       /users/email/send-verification [post]
 func (controller *UserController) SendEmailVerification(c *gin.Context) {
@@ -270,22 +463,28 @@ func (controller *UserController) SendEmailVerification(c *gin.Context) {
 		return
 	}
 
-	if err := controller.userService.SendEmailVerification(emailData.Email); err != nil {
+	if err := controller.userService.SendEmailVerification(emailData.Email, clientInfo(c)); err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email", "details": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a verification link has been sent"})
 }
 
 // GetByName
 // @Summary      Get users by name prefix
-// @Description  Retrieves up to 10 users whose names start with the provided prefix.
+// @Description  Retrieves a page of the users whose names start with the provided prefix, newest first. Profiles hidden from the caller by their privacy settings are left out.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
-// @Param        name  query  string  true  "Name prefix to search for"
-// @Success      200   {array}  models.User   "List of users"
+// @Param        name           query  string  true   "Name prefix to search for"
+// @Param        limit          query  int     false  "Number of users per page (default 20, max 100)"
+// @Param        cursor         query  string  false  "next_cursor of the previous page"
+// @Param        include_total  query  bool    false  "Include the total number of matching users"
+// @Success      200   {object}  map[string]interface{}   "Page of users"
 // @Failure      400   {object}  map[string]string  "Bad Request"
 // @Failure      500   {object}  map[string]string  "Internal Server Error"
 // @Router       /users/search [get]
@@ -295,19 +494,23 @@ func (controller *UserController) GetByName(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Name parameter is required"})
 		return
 	}
+	params, ok := pageParams(c)
+	if !ok {
+		return
+	}
 
-	users, err := controller.userService.GetUsersByNamePrefix(name)
+	users, page, err := controller.userService.GetUsersByNamePrefix(name, viewerFromContext(c), params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, page.Response("users", users))
 }
 
 // GetUserByEmail godoc
 // @Summary Get a user by email
-// @Description Retrieves a user by their email address from query parameters
+// @Description Retrieves a user by their email address from query parameters. Users who hide their email or whose profile is hidden from the caller are not found.
 // @Tags Users
 // @Accept  json
 // @Produce  json
@@ -325,7 +528,7 @@ func (c *UserController) GetUserByEmail(ctx *gin.Context) {
 		return
 	}
 
-	user, err := c.userService.GetByEmail(email)
+	user, err := c.userService.GetByEmail(email, viewerFromContext(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
@@ -0,0 +1,360 @@
+package database
+
+import (
+	"backend/models"
+	"backend/provenance"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migrate creates or updates the tables that are managed by GORM
+func Migrate() {
+	if err := DB.AutoMigrate(
+		&models.SchemaMigration{},
+		&models.Session{},
+		&models.UserIdentity{},
+		&models.OAuthState{},
+		&models.UserMFA{},
+		&models.RecoveryCode{},
+		&models.LoginAttempt{},
+		&models.AttemptCounter{},
+		&models.OneTimeToken{},
+		&models.EmailChange{},
+		&models.APIKey{},
+		&models.AccountDeletion{},
+		&models.UserPrivacy{},
+		&models.Category{},
+		&models.CategoryName{},
+		&models.Media{},
+		&models.Order{},
+		&models.Payment{},
+		&models.LedgerEntry{},
+		&models.TransactionAmendment{},
+		&models.RevitalizationRequest{},
+		&models.RevitalizationBid{},
+	); err != nil {
+		log.Fatalf("Error migrating database: %v", err)
+	}
+
+	// The legacy tables come from the SQL dump and use types AutoMigrate cannot reconcile,
+	// so new columns on them are added one by one instead.
+	addColumnIfMissing(&models.User{}, "Role")
+	addColumnIfMissing(&models.User{}, "DeletedAt")
+	addColumnIfMissing(&models.Transaction{}, "Sequence")
+	addColumnIfMissing(&models.Transaction{}, "PrevHash")
+	addColumnIfMissing(&models.Transaction{}, "Hash")
+	addColumnIfMissing(&models.Transaction{}, "HashVersion")
+	addFulltextIndexIfMissing("products", "ft_products_name_description", "name", "description")
+
+	runOnce("seed_categories", seedCategories)
+
+	runOnce("backfill_transaction_media", backfillTransactionMedia)
+	runOnce("chain_legacy_transactions", chainLegacyTransactions)
+	runOnce("chain_amendments", chainAmendments)
+	addIndexIfMissing(&models.Transaction{}, "idx_transactions_chain")
+
+	log.Println("Database migrated successfully!")
+}
+
+// addColumnIfMissing adds the column backing the given model field when it does not exist yet
+func addColumnIfMissing(model interface{}, field string) {
+	migrator := DB.Migrator()
+	if migrator.HasColumn(model, field) {
+		return
+	}
+	if err := migrator.AddColumn(model, field); err != nil {
+		log.Fatalf("Error adding column %s: %v", field, err)
+	}
+}
+
+// addFulltextIndexIfMissing adds a FULLTEXT index over the given columns when the table does not have it yet
+func addFulltextIndexIfMissing(table, name string, columns ...string) {
+	if DB.Migrator().HasIndex(table, name) {
+		return
+	}
+	statement := fmt.Sprintf("ALTER TABLE `%s` ADD FULLTEXT INDEX `%s` (`%s`)", table, name, strings.Join(columns, "`, `"))
+	if err := DB.Exec(statement).Error; err != nil {
+		log.Fatalf("Error adding index %s: %v", name, err)
+	}
+}
+
+// addIndexIfMissing creates an index declared in the model's tags when it does not exist yet
+func addIndexIfMissing(model interface{}, name string) {
+	migrator := DB.Migrator()
+	if migrator.HasIndex(model, name) {
+		return
+	}
+	if err := migrator.CreateIndex(model, name); err != nil {
+		log.Fatalf("Error adding index %s: %v", name, err)
+	}
+}
+
+// runOnce applies a one-time data migration together with its marker in one transaction. The marker is
+// claimed first, so an instance booting concurrently waits for it and then skips the migration.
+func runOnce(name string, migrate func(tx *gorm.DB) error) {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		marker := models.SchemaMigration{Name: name, AppliedAt: time.Now().UTC()}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&marker)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return migrate(tx)
+	})
+	if err != nil {
+		log.Fatalf("Error running migration %s: %v", name, err)
+	}
+}
+
+// stockCategories are the categories the app shipped with. They seed the taxonomy of installs that have
+// no products to take it from.
+var stockCategories = []models.CategoryUsage{
+	{Category: "electronic", SubCategory: "laptop"},
+	{Category: "electronic", SubCategory: "mouse"},
+	{Category: "electronic", SubCategory: "tarayici"},
+	{Category: "electronic", SubCategory: "telefon"},
+	{Category: "ev_esyalari", SubCategory: "Sifonyer"},
+	{Category: "ev_esyalari", SubCategory: "TV_Koltuklari"},
+	{Category: "ev_esyalari", SubCategory: "TV_Unitesi"},
+	{Category: "ev_esyalari", SubCategory: "Vazo"},
+	{Category: "giyim", SubCategory: "esofman"},
+	{Category: "giyim", SubCategory: "gomlek"},
+	{Category: "giyim", SubCategory: "hirka_yelek"},
+	{Category: "giyim", SubCategory: "topuklu"},
+}
+
+// seedCategories builds the taxonomy from the free-text categories and subcategories products were listed
+// under, and moves the products onto the new slugs. Without it the taxonomy starts out empty and no product
+// can be created. A taxonomy an admin already started is left alone.
+func seedCategories(tx *gorm.DB) error {
+	var existing int64
+	if err := tx.Model(&models.Category{}).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	var usage []models.CategoryUsage
+	if err := tx.Model(&models.Product{}).
+		Select("COALESCE(category, '') AS category, COALESCE(sub_category, '') AS sub_category, COUNT(*) AS count").
+		Group("COALESCE(category, ''), COALESCE(sub_category, '')").
+		Scan(&usage).Error; err != nil {
+		return err
+	}
+	if len(usage) == 0 {
+		usage = stockCategories
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Category != usage[j].Category {
+			return usage[i].Category < usage[j].Category
+		}
+		return usage[i].SubCategory < usage[j].SubCategory
+	})
+
+	seed := categorySeed{bySlug: map[string]*models.Category{}}
+	var remaps []models.CategoryRemap
+	for _, u := range usage {
+		root := seed.add(u.Category, nil)
+		if root == nil {
+			continue
+		}
+		remap := models.CategoryRemap{FromCategory: u.Category, FromSubCategory: u.SubCategory, Category: root.Slug}
+		if u.SubCategory != "" {
+			sub := seed.add(u.SubCategory, root)
+			if sub == nil {
+				continue
+			}
+			remap.SubCategory = sub.Slug
+		}
+		remaps = append(remaps, remap)
+	}
+	if len(seed.categories) == 0 {
+		return nil
+	}
+
+	if err := tx.Create(&seed.categories).Error; err != nil {
+		return err
+	}
+	for _, remap := range remaps {
+		if err := tx.Model(&models.Product{}).
+			Where("COALESCE(category, '') = ? AND COALESCE(sub_category, '') = ?", remap.FromCategory, remap.FromSubCategory).
+			Updates(map[string]interface{}{"category": remap.Category, "sub_category": remap.SubCategory}).Error; err != nil {
+			return err
+		}
+	}
+	log.Printf("Seeded %d categories from the products' categories", len(seed.categories))
+	return nil
+}
+
+// categorySeed collects the categories seedCategories creates
+type categorySeed struct {
+	categories []*models.Category
+	bySlug     map[string]*models.Category
+}
+
+// add returns the category for a free-text value under parent (nil for a top-level category), creating it
+// on first use. Values that yield no slug, or whose slug is taken on the other level, get nil and their
+// products are left for the category migration endpoint.
+func (s *categorySeed) add(value string, parent *models.Category) *models.Category {
+	slug := categorySlug(value)
+	if slug == "" {
+		return nil
+	}
+	if existing := s.bySlug[slug]; existing != nil && parent != nil && (existing.ParentID == nil || *existing.ParentID != parent.ID) {
+		// Subcategory slugs are unique across parents, so a clashing one is qualified with its parent's
+		slug = categorySlug(parent.Slug + "-" + slug)
+	}
+	if existing := s.bySlug[slug]; existing != nil {
+		if parent == nil && existing.ParentID == nil || parent != nil && existing.ParentID != nil && *existing.ParentID == parent.ID {
+			return existing
+		}
+		return nil
+	}
+
+	name := strings.TrimSpace(strings.ReplaceAll(value, "_", " "))
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	category := &models.Category{
+		ID:        uuid.New(),
+		Slug:      slug,
+		Name:      name,
+		Position:  len(s.categories),
+		CreatedAt: time.Now().UTC(),
+	}
+	if parent != nil {
+		category.ParentID = &parent.ID
+	}
+	s.categories = append(s.categories, category)
+	s.bySlug[slug] = category
+	return category
+}
+
+var (
+	// slugFolding spells the Turkish letters found in category values in ASCII
+	slugFolding   = strings.NewReplacer("ı", "i", "İ", "i", "ş", "s", "Ş", "s", "ğ", "g", "Ğ", "g", "ü", "u", "Ü", "u", "ö", "o", "Ö", "o", "ç", "c", "Ç", "c")
+	slugSeparator = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// categorySlug turns a free-text category value into a slug of lowercase words joined by hyphens
+func categorySlug(value string) string {
+	slug := strings.Trim(slugSeparator.ReplaceAllString(strings.ToLower(slugFolding.Replace(value)), "-"), "-")
+	if len(slug) > 100 {
+		slug = strings.TrimRight(slug[:100], "-")
+	}
+	return slug
+}
+
+// backfillTransactionMedia copies the single image of transactions from before galleries existed into
+// the media table, so they show up in product galleries. It runs once: images deleted from a gallery
+// afterwards must not come back.
+func backfillTransactionMedia(tx *gorm.DB) error {
+	result := tx.Exec(`INSERT INTO media (id, product_id, transaction_id, uploaded_by, image_key, caption, position, is_cover, created_at)
+		SELECT UUID(), t.item_id, t.id, t.user_id, CONCAT('images/', t.image_url), '', 0, FALSE, t.created_at
+		FROM transactions t
+		WHERE t.image_url <> '' AND NOT EXISTS (SELECT 1 FROM media m WHERE m.transaction_id = t.id)`)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Backfilled %d transaction images into product galleries", result.RowsAffected)
+	}
+	return nil
+}
+
+// chainLegacyTransactions adds the transactions from before the provenance chain existed to their
+// product's chain, in the order they were recorded. Their history is vouched for from then on.
+//
+// It runs once, when the chain is introduced. Unchained rows turning up later can only have been written
+// past the application, so they must not be vouched for: products that already have a chain are refused
+// and their unchained rows are left out of it.
+func chainLegacyTransactions(tx *gorm.DB) error {
+	var itemIDs []uuid.UUID
+	if err := tx.Model(&models.Transaction{}).Where("hash = ''").Distinct().Pluck("item_id", &itemIDs).Error; err != nil {
+		return fmt.Errorf("listing unchained transactions: %w", err)
+	}
+
+	chained := 0
+	for _, itemID := range itemIDs {
+		var hasChain int64
+		if err := tx.Model(&models.Transaction{}).Where("item_id = ? AND hash <> ''", itemID).Count(&hasChain).Error; err != nil {
+			return err
+		}
+		if hasChain > 0 {
+			log.Printf("Not chaining the unchained transactions of product %s: it already has a provenance chain", itemID)
+			continue
+		}
+
+		var unchained []models.Transaction
+		if err := tx.Where("item_id = ? AND hash = ''", itemID).Order("created_at ASC, id ASC").Find(&unchained).Error; err != nil {
+			return err
+		}
+		var prev *models.Transaction
+		for i := range unchained {
+			t := &unchained[i]
+			provenance.Link(t, prev)
+			if err := tx.Model(&models.Transaction{}).Where("id = ?", t.ID).Updates(map[string]interface{}{
+				"created_at":   t.CreatedAt,
+				"sequence":     t.Sequence,
+				"prev_hash":    t.PrevHash,
+				"hash":         t.Hash,
+				"hash_version": t.HashVersion,
+			}).Error; err != nil {
+				return fmt.Errorf("chaining transactions of product %s: %w", itemID, err)
+			}
+			prev = t
+		}
+		chained++
+	}
+	if chained > 0 {
+		log.Printf("Chained the transactions of %d products", chained)
+	}
+	return nil
+}
+
+// chainAmendments appends the amendments made before they were recorded in the provenance chain to the
+// chain of their product, oldest first, and links each to its "amended" entry
+func chainAmendments(tx *gorm.DB) error {
+	var amendments []models.TransactionAmendment
+	if err := tx.Where("entry_id IS NULL").Order("created_at ASC, id ASC").Find(&amendments).Error; err != nil {
+		return fmt.Errorf("listing unchained amendments: %w", err)
+	}
+
+	for i := range amendments {
+		amendment := &amendments[i]
+		var transaction models.Transaction
+		if err := tx.First(&transaction, "id = ?", amendment.TransactionID).Error; err != nil {
+			return fmt.Errorf("fetching the transaction of amendment %s: %w", amendment.ID, err)
+		}
+
+		var newest []models.Transaction
+		if err := tx.Where("item_id = ?", transaction.ItemID).Order("sequence DESC").Limit(1).Find(&newest).Error; err != nil {
+			return err
+		}
+		var prev *models.Transaction
+		if len(newest) > 0 {
+			prev = &newest[0]
+		}
+
+		entry := provenance.AmendmentEntry(amendment, transaction.ItemID)
+		provenance.Link(entry, prev)
+		if err := tx.Create(entry).Error; err != nil {
+			return fmt.Errorf("chaining amendment %s: %w", amendment.ID, err)
+		}
+		if err := tx.Model(&models.TransactionAmendment{}).Where("id = ?", amendment.ID).Update("entry_id", entry.ID).Error; err != nil {
+			return fmt.Errorf("chaining amendment %s: %w", amendment.ID, err)
+		}
+	}
+	if len(amendments) > 0 {
+		log.Printf("Chained %d amendments", len(amendments))
+	}
+	return nil
+}
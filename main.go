@@ -33,6 +33,7 @@ func main() {
 	// Connect to the database
 	database.Connect()     // Call the Connect function
 	defer database.Close() // Ensure the database connection is closed when the function exits
+	database.Migrate()     // Create the tables managed by GORM
 
 	// Initialize Gin router
 	router := gin.Default()
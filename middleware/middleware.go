@@ -2,88 +2,193 @@
 package middleware
 
 import (
+	"backend/models"
+	"backend/token"
+	"errors"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
+// SessionChecker reports whether the session an access token was minted for is still active.
+// Logging out revokes the session, so its tokens are rejected before they expire.
+type SessionChecker interface {
+	IsSessionActive(sessionID string) (bool, error)
+}
+
+// APIKeyAuthenticator checks an API key and returns it with the role of its owner
+type APIKeyAuthenticator interface {
+	Authenticate(plainKey string) (*models.APIKey, models.Role, error)
+}
+
+// apiKeyMarker starts every API key, which tells them apart from JWTs in the Authorization header
+const apiKeyMarker = "eco_"
+
 // JWTAuth checks for a valid JWT and extracts the user ID from it.
-func JWTAuth() gin.HandlerFunc {
-	secretKey := os.Getenv("JWT_SECRET") // Retrieve the secret key from environment variables
-	if secretKey == "" {
-		panic("JWT_SECRET environment variable is not set") // Handle the case where the secret key is not set
-	}
+func JWTAuth(sessions SessionChecker) gin.HandlerFunc {
+	keys := token.Default() // Load the signing keys at startup so misconfiguration fails fast
 
 	return func(c *gin.Context) {
-		// Get the token from the Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing"})
+		if status, body := authenticate(c, keys, sessions); status != 0 {
+			c.JSON(status, body)
 			c.Abort()
 			return
 		}
 
-		// Split the token from "Bearer <token>"
-		tokenString := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token missing"})
+		// Proceed to the next middleware or handler
+		c.Next()
+	}
+}
+
+// OptionalJWTAuth identifies the user on public routes whose response depends on who is asking.
+// Requests without a valid token are let through anonymously instead of being rejected.
+func OptionalJWTAuth(sessions SessionChecker) gin.HandlerFunc {
+	keys := token.Default()
+
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			authenticate(c, keys, sessions)
+		}
+		c.Next()
+	}
+}
+
+// authenticate verifies the bearer token of the request and puts the user ID, session ID and role into
+// the context. On failure it leaves the context alone and returns the status and body to reject with.
+func authenticate(c *gin.Context, keys *token.KeySet, sessions SessionChecker) (int, gin.H) {
+	// Get the token from the Authorization header
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return http.StatusUnauthorized, gin.H{"error": "Authorization header missing"}
+	}
+
+	// Split the token from "Bearer <token>"
+	tokenString := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	if tokenString == "" {
+		return http.StatusUnauthorized, gin.H{"error": "Bearer token missing"}
+	}
+
+	// Verify the signature, expiry and purpose of the JWT
+	claims, err := keys.Parse(tokenString, "auth")
+	if err != nil {
+		switch {
+		case errors.Is(err, token.ErrExpired):
+			return http.StatusUnauthorized, gin.H{"error": "Token expired"}
+		case errors.Is(err, token.ErrPurposeInvalid):
+			return http.StatusUnauthorized, gin.H{"error": "Invalid token purpose"}
+		default:
+			return http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()}
+		}
+	}
+
+	// Extract the user ID
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return http.StatusUnauthorized, gin.H{"error": "User ID not found in token"}
+	}
+
+	// Check that the session behind the token has not been revoked
+	sessionID, ok := claims["sid"].(string)
+	if !ok {
+		return http.StatusUnauthorized, gin.H{"error": "Session ID not found in token"}
+	}
+	active, err := sessions.IsSessionActive(sessionID)
+	if err != nil {
+		return http.StatusInternalServerError, gin.H{"error": "Failed to check session", "details": err.Error()}
+	}
+	if !active {
+		return http.StatusUnauthorized, gin.H{"error": "Session has been revoked"}
+	}
+
+	// Tokens minted before roles existed carry no role claim; treat them as regular users
+	role, ok := claims["role"].(string)
+	if !ok || role == "" {
+		role = string(models.RoleUser)
+	}
+
+	// Set user ID, session ID and role in context (locals)
+	c.Set("user_id", userID)
+	c.Set("session_id", sessionID)
+	c.Set("role", models.Role(role))
+	return 0, nil
+}
+
+// RequireRole only lets requests through when the authenticated user has one of the given roles.
+// It must run after JWTAuth, which puts the role into the context.
+func RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("role")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found in context"})
 			c.Abort()
 			return
 		}
 
-		// Parse and validate the JWT
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Ensure the signing method is HMAC
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, http.ErrAbortHandler
+		role, _ := value.(models.Role)
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
 			}
-			return []byte(secretKey), nil
-		})
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
+
+// JWTOrAPIKeyAuth accepts either a Bearer JWT, handled by jwtAuth, or an API key sent in the
+// X-API-Key header or as "Bearer eco_...". API key requests act as the key's owner and are
+// limited to the key's scopes by RequireScope.
+func JWTOrAPIKeyAuth(jwtAuth gin.HandlerFunc, apiKeys APIKeyAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plainKey := strings.TrimSpace(c.GetHeader("X-API-Key"))
+		if plainKey == "" {
+			bearer := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+			if strings.HasPrefix(bearer, apiKeyMarker) {
+				plainKey = bearer
+			}
+		}
+		if plainKey == "" {
+			jwtAuth(c)
+			return
+		}
 
+		key, role, err := apiKeys.Authenticate(plainKey)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
 			c.Abort()
 			return
 		}
 
-		// Extract claims and get user ID
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			userID, ok := claims["user_id"].(string)
-			if !ok {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in token"})
-				c.Abort()
-				return
-			}
+		// Set user ID, role, key ID and scopes in context (locals)
+		c.Set("user_id", key.UserID.String())
+		c.Set("role", role)
+		c.Set("api_key_id", key.ID.String())
+		c.Set("scopes", key.Scopes)
 
-			// Check for expiration
-			expiresAt, ok := claims["expires_at"].(float64) // exp is a float64 (Unix time)
-			if ok && float64(time.Now().Unix()) > expiresAt {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
-				c.Abort()
-				return
-			}
+		c.Next()
+	}
+}
 
-			// Check the purpose of the token
-			purpose, ok := claims["purpose"].(string)
-			if !ok || purpose != "auth" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token purpose"})
-				c.Abort()
-				return
-			}
+// RequireScope only lets API key requests through when the key has the scope. Requests with a JWT
+// act with the user's full permissions and always pass. It must run after JWTOrAPIKeyAuth.
+func RequireScope(scope models.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, isAPIKey := c.Get("scopes")
+		if !isAPIKey {
+			c.Next()
+			return
+		}
 
-			// Set user ID in context (locals)
-			c.Set("user_id", userID)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		scopes, _ := value.(models.Scopes)
+		if !scopes.Has(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient scope", "details": "API key is missing scope " + string(scope)})
 			c.Abort()
 			return
 		}
 
-		// Proceed to the next middleware or handler
 		c.Next()
 	}
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountDeletionStatus is the state of a closed account
+type AccountDeletionStatus string
+
+const (
+	AccountDeletionPending   AccountDeletionStatus = "pending"   // Anonymized, personal data kept until the grace period ends
+	AccountDeletionCompleted AccountDeletionStatus = "completed" // Personal data has been purged
+)
+
+// DeletedUserName replaces the name of a closed account, so authored content no longer identifies its author
+const DeletedUserName = "Deleted user"
+
+// DeletedUser is what is shown of a closed account next to the content it still authors
+func DeletedUser(id uuid.UUID) User {
+	return User{ID: id, Name: DeletedUserName}
+}
+
+// AccountDeletion records that a user closed their account. The account is anonymized right away and
+// the remaining personal data is purged by a background job once PurgeAfter has passed.
+type AccountDeletion struct {
+	ID          uuid.UUID             `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID      uuid.UUID             `gorm:"type:char(36);not null;uniqueIndex" json:"user_id"`
+	Status      AccountDeletionStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	RequestedAt time.Time             `gorm:"not null" json:"requested_at"`
+	PurgeAfter  time.Time             `gorm:"not null;index" json:"purge_after"`
+	CompletedAt *time.Time            `json:"completed_at,omitempty"`
+}
+
+// DeleteAccount represents the data for closing the current user's account
+type DeleteAccount struct {
+	Password string `json:"password" binding:"required"` // Current password, to confirm the request
+}
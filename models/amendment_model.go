@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransactionAmendment corrects values of a posted transaction. The transaction itself is never changed;
+// its effective values are the original ones with every amendment applied in order. Each amendment is
+// also recorded by an "amended" entry in the product's provenance chain, which spells out the corrected
+// values, so an amendment altered or removed in the database no longer matches the chain.
+type TransactionAmendment struct {
+	ID            uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	TransactionID uuid.UUID  `gorm:"type:char(36);not null;index" json:"transaction_id"`
+	EntryID       *uuid.UUID `gorm:"type:char(36);index" json:"entry_id,omitempty"` // The "amended" entry recording it in the provenance chain
+	AuthorID      uuid.UUID  `gorm:"type:char(36);not null;index" json:"author_id"`
+	Reason        string     `gorm:"type:varchar(500);not null" json:"reason"`
+	Description   *string    `gorm:"type:text" json:"description,omitempty"`       // Corrected description, if it was corrected
+	ImageURL      *string    `gorm:"type:varchar(255)" json:"image_url,omitempty"` // Corrected photo, if it was corrected
+	Price         *float64   `json:"price,omitempty"`                              // Corrected price, if it was corrected
+	CreatedAt     time.Time  `gorm:"not null" json:"created_at"`
+}
+
+// TransactionValues are the values of a transaction that can be corrected
+type TransactionValues struct {
+	Description string   `json:"description"`
+	ImageURL    string   `json:"image_url"`
+	Price       *float64 `json:"price,omitempty"` // Transactions only carry a price once it was corrected
+}
+
+// AmendTransaction represents a correction of a transaction. At least one value must be corrected.
+type AmendTransaction struct {
+	Reason      string   `json:"reason" binding:"required,max=500"`
+	Description *string  `json:"description"`
+	ImageData   string   `json:"image_data"` // Base64 encoded replacement photo
+	Price       *float64 `json:"price" binding:"omitempty,gte=0"`
+}
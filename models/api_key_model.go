@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scope is a permission granted to an API key
+type Scope string
+
+const (
+	ScopeProductsRead         Scope = "products:read"
+	ScopeProductsWrite        Scope = "products:write"
+	ScopeRatingsWrite         Scope = "ratings:write"
+	ScopeRatingsExport        Scope = "ratings:export"
+	ScopeCommentsWrite        Scope = "comments:write"
+	ScopeTransactionsWrite    Scope = "transactions:write"
+	ScopeProfileWrite         Scope = "profile:write"
+	ScopeOrdersRead           Scope = "orders:read"
+	ScopeOrdersWrite          Scope = "orders:write"
+	ScopeRevitalizationsWrite Scope = "revitalizations:write"
+)
+
+// AllScopes lists every scope an API key can be given
+var AllScopes = []Scope{
+	ScopeProductsRead,
+	ScopeProductsWrite,
+	ScopeRatingsWrite,
+	ScopeRatingsExport,
+	ScopeCommentsWrite,
+	ScopeTransactionsWrite,
+	ScopeProfileWrite,
+	ScopeOrdersRead,
+	ScopeOrdersWrite,
+	ScopeRevitalizationsWrite,
+}
+
+// IsValid reports whether the scope is one of the known scopes
+func (s Scope) IsValid() bool {
+	for _, scope := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes is a list of scopes, stored as a comma-separated column
+type Scopes []Scope
+
+// Has reports whether the list contains the scope
+func (s Scopes) Has(scope Scope) bool {
+	for _, granted := range s {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements driver.Valuer
+func (s Scopes) Value() (driver.Value, error) {
+	parts := make([]string, len(s))
+	for i, scope := range s {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// Scan implements sql.Scanner
+func (s *Scopes) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	case nil:
+		*s = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Scopes", value)
+	}
+
+	*s = nil
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*s = append(*s, Scope(part))
+		}
+	}
+	return nil
+}
+
+// APIKey lets a machine client call the API on behalf of a user, limited to its scopes.
+// Only a hash of the key is stored; the prefix is kept in clear to look the key up.
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);not null;uniqueIndex" json:"prefix"`
+	KeyHash    string     `gorm:"type:char(64);not null" json:"-"` // SHA-256 of the full key
+	Scopes     Scopes     `gorm:"type:varchar(512);not null" json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"default:current_timestamp" json:"created_at"`
+}
+
+// CreateAPIKey represents the data for creating an API key
+type CreateAPIKey struct {
+	Name          string  `json:"name" binding:"required,max=100"`
+	Scopes        []Scope `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int     `json:"expires_in_days" binding:"min=0"` // 0 means the key does not expire
+}
+
+// CreatedAPIKey is returned once when a key is created; the plain key is never shown again
+type CreatedAPIKey struct {
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"api_key"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Category is a node of the managed product taxonomy. Top-level categories have no parent and may have
+// subcategories; products store the slugs of their category and subcategory.
+type Category struct {
+	ID        uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	ParentID  *uuid.UUID     `gorm:"type:char(36);index" json:"parent_id,omitempty"`
+	Slug      string         `gorm:"type:varchar(100);not null;uniqueIndex" json:"slug"` // Stable identifier stored on products
+	Name      string         `gorm:"type:varchar(100);not null" json:"name"`             // Name shown when no translation matches
+	Position  int            `gorm:"not null;default:0" json:"position"`                 // Sort order among siblings
+	Names     []CategoryName `gorm:"foreignKey:CategoryID;constraint:OnDelete:CASCADE" json:"names,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// CategoryName is the name of a category in one language
+type CategoryName struct {
+	CategoryID uuid.UUID `gorm:"type:char(36);primaryKey" json:"-"`
+	Locale     string    `gorm:"type:varchar(10);primaryKey" json:"locale"` // Language code, e.g. "en" or "tr"
+	Name       string    `gorm:"type:varchar(100);not null" json:"name"`
+}
+
+// CategoryNode is a category in the tree returned to clients, named in the requested language
+type CategoryNode struct {
+	ID           uuid.UUID      `json:"id"`
+	Slug         string         `json:"slug"`
+	Name         string         `json:"name"`
+	ProductCount int64          `json:"product_count"` // Listed products in the category, including its subcategories
+	Children     []CategoryNode `json:"children,omitempty"`
+}
+
+// CreateCategory represents the data for adding a category to the taxonomy
+type CreateCategory struct {
+	Slug       string            `json:"slug" binding:"required,max=100"`
+	Name       string            `json:"name" binding:"required,max=100"`
+	ParentSlug string            `json:"parent_slug"` // Empty for a top-level category
+	Position   int               `json:"position"`
+	Names      map[string]string `json:"names"` // Localized names keyed by language code
+}
+
+// CategoryUsage counts the products that use a category/subcategory pair
+type CategoryUsage struct {
+	Category    string `json:"category"`
+	SubCategory string `json:"sub_category"`
+	Count       int64  `json:"count"`
+}
+
+// CategoryMapping maps a free-text category value onto a canonical category. An empty FromSubCategory
+// matches every subcategory the value was used with.
+type CategoryMapping struct {
+	FromCategory    string `json:"from_category" binding:"required"`
+	FromSubCategory string `json:"from_sub_category"`
+	Category        string `json:"category" binding:"required"` // Slug of the canonical category
+	SubCategory     string `json:"sub_category"`                // Slug of the canonical subcategory, if any
+}
+
+// CategoryMigration represents a request to move existing products onto the taxonomy. Values not covered
+// by Mappings are matched against the category slugs and names automatically.
+type CategoryMigration struct {
+	Mappings []CategoryMapping `json:"mappings"`
+	DryRun   bool              `json:"dry_run"` // Only report what would change
+}
+
+// CategoryRemap is one free-text category/subcategory pair and the canonical pair it is moved to
+type CategoryRemap struct {
+	FromCategory    string `json:"from_category"`
+	FromSubCategory string `json:"from_sub_category"`
+	Category        string `json:"category,omitempty"`
+	SubCategory     string `json:"sub_category,omitempty"`
+	Products        int64  `json:"products"`
+}
+
+// CategoryMigrationReport lists what a category migration changed, or would change on a dry run
+type CategoryMigrationReport struct {
+	DryRun   bool            `json:"dry_run"`
+	Mapped   []CategoryRemap `json:"mapped"`
+	Unmapped []CategoryRemap `json:"unmapped"` // Values that match no category and need an explicit mapping
+	Updated  int64           `json:"updated"`  // Products changed
+}
@@ -17,6 +17,9 @@ type Comment struct {
 	CreatedAt time.Time `gorm:"default:current_timestamp" json:"created_at"`
 }
 
+// RedactedCommentContent replaces the text of comments by a purged account
+const RedactedCommentContent = "[This comment was removed because its author deleted their account]"
+
 // AddComment represents the structure to add a new comment to a product
 type AddComment struct {
 	ProductID string `gorm:"type:uuid;not null" json:"product_id"`
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChangeStatus is the state of a requested email change
+type EmailChangeStatus string
+
+const (
+	EmailChangePending   EmailChangeStatus = "pending"
+	EmailChangeConfirmed EmailChangeStatus = "confirmed"
+	EmailChangeCancelled EmailChangeStatus = "cancelled"
+	EmailChangeFailed    EmailChangeStatus = "failed" // The new address was taken by someone else before confirmation
+)
+
+// Purposes of the single-use tokens sent for an email change
+const (
+	TokenPurposeEmailChange       = "email_change"
+	TokenPurposeEmailChangeCancel = "email_change_cancel"
+)
+
+// EmailChange is a requested change of a user's email. The address is only swapped once
+// the link sent to the new address is confirmed.
+type EmailChange struct {
+	ID          uuid.UUID         `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID      uuid.UUID         `gorm:"type:char(36);not null;index" json:"user_id"`
+	OldEmail    string            `gorm:"type:varchar(255);not null" json:"old_email"`
+	NewEmail    string            `gorm:"type:varchar(255);not null;index" json:"new_email"`
+	Status      EmailChangeStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	ExpiresAt   time.Time         `gorm:"not null" json:"expires_at"`
+	CreatedAt   time.Time         `gorm:"default:current_timestamp" json:"created_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"` // When the change was confirmed, cancelled or failed
+}
+
+// EmailChangeToken represents the token from a confirmation or cancellation link
+type EmailChangeToken struct {
+	Token string `json:"token" binding:"required"`
+}
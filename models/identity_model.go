@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links a user to an account at an external OAuth2/OpenID Connect provider
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"type:char(36);not null;index" json:"user_id"`
+	Provider  string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_provider_subject" json:"subject"` // The provider's stable user ID ("sub" claim)
+	Email     string    `gorm:"type:varchar(255)" json:"email"`
+	CreatedAt time.Time `gorm:"default:current_timestamp" json:"created_at"`
+}
+
+// OAuthState holds the secrets of a social login that has been started but not completed yet
+type OAuthState struct {
+	State        string    `gorm:"type:varchar(64);primaryKey"`
+	Provider     string    `gorm:"type:varchar(50);not null"`
+	CodeVerifier string    `gorm:"type:varchar(128);not null"` // PKCE verifier, never leaves the server
+	Nonce        string    `gorm:"type:varchar(64);not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"default:current_timestamp"`
+}
+
+// OAuthCallback represents the data the frontend receives from the provider's redirect
+type OAuthCallback struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// ExternalIdentity is what a provider tells us about the user after a successful login
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AttemptAction names the endpoint a recorded attempt was made against
+type AttemptAction string
+
+const (
+	AttemptLogin             AttemptAction = "login"
+	AttemptMFA               AttemptAction = "mfa"
+	AttemptPasswordReset     AttemptAction = "password_reset"
+	AttemptEmailVerification AttemptAction = "email_verification"
+)
+
+// LoginAttempt is one entry of the login history. UserID is empty when the email did not match an account.
+type LoginAttempt struct {
+	ID        uuid.UUID     `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    *uuid.UUID    `gorm:"type:char(36);index" json:"user_id,omitempty"`
+	Email     string        `gorm:"type:varchar(255);index" json:"email"`
+	IPAddress string        `gorm:"type:varchar(45);index" json:"ip_address"`
+	UserAgent string        `gorm:"type:varchar(255)" json:"user_agent"`
+	Action    AttemptAction `gorm:"type:varchar(32);not null" json:"action"`
+	Success   bool          `gorm:"not null" json:"success"`
+	Reason    string        `gorm:"type:varchar(64)" json:"reason,omitempty"` // Why the attempt failed, e.g. "invalid_credentials" or "locked"
+	CreatedAt time.Time     `gorm:"default:current_timestamp;index" json:"created_at"`
+}
+
+// AttemptCounter tracks recent failures for one throttling key, e.g. an email address or an IP
+type AttemptCounter struct {
+	Key           string     `gorm:"type:varchar(191);primaryKey" json:"key"` // "<scope>:<value>", e.g. "login-account:jane@example.com"
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until,omitempty"`              // Further attempts are rejected until then
+	Locked        bool       `gorm:"not null;default:false" json:"locked"` // The block is a lockout rather than a short delay
+}
+
+// LockoutStatus is the lockout state of an account as shown to admins
+type LockoutStatus struct {
+	Locked        bool       `json:"locked"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	Failures      int        `json:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Media is an image in a product's gallery. Images uploaded with a transaction also record it, so each
+// lifecycle step can show the photos taken at that point.
+type Media struct {
+	ID            uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	ProductID     uuid.UUID  `gorm:"type:char(36);not null;index:idx_media_product_position,priority:1" json:"product_id"`
+	TransactionID *uuid.UUID `gorm:"type:char(36);index" json:"transaction_id,omitempty"`
+	UploadedBy    uuid.UUID  `gorm:"type:char(36);not null;index" json:"uploaded_by"`
+	ImageKey      string     `gorm:"type:varchar(255);not null" json:"-"` // S3 object key
+	URL           string     `gorm:"-" json:"url"`                        // Pre-signed URL, filled in when the gallery is read
+	Caption       string     `gorm:"type:varchar(500)" json:"caption"`
+	Position      int        `gorm:"not null;default:0;index:idx_media_product_position,priority:2" json:"position"`
+	IsCover       bool       `gorm:"not null;default:false" json:"is_cover"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TableName keeps GORM from pluralizing the table name to "medias"
+func (Media) TableName() string {
+	return "media"
+}
+
+// MediaUpload is one image sent with a product, a transaction or a gallery upload
+type MediaUpload struct {
+	ImageData string `json:"image_data" binding:"required"` // Base64 encoded image data
+	Caption   string `json:"caption" binding:"max=500"`
+}
+
+// AddMedia represents images added to a product's gallery, optionally attached to one of its transactions
+type AddMedia struct {
+	TransactionID *uuid.UUID    `json:"transaction_id"`
+	Images        []MediaUpload `json:"images" binding:"required,min=1,max=20,dive"`
+}
+
+// UpdateMedia represents a change to one gallery image; omitted fields are left unchanged
+type UpdateMedia struct {
+	Caption *string `json:"caption" binding:"omitempty,max=500"`
+	IsCover *bool   `json:"is_cover"` // Only true is accepted; make another image the cover to change it
+}
+
+// ReorderMedia lists the IDs of a product's gallery images in their new order
+type ReorderMedia struct {
+	MediaIDs []uuid.UUID `json:"media_ids" binding:"required,min=1"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserMFA holds a user's TOTP secret. The secret is pending until the user proves they can generate codes.
+type UserMFA struct {
+	UserID       uuid.UUID  `gorm:"type:char(36);primaryKey" json:"user_id"`
+	Secret       string     `gorm:"type:varchar(64);not null" json:"-"` // Base32 TOTP secret
+	Enabled      bool       `gorm:"not null;default:false" json:"enabled"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"` // Last accepted TOTP time step, so a code cannot be replayed
+	CreatedAt    time.Time  `gorm:"default:current_timestamp" json:"created_at"`
+}
+
+// RecoveryCode is a hashed single-use code that replaces a TOTP code when the device is lost
+type RecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:char(64);not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"default:current_timestamp" json:"created_at"`
+}
+
+// MFAEnrollment is returned when a user starts enrolling an authenticator app
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFACode represents a 6-digit code from the user's authenticator app
+type MFACode struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// MFADisable represents the second factor required to switch off two-factor authentication
+type MFADisable struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFALogin represents the second login step for users with two-factor authentication
+type MFALogin struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// LoginResult is the outcome of the first login step: either a session, or a challenge for the second factor
+type LoginResult struct {
+	UserID       string
+	Tokens       *AuthTokens
+	MFARequired  bool
+	MFAToken     string
+	MFAExpiresAt time.Time
+}
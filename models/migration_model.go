@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// SchemaMigration marks a one-time data migration as applied, so it does not run again on later boots
+type SchemaMigration struct {
+	Name      string    `gorm:"type:varchar(100);primaryKey" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purposes of single-use tokens; they match the "purpose" claim of the JWT
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// OneTimeToken records a single-use JWT by its ID so it can only be redeemed once and can be revoked
+type OneTimeToken struct {
+	JTI        string     `gorm:"column:jti;type:char(36);primaryKey" json:"jti"`
+	UserID     uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	Purpose    string     `gorm:"type:varchar(32);not null;index" json:"purpose"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"` // Set when the token is redeemed or revoked
+	CreatedAt  time.Time  `gorm:"default:current_timestamp" json:"created_at"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderStatus is the state of a buyer's order on a product
+type OrderStatus string
+
+const (
+	OrderPending    OrderStatus = "pending"     // Placed by the buyer, waiting for the seller
+	OrderAccepted   OrderStatus = "accepted"    // The seller agreed to sell, waiting for payment
+	OrderPaid       OrderStatus = "paid"        // The buyer paid
+	OrderHandedOver OrderStatus = "handed_over" // The seller handed the product over
+	OrderCancelling OrderStatus = "cancelling"  // A moderator is cancelling the paid order; the refund is under way
+	OrderCompleted  OrderStatus = "completed"   // The buyer confirmed receipt; the product is theirs
+	OrderDeclined   OrderStatus = "declined"    // The seller refused the order
+	OrderCancelled  OrderStatus = "cancelled"   // Called off by the buyer, the seller or a moderator
+	OrderExpired    OrderStatus = "expired"     // The reservation ran out before the order was paid
+)
+
+// IsValid reports whether s is one of the known order statuses
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderPending, OrderAccepted, OrderPaid, OrderHandedOver, OrderCancelling, OrderCompleted, OrderDeclined, OrderCancelled, OrderExpired:
+		return true
+	}
+	return false
+}
+
+// IsActive reports whether an order in this status still holds its product's reservation
+func (s OrderStatus) IsActive() bool {
+	return s == OrderPending || s == OrderAccepted || s == OrderPaid || s == OrderHandedOver || s == OrderCancelling
+}
+
+// ActiveOrderStatuses lists the statuses of orders that hold a reservation
+var ActiveOrderStatuses = []OrderStatus{OrderPending, OrderAccepted, OrderPaid, OrderHandedOver, OrderCancelling}
+
+// OrderAction is a step a party takes on an order
+type OrderAction string
+
+const (
+	OrderActionAccept   OrderAction = "accept"
+	OrderActionDecline  OrderAction = "decline"
+	OrderActionPay      OrderAction = "pay"
+	OrderActionHandOver OrderAction = "hand_over"
+	OrderActionComplete OrderAction = "complete"
+	OrderActionCancel   OrderAction = "cancel"
+	OrderActionExpire   OrderAction = "expire" // Taken by the reservation timeout, not by a user
+)
+
+// Order is a buyer's order on a product. While it is active the product is reserved for the buyer;
+// completing it sells the product and makes the buyer its owner.
+type Order struct {
+	ID            uuid.UUID     `gorm:"type:char(36);primaryKey" json:"id"`
+	ProductID     uuid.UUID     `gorm:"type:char(36);not null;index" json:"product_id"`
+	BuyerID       uuid.UUID     `gorm:"type:char(36);not null;index" json:"buyer_id"`
+	SellerID      uuid.UUID     `gorm:"type:char(36);not null;index" json:"seller_id"`
+	Price         float64       `gorm:"not null" json:"price"` // The product's price when the order was placed
+	Status        OrderStatus   `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	ProductStatus ProductStatus `gorm:"type:varchar(20);not null" json:"-"` // The product's status before the reservation, restored when it ends
+	Note          string        `gorm:"type:varchar(1000)" json:"note,omitempty"`
+	Reason        string        `gorm:"type:varchar(500)" json:"reason,omitempty"` // Why the order was declined or cancelled
+	ReservedUntil *time.Time    `gorm:"index" json:"reserved_until,omitempty"`     // Unpaid orders expire then; nil once paid
+	CreatedAt     time.Time     `gorm:"not null;index" json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	CompletedAt   *time.Time    `json:"completed_at,omitempty"`
+}
+
+// PlaceOrder represents a buyer's order on a product
+type PlaceOrder struct {
+	ProductID uuid.UUID `json:"product_id" binding:"required"`
+	Note      string    `json:"note" binding:"max=1000"` // Message to the seller
+}
+
+// OrderStep represents the optional details of a step taken on an order
+type OrderStep struct {
+	Reason string `json:"reason" binding:"max=500"` // Why the order is declined or cancelled
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PaymentStatus is where a buyer's money for an order is
+type PaymentStatus string
+
+const (
+	PaymentHeld     PaymentStatus = "held"     // Captured and kept in escrow until the buyer confirms receipt
+	PaymentReleased PaymentStatus = "released" // Paid out to the seller, minus the platform fee
+	PaymentRefunded PaymentStatus = "refunded" // Paid back to the buyer
+)
+
+// Payment is the money a buyer paid for an order. Amounts are in the currency's minor unit, e.g. cents.
+type Payment struct {
+	ID              uuid.UUID     `gorm:"type:char(36);primaryKey" json:"id"`
+	OrderID         uuid.UUID     `gorm:"type:char(36);not null;uniqueIndex" json:"order_id"`
+	BuyerID         uuid.UUID     `gorm:"type:char(36);not null;index" json:"buyer_id"`
+	SellerID        uuid.UUID     `gorm:"type:char(36);not null;index" json:"seller_id"`
+	Provider        string        `gorm:"type:varchar(30);not null" json:"provider"`
+	AuthorizationID string        `gorm:"type:varchar(100);not null" json:"-"`
+	CaptureID       string        `gorm:"type:varchar(100);not null;index" json:"-"` // Provider webhooks refer to the payment by it
+	RefundID        string        `gorm:"type:varchar(100)" json:"-"`
+	Amount          int64         `gorm:"not null" json:"amount"`
+	Fee             int64         `gorm:"not null" json:"fee"` // The platform's share, fixed when the buyer pays
+	Currency        string        `gorm:"type:char(3);not null" json:"currency"`
+	Status          PaymentStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	CreatedAt       time.Time     `gorm:"not null" json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// LedgerAccount is an account of the double-entry ledger money moves between
+type LedgerAccount string
+
+const (
+	AccountProvider      LedgerAccount = "provider"       // Funds the platform holds at the payment provider
+	AccountEscrow        LedgerAccount = "escrow"         // Buyers' funds awaiting the outcome of their order
+	AccountSellerPayable LedgerAccount = "seller_payable" // Owed to a seller; entries carry the seller's ID
+	AccountPlatformFees  LedgerAccount = "platform_fees"  // The platform's earnings
+)
+
+// LedgerEntry is one side of a money movement. The entries sharing a TransferID always balance:
+// their debits add up to their credits. Entries are never updated or deleted.
+type LedgerEntry struct {
+	ID         uuid.UUID     `gorm:"type:char(36);primaryKey" json:"id"`
+	TransferID uuid.UUID     `gorm:"type:char(36);not null;index" json:"transfer_id"`
+	PaymentID  uuid.UUID     `gorm:"type:char(36);not null;index" json:"payment_id"`
+	Account    LedgerAccount `gorm:"type:varchar(30);not null;index:idx_ledger_account" json:"account"`
+	UserID     *uuid.UUID    `gorm:"type:char(36);index:idx_ledger_account" json:"user_id,omitempty"`
+	Debit      int64         `gorm:"not null;default:0" json:"debit"`
+	Credit     int64         `gorm:"not null;default:0" json:"credit"`
+	Memo       string        `gorm:"type:varchar(255)" json:"memo"`
+	CreatedAt  time.Time     `gorm:"not null" json:"created_at"`
+}
+
+// PayOrder represents the buyer's payment for an accepted order
+type PayOrder struct {
+	Source string `json:"source" binding:"required"` // Payment method token from the payment provider's client SDK
+}
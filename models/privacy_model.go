@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProfileVisibility controls who may see a user's public profile
+type ProfileVisibility string
+
+const (
+	VisibilityPublic  ProfileVisibility = "public"  // Everyone, including visitors who are not logged in
+	VisibilityMembers ProfileVisibility = "members" // Only logged-in users
+	VisibilityPrivate ProfileVisibility = "private" // Only the user themselves and staff
+)
+
+// IsValid reports whether v is one of the known visibilities
+func (v ProfileVisibility) IsValid() bool {
+	switch v {
+	case VisibilityPublic, VisibilityMembers, VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// PrivateUserName replaces the name of a user whose profile the viewer may not see
+const PrivateUserName = "Private user"
+
+// UserPrivacy holds a user's privacy settings. Users without a row get DefaultPrivacy.
+type UserPrivacy struct {
+	UserID       uuid.UUID         `gorm:"type:char(36);primaryKey" json:"user_id"`
+	Visibility   ProfileVisibility `gorm:"type:varchar(20);not null;default:'public'" json:"visibility"`
+	HideEmail    bool              `gorm:"not null;default:false" json:"hide_email"`    // Don't show the masked email, nor find the profile by email
+	HideActivity bool              `gorm:"not null;default:false" json:"hide_activity"` // Don't show which products the user rated
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// DefaultPrivacy returns the settings of a user who never changed them
+func DefaultPrivacy(userID uuid.UUID) *UserPrivacy {
+	return &UserPrivacy{UserID: userID, Visibility: VisibilityPublic}
+}
+
+// UpdatePrivacy represents the data for changing privacy settings; omitted fields are left unchanged
+type UpdatePrivacy struct {
+	Visibility   *ProfileVisibility `json:"visibility"`
+	HideEmail    *bool              `json:"hide_email"`
+	HideActivity *bool              `json:"hide_activity"`
+}
@@ -13,9 +13,20 @@ const (
 	StatusAvailable         ProductStatus = "available"
 	StatusRestored          ProductStatus = "restored"
 	StatusRestoredAvailable ProductStatus = "restoredAvailable"
+	StatusReserved          ProductStatus = "reserved" // Held for a buyer while their order is open
 	StatusSold              ProductStatus = "sold"
+	StatusWithdrawn         ProductStatus = "withdrawn" // Pulled down by the seller or a moderator; kept for its history
 )
 
+// IsValid reports whether s is one of the known product statuses
+func (s ProductStatus) IsValid() bool {
+	switch s {
+	case StatusAvailable, StatusRestored, StatusRestoredAvailable, StatusReserved, StatusSold, StatusWithdrawn:
+		return true
+	}
+	return false
+}
+
 // TransactionAction defines the possible actions for a transaction.
 type TransactionAction string
 
@@ -24,8 +35,23 @@ const (
 	SubmittedRevitalized TransactionAction = "submittedRevitalized"
 	Revitalized          TransactionAction = "revitalized"
 	Sold                 TransactionAction = "sold"
+	Updated              TransactionAction = "updated"    // The listing was edited
+	Withdrawn            TransactionAction = "withdrawn"  // The listing was pulled down
+	Reserved             TransactionAction = "reserved"   // A buyer placed an order
+	Released             TransactionAction = "released"   // The order ended without a sale
+	Overridden           TransactionAction = "overridden" // A moderator set the status outside the lifecycle
+	Amended              TransactionAction = "amended"    // An earlier transaction was corrected
 )
 
+// IsValid reports whether a is one of the known transaction actions
+func (a TransactionAction) IsValid() bool {
+	switch a {
+	case Submitted, SubmittedRevitalized, Revitalized, Sold, Updated, Withdrawn, Reserved, Released, Overridden, Amended:
+		return true
+	}
+	return false
+}
+
 type Product struct {
 	ID          uuid.UUID     `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primary_key"` // Unique identifier for the product
 	UserID      uuid.UUID     `json:"user_id" gorm:"type:uuid"`                                   // ID of the user who owns the product
@@ -33,7 +59,7 @@ type Product struct {
 	Description string        `json:"description"`                                                // Description of the product
 	Price       float64       `json:"price"`                                                      // Price of the product
 	SubCategory string        `json:"sub_category"`                                               // Subcategory of the product
-	Status      ProductStatus `json:"status" gorm:"type:varchar(20);default:'uncompleted'"`       // Status of the product (uses varchar instead of enum for MySQL)
+	Status      ProductStatus `json:"status" gorm:"type:varchar(20);default:'available'"`         // Status of the product (uses varchar instead of enum for MySQL)
 	Category    string        `json:"category"`                                                   // Category of the product
 	CreatedAt   time.Time     `json:"created_at" gorm:"autoCreateTime"`                           // Timestamp when the product was created
 }
@@ -53,46 +79,63 @@ type ProductResponse struct {
 	RatingAverage float64       `json:"rating_average"`                   // Product rating average
 	Category      string        `json:"category"`                         // Category of the product
 	CreatedAt     time.Time     `json:"created_at" gorm:"autoCreateTime"` // Timestamp when the product was created
+	Gallery       []Media       `json:"gallery"`                          // Product images in display order
 }
 
 // ProductRequest is used when creating a new product, without including transactions.
 type ProductRequest struct {
-	UserID      uuid.UUID     `json:"user_id"`             // ID of the user creating the product
-	Name        string        `json:"name"`                // Name of the product
-	Description string        `json:"description"`         // Description of the product
-	Price       float64       `json:"price"`               // Price of the product
-	SubCategory string        `json:"sub_category"`        // Subcategory of the product
-	Category    string        `json:"category"`            // Category of the product
-	Status      ProductStatus `json:"status,omitempty"`    // Status of the product (optional during request)
-	ImageData   string        `gorm:"-" json:"image_data"` // Base64 encoded image data for the transaction
+	UserID      uuid.UUID     `json:"user_id"`                               // ID of the user creating the product
+	Name        string        `json:"name"`                                  // Name of the product
+	Description string        `json:"description"`                           // Description of the product
+	Price       float64       `json:"price"`                                 // Price of the product
+	SubCategory string        `json:"sub_category"`                          // Slug of a subcategory of Category (optional)
+	Category    string        `json:"category"`                              // Slug of a top-level category
+	Status      ProductStatus `json:"status,omitempty"`                      // Status of the product (optional during request)
+	ImageData   string        `gorm:"-" json:"image_data"`                   // Base64 encoded image data for the transaction
+	Images      []MediaUpload `gorm:"-" json:"images" binding:"max=20,dive"` // Further images for the gallery, in order
 }
 
 // Transaction defines the structure for a transaction involving a product.
 type Transaction struct {
-	ID          uuid.UUID         `gorm:"type:uuid;primaryKey;unique" json:"id"`       // Primary key, unique identifier for each transaction
-	ItemID      uuid.UUID         `gorm:"type:uuid;not null" json:"item_id"`           // Reference to the product involved in the transaction
-	UserID      uuid.UUID         `gorm:"type:uuid;not null" json:"user_id"`           // Reference to the user performing the transaction
-	Description string            `gorm:"type:text" json:"description"`                // Description of the transaction
-	Action      TransactionAction `gorm:"type:varchar(20);not null" json:"action"`     // Action type of the transaction
-	ImageURL    string            `gorm:"type:varchar(255)" json:"image_url"`          // URL of the transaction image
-	CreatedAt   time.Time         `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"` // Transaction timestamp
+	ID          uuid.UUID              `gorm:"type:uuid;primaryKey;unique" json:"id"`                                            // Primary key, unique identifier for each transaction
+	ItemID      uuid.UUID              `gorm:"type:uuid;not null;uniqueIndex:idx_transactions_chain,priority:1" json:"item_id"`  // Reference to the product involved in the transaction
+	UserID      uuid.UUID              `gorm:"type:uuid;not null" json:"user_id"`                                                // Reference to the user performing the transaction
+	Description string                 `gorm:"type:text" json:"description"`                                                     // Description of the transaction
+	Action      TransactionAction      `gorm:"type:varchar(20);not null" json:"action"`                                          // Action type of the transaction
+	ImageURL    string                 `gorm:"type:varchar(255)" json:"image_url"`                                               // URL of the transaction image
+	CreatedAt   time.Time              `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`                                      // Transaction timestamp
+	Sequence    int                    `gorm:"not null;default:0;uniqueIndex:idx_transactions_chain,priority:2" json:"sequence"` // Position in the product's provenance chain, from 1
+	PrevHash    string                 `gorm:"type:char(64);not null;default:''" json:"prev_hash"`                               // Hash of the entry before it; empty for the first
+	Hash        string                 `gorm:"type:char(64);not null;default:''" json:"hash"`                                    // SHA-256 of the entry's content and PrevHash
+	HashVersion string                 `gorm:"type:varchar(4);not null;default:'v1'" json:"hash_version"`                        // Format Hash was computed in
+	Images      []Media                `gorm:"-" json:"images,omitempty"`                                                        // Gallery images uploaded with the transaction
+	Price       *float64               `gorm:"-" json:"price,omitempty"`                                                         // Corrected price, when an amendment set one
+	Original    *TransactionValues     `gorm:"-" json:"original,omitempty"`                                                      // The values as posted, when they were amended
+	Amendments  []TransactionAmendment `gorm:"-" json:"amendments,omitempty"`                                                    // Corrections, oldest first
+}
+
+// TransactionFilter narrows down a list of transactions; fields left empty do not filter
+type TransactionFilter struct {
+	ProductID *uuid.UUID
+	UserID    *uuid.UUID
+	Actions   []TransactionAction
+	From      *time.Time // Inclusive
+	To        *time.Time // Inclusive
 }
 
-// TransactionRequest defines the fields for creating a transaction with optional image data
-type TransactionRequest struct {
-	ItemID      uuid.UUID         `gorm:"type:uuid;not null" json:"item_id"`       // Reference to the product involved in the transaction
-	UserID      uuid.UUID         `gorm:"type:uuid;not null" json:"user_id"`       // Reference to the user performing the transaction
-	Description string            `gorm:"type:text" json:"description"`            // Description of the transaction
-	Action      TransactionAction `gorm:"type:varchar(20);not null" json:"action"` // Action type of the transaction
-	ImageData   string            `gorm:"-" json:"image_data"`                     // Base64 encoded image data for the transaction
+// TransactionEntry is a transaction with a summary of the user who recorded it, as history lists show it
+type TransactionEntry struct {
+	Transaction
+	User UserSummary `json:"user"`
 }
 
 // AddTransactionRequest is used to add a transaction with optional image data
 type AddTransactionRequest struct {
-	Description string            `gorm:"type:text" json:"description"`            // Description of the transaction
-	Action      TransactionAction `gorm:"type:varchar(20);not null" json:"action"` // Action type of the transaction
-	ImageData   string            `gorm:"-" json:"image_data"`                     // Base64 encoded image data for the transaction
-	Price       float64           `gorm:"type:float64" json:"price"`               // Price of the transaction
+	Description string            `gorm:"type:text" json:"description"`              // Description of the transaction
+	Action      TransactionAction `gorm:"type:varchar(20);not null" json:"action"`   // Action type of the transaction
+	ImageData   string            `gorm:"-" json:"image_data"`                       // Base64 encoded image data for the transaction
+	Images      []MediaUpload     `gorm:"-" json:"images" binding:"max=20,dive"`     // Further images for the gallery, in order
+	Price       float64           `gorm:"type:float64" json:"price" binding:"gte=0"` // New price of the product; 0 keeps the current one
 }
 
 type DetailedProductResponse struct {
@@ -109,13 +152,40 @@ type DetailedProductResponse struct {
 	RatingAverage float64               `json:"rating_average"`                   // Product rating average
 	Category      string                `json:"category"`                         // Category of the product
 	CreatedAt     time.Time             `json:"created_at" gorm:"autoCreateTime"` // Timestamp when the product was created
+	Gallery       []Media               `json:"gallery"`                          // Product images in display order
 }
 
 type DetailedTransaction struct {
-	ID          uuid.UUID         `gorm:"type:uuid;primaryKey;unique" json:"id"` // Primary key, unique identifier for each transaction
-	ItemID      uuid.UUID         `gorm:"type:uuid;not null" json:"item_id"`     // Reference to the product involved in the transaction
-	User        User              `gorm:"foreignKey:UserID" json:"user"`
-	Description string            `gorm:"type:text" json:"description"` // Description of the transaction
-	Action      TransactionAction `gorm:"type:varchar(20);not null" json:"action"`
-	ImageURL    string            `gorm:"type:varchar(255)" json:"image_url"` // URL of the transaction image
+	ID          uuid.UUID              `gorm:"type:uuid;primaryKey;unique" json:"id"` // Primary key, unique identifier for each transaction
+	ItemID      uuid.UUID              `gorm:"type:uuid;not null" json:"item_id"`     // Reference to the product involved in the transaction
+	User        User                   `gorm:"foreignKey:UserID" json:"user"`
+	Description string                 `gorm:"type:text" json:"description"` // Description of the transaction
+	Action      TransactionAction      `gorm:"type:varchar(20);not null" json:"action"`
+	ImageURL    string                 `gorm:"type:varchar(255)" json:"image_url"` // URL of the transaction image
+	Images      []Media                `json:"images,omitempty"`                   // Gallery images uploaded with the transaction
+	Price       *float64               `json:"price,omitempty"`                    // Corrected price, when an amendment set one
+	Original    *TransactionValues     `json:"original,omitempty"`                 // The values as posted, when they were amended
+	Amendments  []TransactionAmendment `json:"amendments,omitempty"`               // Corrections, oldest first
+}
+
+// UpdateProductStatus represents the data for changing a product's status
+type UpdateProductStatus struct {
+	Status ProductStatus `json:"status" binding:"required"`
+	Reason string        `json:"reason" binding:"max=500"` // Why the status is overridden, recorded in the product's history
+}
+
+// UpdateProduct represents a partial update of a listing; omitted fields are left unchanged
+type UpdateProduct struct {
+	Name        *string       `json:"name" binding:"omitempty,min=1,max=255"`
+	Description *string       `json:"description" binding:"omitempty,max=5000"`
+	Price       *float64      `json:"price" binding:"omitempty,gte=0"`
+	Category    *string       `json:"category" binding:"omitempty,min=1,max=100"`
+	SubCategory *string       `json:"sub_category" binding:"omitempty,min=1,max=100"`
+	ImageData   string        `json:"image_data"`                   // Base64 encoded image recorded with the update
+	Images      []MediaUpload `json:"images" binding:"max=20,dive"` // Further images added to the gallery
+}
+
+// WithdrawProduct represents the optional reason given when pulling down a listing
+type WithdrawProduct struct {
+	Reason string `json:"reason" binding:"max=500"`
 }
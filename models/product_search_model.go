@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ProductSort is the order of product search results
+type ProductSort string
+
+const (
+	SortRelevance ProductSort = "relevance" // Best text match first; newest first without a text query
+	SortPriceAsc  ProductSort = "price_asc"
+	SortPriceDesc ProductSort = "price_desc"
+	SortNewest    ProductSort = "newest"
+	SortRating    ProductSort = "rating" // Highest average rating first
+)
+
+// IsValid reports whether s is one of the known sort orders
+func (s ProductSort) IsValid() bool {
+	switch s {
+	case SortRelevance, SortPriceAsc, SortPriceDesc, SortNewest, SortRating:
+		return true
+	}
+	return false
+}
+
+// ProductSearchQuery describes a product search: a free-text query over name and description plus
+// optional filters. Empty filters match everything.
+type ProductSearchQuery struct {
+	Text        string
+	Category    string
+	SubCategory string
+	Status      ProductStatus
+	MinPrice    *float64
+	MaxPrice    *float64
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        ProductSort
+	Limit       int
+	Offset      int
+}
+
+// ProductFacets counts the matching products per category and per status. Each facet ignores its own
+// filter, so clients can show how many results the other choices would give.
+type ProductFacets struct {
+	Categories map[string]int64        `json:"categories"`
+	Statuses   map[ProductStatus]int64 `json:"statuses"`
+}
+
+// ProductSearchResult is one page of search results with the total match count and facets
+type ProductSearchResult struct {
+	Products []Product
+	Total    int64
+	Facets   ProductFacets
+}
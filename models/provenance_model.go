@@ -0,0 +1,36 @@
+package models
+
+import "github.com/google/uuid"
+
+// ProvenanceProblem is an entry of a provenance chain that does not verify, or an amendment the chain
+// does not record
+type ProvenanceProblem struct {
+	Sequence      int       `json:"sequence"` // 0 for an amendment the chain does not record
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Reason        string    `json:"reason"`
+}
+
+// ProvenanceVerification is the result of checking a product's provenance chain
+type ProvenanceVerification struct {
+	Valid    bool                `json:"valid"`
+	Length   int                 `json:"length"`
+	Head     string              `json:"head"` // Hash of the newest entry, which vouches for all before it
+	Problems []ProvenanceProblem `json:"problems,omitempty"`
+}
+
+// Provenance is a product's history as a hash chain, oldest entry first
+type Provenance struct {
+	ProductID    uuid.UUID              `json:"product_id"`
+	HashVersion  string                 `json:"hash_version"` // Format new entries are hashed in; each entry names its own
+	Chain        []Transaction          `json:"chain"`
+	Verification ProvenanceVerification `json:"verification"`
+}
+
+// ProvenanceCertificate is a signed snapshot of a product's provenance. The certificate is a JWT whose
+// claims hold the chain; it verifies offline against the public key with its "kid" from the JWKS.
+type ProvenanceCertificate struct {
+	Certificate string `json:"certificate"`
+	KeyID       string `json:"key_id"`
+	JWKSURL     string `json:"jwks_url"`
+	Head        string `json:"head"`
+}
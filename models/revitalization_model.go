@@ -0,0 +1,141 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevitalizationStatus is the state of a revitalization request and of the job that follows a bid
+type RevitalizationStatus string
+
+const (
+	RevitalizationOpen       RevitalizationStatus = "open"        // Published by the owner, workshops may bid
+	RevitalizationAccepted   RevitalizationStatus = "accepted"    // The owner accepted a bid
+	RevitalizationInProgress RevitalizationStatus = "in_progress" // The workshop started the work
+	RevitalizationDelivered  RevitalizationStatus = "delivered"   // The workshop posted the revitalized transaction
+	RevitalizationCancelled  RevitalizationStatus = "cancelled"   // Called off by the owner or a moderator
+)
+
+// IsValid reports whether s is one of the known revitalization statuses
+func (s RevitalizationStatus) IsValid() bool {
+	switch s {
+	case RevitalizationOpen, RevitalizationAccepted, RevitalizationInProgress, RevitalizationDelivered, RevitalizationCancelled:
+		return true
+	}
+	return false
+}
+
+// ActiveRevitalizationStatuses lists the statuses of requests that still block a new one on the product
+var ActiveRevitalizationStatuses = []RevitalizationStatus{RevitalizationOpen, RevitalizationAccepted, RevitalizationInProgress}
+
+// JobRevitalizationStatuses lists the statuses in which a workshop holds the job
+var JobRevitalizationStatuses = []RevitalizationStatus{RevitalizationAccepted, RevitalizationInProgress}
+
+// BidStatus is the state of a workshop's bid on a revitalization request
+type BidStatus string
+
+const (
+	BidPending  BidStatus = "pending"
+	BidAccepted BidStatus = "accepted"
+	BidRejected BidStatus = "rejected" // Another bid was accepted or the request was cancelled
+)
+
+// TransactionIDs is a list of transaction IDs, stored as a comma-separated column
+type TransactionIDs []uuid.UUID
+
+// Value implements driver.Valuer
+func (ids TransactionIDs) Value() (driver.Value, error) {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = id.String()
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// Scan implements sql.Scanner
+func (ids *TransactionIDs) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case []byte:
+		raw = string(v)
+	case string:
+		raw = v
+	case nil:
+		*ids = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into TransactionIDs", value)
+	}
+
+	*ids = nil
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := uuid.Parse(part)
+		if err != nil {
+			return fmt.Errorf("cannot scan %q into TransactionIDs: %w", part, err)
+		}
+		*ids = append(*ids, id)
+	}
+	return nil
+}
+
+// RevitalizationRequest is an owner's call for workshops to revitalize a product. Once the owner accepts
+// a bid it becomes that workshop's job, and only the workshop may post the product's revitalized
+// transaction, which delivers the job.
+type RevitalizationRequest struct {
+	ID            uuid.UUID            `gorm:"type:char(36);primaryKey" json:"id"`
+	ProductID     uuid.UUID            `gorm:"type:char(36);not null;index" json:"product_id"`
+	OwnerID       uuid.UUID            `gorm:"type:char(36);not null;index" json:"owner_id"`
+	Description   string               `gorm:"type:varchar(2000);not null" json:"description"` // What the owner wants done
+	Status        RevitalizationStatus `gorm:"type:varchar(20);not null;default:'open';index" json:"status"`
+	AcceptedBidID *uuid.UUID           `gorm:"type:char(36)" json:"accepted_bid_id,omitempty"`
+	WorkshopID    *uuid.UUID           `gorm:"type:char(36);index" json:"workshop_id,omitempty"` // The workshop whose bid was accepted
+	TransactionID *uuid.UUID           `gorm:"type:char(36)" json:"transaction_id,omitempty"`    // The revitalized transaction that delivered the job
+	Reason        string               `gorm:"type:varchar(500)" json:"reason,omitempty"`        // Why the request was cancelled
+	CreatedAt     time.Time            `gorm:"not null;index" json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	AcceptedAt    *time.Time           `json:"accepted_at,omitempty"`
+	StartedAt     *time.Time           `json:"started_at,omitempty"`
+	DeliveredAt   *time.Time           `json:"delivered_at,omitempty"`
+	Bids          []RevitalizationBid  `gorm:"-" json:"bids,omitempty"` // All bids for the owner and moderators, a workshop's own otherwise
+}
+
+// RevitalizationBid is a workshop's offer to revitalize a product. Its portfolio points at revitalized
+// transactions the workshop posted before.
+type RevitalizationBid struct {
+	ID            uuid.UUID      `gorm:"type:char(36);primaryKey" json:"id"`
+	RequestID     uuid.UUID      `gorm:"type:char(36);not null;uniqueIndex:idx_revitalization_bids_workshop" json:"request_id"`
+	WorkshopID    uuid.UUID      `gorm:"type:char(36);not null;uniqueIndex:idx_revitalization_bids_workshop;index" json:"workshop_id"`
+	Price         float64        `gorm:"not null" json:"price"`
+	EstimatedDays int            `gorm:"not null" json:"estimated_days"`
+	Message       string         `gorm:"type:varchar(1000)" json:"message,omitempty"`
+	Portfolio     TransactionIDs `gorm:"type:varchar(400)" json:"portfolio"`
+	Status        BidStatus      `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	CreatedAt     time.Time      `gorm:"not null" json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// PublishRevitalization represents an owner's revitalization request for a product
+type PublishRevitalization struct {
+	ProductID   uuid.UUID `json:"product_id" binding:"required"`
+	Description string    `json:"description" binding:"required,max=2000"`
+}
+
+// PlaceBid represents a workshop's bid on a revitalization request
+type PlaceBid struct {
+	Price         float64     `json:"price" binding:"required,gt=0"`
+	EstimatedDays int         `json:"estimated_days" binding:"required,min=1,max=365"`
+	Message       string      `json:"message" binding:"max=1000"`
+	Portfolio     []uuid.UUID `json:"portfolio" binding:"max=10"` // Revitalized transactions the workshop posted before
+}
+
+// CancelRevitalization represents the optional details of cancelling a revitalization request
+type CancelRevitalization struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
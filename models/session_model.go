@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session represents a logged-in device, backed by a rotating refresh token
+type Session struct {
+	ID                uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID            uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"` // SHA-256 of the current refresh token
+	PreviousTokenHash string     `gorm:"type:char(64);index" json:"-"`                // SHA-256 of the last rotated-out refresh token, used to detect replays
+	UserAgent         string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IPAddress         string     `gorm:"type:varchar(45)" json:"ip_address"`
+	CreatedAt         time.Time  `gorm:"default:current_timestamp" json:"created_at"`
+	LastRefreshedAt   time.Time  `json:"last_refreshed_at"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AuthTokens is the pair of tokens handed out on login and on every refresh
+type AuthTokens struct {
+	AccessToken      string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// RefreshSession represents the data required to rotate a refresh token
+type RefreshSession struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ClientInfo describes the device a session was opened from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
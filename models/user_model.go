@@ -8,16 +8,49 @@ import (
 	"github.com/google/uuid"
 )
 
+// Role defines the access level of a user
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleWorkshop  Role = "workshop" // A user an admin verified as a workshop; only workshops bid on revitalizations
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// IsValid reports whether r is one of the known roles
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleUser, RoleWorkshop, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 // User represents the user model
 type User struct {
-	ID           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	Name         string    `gorm:"not null" json:"name"`
-	Email        string    `gorm:"not null;unique" json:"email"`
-	Password     string    `gorm:"not null" json:"password"`
-	Verified     bool      `gorm:"not null" json:"verified"`
-	CreatedAt    time.Time `gorm:"default:current_timestamp" json:"created_at"`
-	ImageURL     string    `gorm:"not null" json:"image_url"`
-	PremiumUntil string    `json:"premium_until"`
+	ID           uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Name         string     `gorm:"not null" json:"name"`
+	Email        string     `gorm:"not null;unique" json:"email"`
+	Password     string     `gorm:"not null" json:"password"`
+	Verified     bool       `gorm:"not null" json:"verified"`
+	CreatedAt    time.Time  `gorm:"default:current_timestamp" json:"created_at"`
+	ImageURL     string     `gorm:"not null" json:"image_url"`
+	PremiumUntil string     `json:"premium_until"`
+	Role         Role       `gorm:"type:varchar(20);not null;default:'user'" json:"role"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"` // Set when the user closed their account; see AccountDeletion
+}
+
+// UserSummary is the part of a profile shown next to content the user authored
+type UserSummary struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	ImageURL string    `json:"image_url,omitempty"`
+}
+
+// Summary returns the user's summary. Call it on a profile the viewer's privacy rules were applied to.
+func (u *User) Summary() UserSummary {
+	return UserSummary{ID: u.ID, Name: u.Name, ImageURL: u.ImageURL}
 }
 
 type SignUp struct {
@@ -40,6 +73,7 @@ type UpdateEmail struct {
 
 // UpdatePassword represents the data for updating a user's password
 type UpdatePassword struct {
+	Token       string `json:"token"` // Password reset token from the emailed link; the ?token= query parameter is still accepted
 	NewPassword string `json:"new_password" binding:"required,min=6"`
 }
 
@@ -55,7 +89,7 @@ type SendPasswordResetEmail struct {
 
 // VerifyEmail represents the data for verifying a user's email
 type VerifyEmail struct {
-	Token string `json:"token" binding:"required"`
+	Token string `json:"token"` // The ?token= query parameter is still accepted
 }
 type SendEmailVerification struct {
 	Email string `json:"email" binding:"required,email"`
@@ -64,3 +98,8 @@ type PasswordResetClaims struct {
 	UserID string `json:"user_id"`
 	jwt.StandardClaims
 }
+
+// UpdateRole represents the data for changing a user's role
+type UpdateRole struct {
+	Role Role `json:"role" binding:"required"`
+}
@@ -0,0 +1,182 @@
+// Package pagination implements the cursor pagination shared by the list endpoints. Lists are ordered
+// newest first on (created_at, id), and each page ends with an opaque cursor that says where the next
+// one starts, so rows inserted while a client pages through a list neither repeat nor go missing.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks where a page ended. Keyset lists resume after CreatedAt/ID; ranked lists that have no
+// stable key, such as search results by relevance or price, resume at Offset.
+type Cursor struct {
+	CreatedAt time.Time `json:"t,omitempty"`
+	ID        uuid.UUID `json:"i,omitempty"`
+	Offset    int       `json:"o,omitempty"`
+}
+
+// Encode turns the cursor into the opaque token handed to clients
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a token made by Encode
+func Decode(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Offset < 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// Params are the pagination parameters of a list request
+type Params struct {
+	Limit     int
+	After     *Cursor // Nil for the first page
+	WithTotal bool    // Count all matching items; costs an extra query
+}
+
+// Page describes where a page sits in its list
+type Page struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	Total      *int64 `json:"total,omitempty"` // Only set when asked for with include_total
+}
+
+// FromRequest reads the limit, cursor and include_total query parameters
+func FromRequest(c *gin.Context) (Params, error) {
+	params := Params{Limit: DefaultLimit}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return params, fmt.Errorf("limit must be a positive number")
+		}
+		params.Limit = min(limit, MaxLimit)
+	}
+
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := Decode(token)
+		if err != nil {
+			return params, err
+		}
+		params.After = cursor
+	}
+
+	params.WithTotal, _ = strconv.ParseBool(c.Query("include_total"))
+	return params, nil
+}
+
+// Keyset restricts a query to the rows after the cursor, newest first, and fetches one row more than
+// the limit so Finish can tell whether another page follows. Columns are qualified with table.
+func Keyset(query *gorm.DB, table string, params Params) *gorm.DB {
+	return keyset(query, table, params, "<", "DESC")
+}
+
+// KeysetOldestFirst is Keyset for lists read oldest first, such as exports
+func KeysetOldestFirst(query *gorm.DB, table string, params Params) *gorm.DB {
+	return keyset(query, table, params, ">", "ASC")
+}
+
+func keyset(query *gorm.DB, table string, params Params, after, direction string) *gorm.DB {
+	query = query.Session(&gorm.Session{})
+	if params.After != nil {
+		query = query.Where(
+			fmt.Sprintf("(%[1]s.created_at %[2]s ? OR (%[1]s.created_at = ? AND %[1]s.id %[2]s ?))", table, after),
+			params.After.CreatedAt, params.After.CreatedAt, params.After.ID,
+		)
+	}
+	return query.
+		Order(fmt.Sprintf("%[1]s.created_at %[2]s, %[1]s.id %[2]s", table, direction)).
+		Limit(params.Limit + 1)
+}
+
+// Offset skips the rows of earlier pages of a ranked list and fetches one row more than the limit
+func Offset(query *gorm.DB, params Params) *gorm.DB {
+	return query.Session(&gorm.Session{}).Offset(params.Skip()).Limit(params.Limit + 1)
+}
+
+// Count counts the rows of a query when totals were asked for
+func Count(query *gorm.DB, params Params) (*int64, error) {
+	if !params.WithTotal {
+		return nil, nil
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	return &total, nil
+}
+
+// Finish trims the extra row fetched by Keyset and builds the page, with a cursor after the last item
+func Finish[T any](items []T, params Params, total *int64, key func(T) (time.Time, uuid.UUID)) ([]T, Page) {
+	items, page := trim(items, params, total)
+	if page.HasMore {
+		createdAt, id := key(items[len(items)-1])
+		page.NextCursor = Cursor{CreatedAt: createdAt, ID: id}.Encode()
+	}
+	return items, page
+}
+
+// FinishOffset trims the extra row fetched by Offset and builds the page of a ranked list
+func FinishOffset[T any](items []T, params Params, total *int64) ([]T, Page) {
+	items, page := trim(items, params, total)
+	if page.HasMore {
+		page.NextCursor = Cursor{Offset: params.Skip() + len(items)}.Encode()
+	}
+	return items, page
+}
+
+// trim cuts items down to the limit and reports whether there were more
+func trim[T any](items []T, params Params, total *int64) ([]T, Page) {
+	page := Page{Total: total}
+	if len(items) > params.Limit {
+		items = items[:params.Limit]
+		page.HasMore = true
+	}
+	if items == nil {
+		items = []T{}
+	}
+	return items, page
+}
+
+// Skip is the number of rows of a ranked list that earlier pages covered
+func (p Params) Skip() int {
+	if p.After == nil {
+		return 0
+	}
+	return p.After.Offset
+}
+
+// Response builds the JSON body of a list endpoint: the items under key, plus the page fields
+func (p Page) Response(key string, items interface{}) gin.H {
+	body := gin.H{key: items, "has_more": p.HasMore}
+	if p.NextCursor != "" {
+		body["next_cursor"] = p.NextCursor
+	}
+	if p.Total != nil {
+		body["total"] = *p.Total
+	}
+	return body
+}
@@ -0,0 +1,134 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MockProviderName identifies the mock provider
+	MockProviderName = "mock"
+	// MockDeclinedSource is a payment source the mock provider always declines
+	MockDeclinedSource = "mock_declined"
+)
+
+// mockPayment is one authorization the mock provider keeps track of
+type mockPayment struct {
+	authorized Amount
+	captureID  string
+	captured   Amount
+	refunded   Amount
+}
+
+// MockProvider is an in-memory Provider for local development and tests. It accepts every payment
+// source except MockDeclinedSource and signs webhooks with HMAC-SHA256.
+type MockProvider struct {
+	secret []byte
+
+	mu       sync.Mutex
+	payments map[string]*mockPayment // By authorization ID
+	captures map[string]string       // Capture ID to authorization ID
+}
+
+// NewMockProvider creates a mock provider whose webhooks are signed with secret
+func NewMockProvider(secret string) *MockProvider {
+	return &MockProvider{
+		secret:   []byte(secret),
+		payments: make(map[string]*mockPayment),
+		captures: make(map[string]string),
+	}
+}
+
+// Name returns "mock"
+func (p *MockProvider) Name() string {
+	return MockProviderName
+}
+
+// Authorize reserves the amount unless the source is MockDeclinedSource
+func (p *MockProvider) Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidAmount, req.Amount)
+	}
+	if req.Source == MockDeclinedSource {
+		return nil, fmt.Errorf("%w: the card was declined", ErrDeclined)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := "mock_auth_" + uuid.NewString()
+	p.payments[id] = &mockPayment{authorized: req.Amount}
+	return &Result{ID: id, Amount: req.Amount}, nil
+}
+
+// Capture collects up to the authorized amount, once
+func (p *MockProvider) Capture(ctx context.Context, authorizationID string, amount Amount) (*Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[authorizationID]
+	if !ok {
+		return nil, fmt.Errorf("%w: authorization %s", ErrUnknownPayment, authorizationID)
+	}
+	if payment.captureID != "" {
+		return nil, fmt.Errorf("authorization %s was already captured", authorizationID)
+	}
+	if amount <= 0 || amount > payment.authorized {
+		return nil, fmt.Errorf("%w: cannot capture %d of %d authorized", ErrInvalidAmount, amount, payment.authorized)
+	}
+
+	payment.captureID = "mock_cap_" + uuid.NewString()
+	payment.captured = amount
+	p.captures[payment.captureID] = authorizationID
+	return &Result{ID: payment.captureID, Amount: amount}, nil
+}
+
+// Refund pays back up to what is left of the captured amount
+func (p *MockProvider) Refund(ctx context.Context, captureID string, amount Amount) (*Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	authorizationID, ok := p.captures[captureID]
+	if !ok {
+		return nil, fmt.Errorf("%w: capture %s", ErrUnknownPayment, captureID)
+	}
+	payment := p.payments[authorizationID]
+	if amount <= 0 || amount > payment.captured-payment.refunded {
+		return nil, fmt.Errorf("%w: cannot refund %d of %d remaining", ErrInvalidAmount, amount, payment.captured-payment.refunded)
+	}
+
+	payment.refunded += amount
+	return &Result{ID: "mock_ref_" + uuid.NewString(), Amount: amount}, nil
+}
+
+// VerifyWebhook checks that signature is the hex HMAC-SHA256 of the payload
+func (p *MockProvider) VerifyWebhook(payload []byte, signature string) (*Event, error) {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, p.mac(payload)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	return &event, nil
+}
+
+// Sign returns the signature VerifyWebhook accepts for the payload, to simulate webhooks in tests
+func (p *MockProvider) Sign(payload []byte) string {
+	return hex.EncodeToString(p.mac(payload))
+}
+
+func (p *MockProvider) mac(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
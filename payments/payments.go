@@ -0,0 +1,118 @@
+// Package payments moves buyers' money through an external payment provider. The provider is hidden
+// behind an interface so a real processor can be plugged in; the mock provider keeps everything in
+// memory for local development and tests.
+package payments
+
+import (
+	"backend/appenv"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+)
+
+var (
+	ErrDeclined         = errors.New("payment declined")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrUnknownPayment   = errors.New("unknown payment")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Amount is a sum of money in the currency's minor unit, e.g. cents. Money is never kept in floats
+// once it reaches this package.
+type Amount int64
+
+// FromFloat converts a price in major units, as products store it, to an Amount
+func FromFloat(price float64) Amount {
+	return Amount(math.Round(price * 100))
+}
+
+// Float converts the amount back to major units
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+// Fee returns the share of the amount the platform keeps, given in basis points (1/100 of a percent),
+// rounded to the nearest minor unit
+func Fee(amount Amount, basisPoints int64) Amount {
+	return Amount(math.Round(float64(amount) * float64(basisPoints) / 10000))
+}
+
+// AuthorizeRequest asks the provider to reserve money on the buyer's payment method
+type AuthorizeRequest struct {
+	Amount    Amount
+	Currency  string // ISO 4217 code, e.g. "EUR"
+	Source    string // The payment method token the buyer's client obtained from the provider
+	Reference string // Our identifier for the payment, echoed back in webhooks
+}
+
+// Result is the outcome of a successful provider operation
+type Result struct {
+	ID     string // The provider's identifier for the authorization, capture or refund
+	Amount Amount
+}
+
+// EventType is the kind of change a provider reports through a webhook
+type EventType string
+
+const (
+	EventCaptured EventType = "payment.captured"
+	EventRefunded EventType = "payment.refunded"
+	EventFailed   EventType = "payment.failed"
+)
+
+// Event is a verified webhook notification from the provider
+type Event struct {
+	ID        string    `json:"id"`
+	Type      EventType `json:"type"`
+	PaymentID string    `json:"payment_id"` // The capture the event is about
+	Amount    Amount    `json:"amount"`
+}
+
+// Provider is a payment processor. Funds are authorized on the buyer's payment method, captured to
+// the platform and, when a sale falls through, refunded.
+type Provider interface {
+	// Name is the identifier stored on payments, e.g. "mock"
+	Name() string
+	// Authorize reserves the amount on the buyer's payment method. It returns an error wrapping
+	// ErrDeclined when the provider refuses the payment.
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error)
+	// Capture collects an authorized amount
+	Capture(ctx context.Context, authorizationID string, amount Amount) (*Result, error)
+	// Refund pays a captured amount back to the buyer, in full or in part
+	Refund(ctx context.Context, captureID string, amount Amount) (*Result, error)
+	// VerifyWebhook checks the signature of a webhook request and decodes its event. It returns an
+	// error wrapping ErrInvalidSignature when the request did not come from the provider.
+	VerifyWebhook(payload []byte, signature string) (*Event, error)
+}
+
+// LoadProviderFromEnv builds the provider named in PAYMENT_PROVIDER. Without it payments are disabled and
+// nil is returned: orders can then not be placed or paid. The provider's webhooks are verified with
+// PAYMENT_WEBHOOK_SECRET, which must not be empty, or anyone could forge them.
+//
+// The mock provider moves no real money and keeps its captures in memory, so they are lost on restart.
+// It is only allowed with APP_ENV "development" or "test".
+func LoadProviderFromEnv() (Provider, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("PAYMENT_PROVIDER")))
+	if name == "" {
+		log.Println("PAYMENT_PROVIDER is not set, payments are disabled")
+		return nil, nil
+	}
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if strings.TrimSpace(secret) == "" {
+		return nil, errors.New("PAYMENT_WEBHOOK_SECRET is not set")
+	}
+
+	switch name {
+	case MockProviderName:
+		if !appenv.IsDevelopment() {
+			return nil, errors.New("the mock payment provider is only allowed with APP_ENV=development or APP_ENV=test")
+		}
+		log.Println("Using the mock payment provider, which moves no real money (APP_ENV=" + os.Getenv("APP_ENV") + ")")
+		return NewMockProvider(secret), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", name)
+}
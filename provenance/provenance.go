@@ -0,0 +1,191 @@
+// Package provenance chains the transactions of a product with SHA-256 hashes. Each entry's hash covers
+// its content and the hash of the entry before it, so a row altered in the database no longer matches
+// its hash and breaks the link to every entry after it.
+//
+// The hash of an entry is the hex SHA-256 of the JSON array
+//
+//	["v2", sequence, id, item_id, user_id, action, description, image_url, created_at, prev_hash]
+//
+// with sequence as a decimal string, image_url the key of the image recorded with the entry and
+// created_at in RFC 3339 UTC to the second, which anyone can recompute offline. Entries chained before
+// the image key was covered keep their "v1" hash, which is the same array without image_url; each entry
+// names its format in hash_version. The other gallery images are left out because their uploaders and
+// moderators may curate them.
+//
+// Corrections of a transaction never change its entry. Each one is appended as an "amended" entry whose
+// description spells out the corrected values and whose image is the corrected photo, so the chain
+// covers them like any other entry; see AmendmentEntry.
+package provenance
+
+import (
+	"backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Version identifies the hash format new entries are chained with and is the first element of the
+	// hashed content
+	Version = "v2"
+	// versionWithoutImage is the format of entries chained before the image key was covered
+	versionWithoutImage = "v1"
+)
+
+// Hash computes the hash of a transaction from its content and PrevHash, in the format its HashVersion names
+func Hash(t *models.Transaction) string {
+	fields := []string{
+		t.HashVersion,
+		strconv.Itoa(t.Sequence),
+		t.ID.String(),
+		t.ItemID.String(),
+		t.UserID.String(),
+		string(t.Action),
+		t.Description,
+	}
+	if t.HashVersion != versionWithoutImage {
+		fields = append(fields, t.ImageURL)
+	}
+	fields = append(fields, t.CreatedAt.UTC().Format(time.RFC3339), t.PrevHash)
+
+	content, _ := json.Marshal(fields)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Link appends a transaction to the chain after prev, the product's newest entry or nil for its first.
+// The creation time is cut to the second, which is all the database keeps.
+func Link(t *models.Transaction, prev *models.Transaction) {
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	t.CreatedAt = t.CreatedAt.UTC().Truncate(time.Second)
+
+	t.HashVersion = Version
+	t.Sequence = 1
+	t.PrevHash = ""
+	if prev != nil {
+		t.Sequence = prev.Sequence + 1
+		t.PrevHash = prev.Hash
+	}
+	t.Hash = Hash(t)
+}
+
+// Verify checks a product's chain, given oldest entry first: the entries must be numbered from 1
+// without gaps, each must link to the hash of the one before it and match its own hash
+func Verify(chain []models.Transaction) models.ProvenanceVerification {
+	result := models.ProvenanceVerification{Length: len(chain)}
+	problem := func(t *models.Transaction, format string, args ...interface{}) {
+		report(&result, t.Sequence, t.ID, format, args...)
+	}
+
+	prevHash := ""
+	for i := range chain {
+		t := &chain[i]
+		if t.Sequence != i+1 {
+			problem(t, "expected sequence %d, the chain has a gap or a duplicate", i+1)
+		}
+		if t.PrevHash != prevHash {
+			problem(t, "does not link to the entry before it")
+		}
+		if t.HashVersion != Version && t.HashVersion != versionWithoutImage {
+			problem(t, "unknown hash version %q", t.HashVersion)
+		} else if t.Hash != Hash(t) {
+			problem(t, "content does not match its hash")
+		}
+		prevHash = t.Hash
+	}
+
+	result.Head = prevHash
+	result.Valid = len(result.Problems) == 0
+	return result
+}
+
+// AmendmentEntry builds the "amended" entry that records an amendment in the chain of productID, the
+// product of the amended transaction. It still has to be linked.
+func AmendmentEntry(amendment *models.TransactionAmendment, productID uuid.UUID) *models.Transaction {
+	return &models.Transaction{
+		ID:          uuid.New(),
+		ItemID:      productID,
+		UserID:      amendment.AuthorID,
+		Description: describeAmendment(amendment),
+		Action:      models.Amended,
+		ImageURL:    amendmentImage(amendment),
+		CreatedAt:   amendment.CreatedAt,
+	}
+}
+
+// VerifyAmendments checks the amendments of a chain's transactions against the chain, which must have
+// passed Verify: every amendment must be recorded by an "amended" entry that matches it, and every such
+// entry must still have its amendment. Problems found are added to result.
+func VerifyAmendments(result *models.ProvenanceVerification, chain []models.Transaction, amendments []models.TransactionAmendment) {
+	entries := make(map[uuid.UUID]*models.Transaction)
+	for i := range chain {
+		if chain[i].Action == models.Amended {
+			entries[chain[i].ID] = &chain[i]
+		}
+	}
+
+	recorded := make(map[uuid.UUID]bool)
+	for i := range amendments {
+		amendment := &amendments[i]
+		var entry *models.Transaction
+		if amendment.EntryID != nil {
+			entry = entries[*amendment.EntryID]
+		}
+		switch {
+		case entry == nil:
+			report(result, 0, amendment.TransactionID, "amendment %s is not recorded in the chain", amendment.ID)
+		case entry.UserID != amendment.AuthorID || entry.Description != describeAmendment(amendment) || entry.ImageURL != amendmentImage(amendment):
+			report(result, entry.Sequence, entry.ID, "does not match amendment %s", amendment.ID)
+		}
+		if entry != nil {
+			recorded[entry.ID] = true
+		}
+	}
+	for i := range chain {
+		if chain[i].Action == models.Amended && !recorded[chain[i].ID] {
+			report(result, chain[i].Sequence, chain[i].ID, "records an amendment that is missing")
+		}
+	}
+
+	result.Valid = len(result.Problems) == 0
+}
+
+// describeAmendment is the description of an amendment's entry. Prices are written with as many digits
+// as they need, so the description is the same whenever it is recomputed.
+func describeAmendment(amendment *models.TransactionAmendment) string {
+	var corrected []string
+	if amendment.Description != nil {
+		corrected = append(corrected, fmt.Sprintf("description %q", *amendment.Description))
+	}
+	if amendment.ImageURL != nil {
+		corrected = append(corrected, fmt.Sprintf("image %q", *amendment.ImageURL))
+	}
+	if amendment.Price != nil {
+		corrected = append(corrected, "price "+strconv.FormatFloat(*amendment.Price, 'f', -1, 64))
+	}
+	return fmt.Sprintf("Amended transaction %s: %s. Reason: %s", amendment.TransactionID, strings.Join(corrected, ", "), amendment.Reason)
+}
+
+func amendmentImage(amendment *models.TransactionAmendment) string {
+	if amendment.ImageURL == nil {
+		return ""
+	}
+	return *amendment.ImageURL
+}
+
+// report adds a problem with the entry at sequence, 0 for problems outside the chain, to result
+func report(result *models.ProvenanceVerification, sequence int, transactionID uuid.UUID, format string, args ...interface{}) {
+	result.Problems = append(result.Problems, models.ProvenanceProblem{
+		Sequence:      sequence,
+		TransactionID: transactionID,
+		Reason:        fmt.Sprintf(format, args...),
+	})
+}
@@ -0,0 +1,92 @@
+package provenance
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// amendedChain builds a chain of a submitted entry and one amendment of it, recorded as the second entry
+func amendedChain() ([]models.Transaction, []models.TransactionAmendment) {
+	productID, userID := uuid.New(), uuid.New()
+	submitted := models.Transaction{
+		ID:          uuid.New(),
+		ItemID:      productID,
+		UserID:      userID,
+		Description: "Oak chair",
+		Action:      models.Submitted,
+		CreatedAt:   time.Now(),
+	}
+	Link(&submitted, nil)
+
+	description, image, price := "Oak chair, 1960s", "photo.jpg", 12.5
+	amendment := models.TransactionAmendment{
+		ID:            uuid.New(),
+		TransactionID: submitted.ID,
+		AuthorID:      userID,
+		Reason:        "Wrong decade",
+		Description:   &description,
+		ImageURL:      &image,
+		Price:         &price,
+		CreatedAt:     time.Now(),
+	}
+	entry := AmendmentEntry(&amendment, productID)
+	Link(entry, &submitted)
+	amendment.EntryID = &entry.ID
+
+	return []models.Transaction{submitted, *entry}, []models.TransactionAmendment{amendment}
+}
+
+func verify(chain []models.Transaction, amendments []models.TransactionAmendment) models.ProvenanceVerification {
+	result := Verify(chain)
+	VerifyAmendments(&result, chain, amendments)
+	return result
+}
+
+func TestVerifyAmendments(t *testing.T) {
+	chain, amendments := amendedChain()
+	if result := verify(chain, amendments); !result.Valid {
+		t.Fatalf("expected the chain to verify, got %+v", result.Problems)
+	}
+}
+
+func TestVerifyAmendmentsDetectsAlteredAmendment(t *testing.T) {
+	chain, amendments := amendedChain()
+	price := 1.0
+	amendments[0].Price = &price
+
+	result := verify(chain, amendments)
+	if result.Valid || len(result.Problems) != 1 || result.Problems[0].TransactionID != chain[1].ID {
+		t.Fatalf("expected a problem with the amended entry, got %+v", result.Problems)
+	}
+}
+
+func TestVerifyAmendmentsDetectsMissingAmendment(t *testing.T) {
+	chain, _ := amendedChain()
+
+	result := verify(chain, nil)
+	if result.Valid || len(result.Problems) != 1 || result.Problems[0].Sequence != 2 {
+		t.Fatalf("expected a problem with the amended entry, got %+v", result.Problems)
+	}
+}
+
+func TestVerifyAmendmentsDetectsUnrecordedAmendment(t *testing.T) {
+	chain, amendments := amendedChain()
+	chain = chain[:1]
+
+	result := verify(chain, amendments)
+	if result.Valid || len(result.Problems) != 1 || result.Problems[0].Sequence != 0 {
+		t.Fatalf("expected a problem with the amendment, got %+v", result.Problems)
+	}
+}
+
+func TestVerifyDetectsAlteredAmendedEntry(t *testing.T) {
+	chain, amendments := amendedChain()
+	chain[1].Description = "Amended transaction " + chain[0].ID.String() + ": price 1. Reason: Wrong decade"
+
+	if result := verify(chain, amendments); result.Valid {
+		t.Fatal("expected the altered entry not to verify")
+	}
+}
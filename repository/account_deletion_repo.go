@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccountDeletionRepository handles database operations for closed accounts
+type AccountDeletionRepository struct {
+	db *gorm.DB
+}
+
+// NewAccountDeletionRepository creates a new instance of AccountDeletionRepository
+func NewAccountDeletionRepository(db *gorm.DB) *AccountDeletionRepository {
+	return &AccountDeletionRepository{db: db}
+}
+
+// GetByUserID retrieves the deletion record of a user
+func (repo *AccountDeletionRepository) GetByUserID(userID string) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	if err := repo.db.First(&deletion, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// Schedule records the deletion and anonymizes the account in one transaction: the profile no longer
+// identifies the user, and their sessions and API keys stop working.
+func (repo *AccountDeletionRepository) Schedule(deletion *models.AccountDeletion) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(deletion).Error; err != nil {
+			if isDuplicateKey(err) {
+				return ErrDuplicateKey
+			}
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", deletion.UserID).Updates(map[string]interface{}{
+			"name":       models.DeletedUserName,
+			"image_url":  "",
+			"deleted_at": deletion.RequestedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", deletion.UserID).
+			Update("revoked_at", deletion.RequestedAt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", deletion.UserID).
+			Update("revoked_at", deletion.RequestedAt).Error
+	})
+}
+
+// ListDue retrieves pending deletions whose grace period has ended
+func (repo *AccountDeletionRepository) ListDue(now time.Time, limit int) ([]models.AccountDeletion, error) {
+	var deletions []models.AccountDeletion
+	if err := repo.db.
+		Where("status = ? AND purge_after <= ?", models.AccountDeletionPending, now).
+		Order("purge_after ASC").
+		Limit(limit).
+		Find(&deletions).Error; err != nil {
+		return nil, err
+	}
+	return deletions, nil
+}
+
+// Purge hard-deletes the personal data of a closed account and marks the deletion completed.
+// The user row itself stays as an anonymous tombstone: products, transactions and comments reference it
+// with ON DELETE CASCADE, and the provenance of products others now own must survive. Comments are
+// kept so threads still read in order, but their text is redacted.
+func (repo *AccountDeletionRepository) Purge(deletion *models.AccountDeletion) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, "id = ?", deletion.UserID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		personalData := []interface{}{
+			&models.Session{},
+			&models.UserIdentity{},
+			&models.UserMFA{},
+			&models.RecoveryCode{},
+			&models.OneTimeToken{},
+			&models.EmailChange{},
+			&models.APIKey{},
+			&models.Rating{},
+			&models.UserPrivacy{},
+		}
+		for _, model := range personalData {
+			if err := tx.Where("user_id = ?", deletion.UserID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("user_id = ? OR email = ?", deletion.UserID, user.Email).Delete(&models.LoginAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Comment{}).Where("user_id = ?", deletion.UserID).
+			Update("content", models.RedactedCommentContent).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", deletion.UserID).Updates(map[string]interface{}{
+			"name":          models.DeletedUserName,
+			"email":         fmt.Sprintf("deleted-%s@deleted.invalid", deletion.UserID),
+			"password":      "",
+			"verified":      false,
+			"image_url":     "",
+			"premium_until": "",
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.AccountDeletion{}).Where("id = ?", deletion.ID).Updates(map[string]interface{}{
+			"status":       models.AccountDeletionCompleted,
+			"completed_at": time.Now().UTC(),
+		}).Error
+	})
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AmendmentRepository handles database operations for transaction amendments. Amendments are an
+// append-only audit trail, so there is no way to change or remove one.
+type AmendmentRepository struct {
+	db *gorm.DB
+}
+
+// NewAmendmentRepository creates a new instance of AmendmentRepository
+func NewAmendmentRepository(db *gorm.DB) *AmendmentRepository {
+	return &AmendmentRepository{db: db}
+}
+
+// Create inserts a new amendment
+func (repo *AmendmentRepository) Create(amendment *models.TransactionAmendment) error {
+	return repo.db.Create(amendment).Error
+}
+
+// ListByTransactionIDs retrieves the amendments of several transactions, oldest first
+func (repo *AmendmentRepository) ListByTransactionIDs(transactionIDs []uuid.UUID) ([]models.TransactionAmendment, error) {
+	var amendments []models.TransactionAmendment
+	if len(transactionIDs) == 0 {
+		return amendments, nil
+	}
+	if err := repo.db.
+		Where("transaction_id IN ?", transactionIDs).
+		Order("created_at ASC, id ASC").
+		Find(&amendments).Error; err != nil {
+		return nil, err
+	}
+	return amendments, nil
+}
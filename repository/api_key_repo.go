@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new instance of APIKeyRepository
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create inserts a new API key into the database
+func (repo *APIKeyRepository) Create(key *models.APIKey) error {
+	return repo.db.Create(key).Error
+}
+
+// GetByID retrieves an API key by its ID
+func (repo *APIKeyRepository) GetByID(id string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := repo.db.First(&key, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByPrefix retrieves an API key by its lookup prefix
+func (repo *APIKeyRepository) GetByPrefix(prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := repo.db.First(&key, "prefix = ?", prefix).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// List retrieves API keys newest first, optionally only those of one user
+func (repo *APIKeyRepository) List(userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	query := repo.db.Order("created_at DESC")
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Revoke marks an API key as revoked
+func (repo *APIKeyRepository) Revoke(id string) error {
+	return repo.db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC()).Error
+}
+
+// TouchLastUsed records when an API key was last used
+func (repo *APIKeyRepository) TouchLastUsed(id string, usedAt time.Time) error {
+	return repo.db.Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CategoryRepository handles database operations for the product taxonomy
+type CategoryRepository struct {
+	db *gorm.DB
+}
+
+// NewCategoryRepository creates a new instance of CategoryRepository
+func NewCategoryRepository(db *gorm.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+// List retrieves every category with its localized names, ordered by position
+func (repo *CategoryRepository) List() ([]models.Category, error) {
+	var categories []models.Category
+	if err := repo.db.Preload("Names").Order("position ASC, name ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// GetByID retrieves a category by its ID
+func (repo *CategoryRepository) GetByID(id uuid.UUID) (*models.Category, error) {
+	var category models.Category
+	if err := repo.db.Preload("Names").First(&category, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Create adds a category together with its localized names
+func (repo *CategoryRepository) Create(category *models.Category) error {
+	if err := repo.db.Create(category).Error; err != nil {
+		if isDuplicateKey(err) {
+			return ErrDuplicateKey
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes a category and its localized names
+func (repo *CategoryRepository) Delete(id uuid.UUID) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("category_id = ?", id).Delete(&models.CategoryName{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Category{}, "id = ?", id).Error
+	})
+}
+
+// CountChildren counts the subcategories of a category
+func (repo *CategoryRepository) CountChildren(id uuid.UUID) (int64, error) {
+	var count int64
+	err := repo.db.Model(&models.Category{}).Where("parent_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// CountProducts counts products per category/subcategory pair as stored on the products.
+// Withdrawn listings are left out unless includeWithdrawn is set.
+func (repo *CategoryRepository) CountProducts(includeWithdrawn bool) ([]models.CategoryUsage, error) {
+	query := repo.db.Model(&models.Product{}).
+		Select("COALESCE(category, '') AS category, COALESCE(sub_category, '') AS sub_category, COUNT(*) AS count").
+		Group("COALESCE(category, ''), COALESCE(sub_category, '')")
+	if !includeWithdrawn {
+		query = query.Where("status <> ?", models.StatusWithdrawn)
+	}
+
+	var usage []models.CategoryUsage
+	if err := query.Scan(&usage).Error; err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// Remap moves products from free-text category values onto canonical ones in one transaction and
+// returns the number of products changed
+func (repo *CategoryRepository) Remap(remaps []models.CategoryRemap) (int64, error) {
+	var updated int64
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		for _, remap := range remaps {
+			result := tx.Model(&models.Product{}).
+				Where("COALESCE(category, '') = ? AND COALESCE(sub_category, '') = ?", remap.FromCategory, remap.FromSubCategory).
+				Updates(map[string]interface{}{
+					"category":     remap.Category,
+					"sub_category": remap.SubCategory,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			updated += result.RowsAffected
+		}
+		return nil
+	})
+	return updated, err
+}
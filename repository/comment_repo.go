@@ -2,6 +2,8 @@ package repository
 
 import (
 	"backend/models"
+	"backend/pagination"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -27,10 +29,28 @@ func (repo *CommentRepository) Delete(id uuid.UUID) error {
 	return repo.db.Delete(&models.Comment{}, "id = ?", id).Error
 }
 
-// GetByProductID retrieves all comments for a specific product
-func (repo *CommentRepository) GetByProductID(productID uuid.UUID) ([]models.Comment, error) {
+// GetByProductID retrieves a page of the comments on a product, newest first
+func (repo *CommentRepository) GetByProductID(productID uuid.UUID, params pagination.Params) ([]models.Comment, pagination.Page, error) {
+	query := repo.db.Model(&models.Comment{}).Where("product_id = ?", productID)
+	total, err := pagination.Count(query, params)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+
 	var comments []models.Comment
-	if err := repo.db.Where("product_id = ?", productID).Find(&comments).Error; err != nil {
+	if err := pagination.Keyset(query, "comments", params).Find(&comments).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	comments, page := pagination.Finish(comments, params, total, func(c models.Comment) (time.Time, uuid.UUID) {
+		return c.CreatedAt, c.ID
+	})
+	return comments, page, nil
+}
+
+// GetByUserID retrieves all comments written by a user
+func (repo *CommentRepository) GetByUserID(userID uuid.UUID) ([]models.Comment, error) {
+	var comments []models.Comment
+	if err := repo.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&comments).Error; err != nil {
 		return nil, err
 	}
 	return comments, nil
@@ -41,7 +61,10 @@ func (repo *CommentRepository) FindByID(id uuid.UUID) (*models.Comment, error) {
 	// Search for the comment by its ID in the database
 	err := repo.db.Where("id = ?", id).First(&comment).Error
 	if err != nil {
-		return nil, err // if not found or any other error, return error
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil // No comment found, return nil
+		}
+		return nil, err // Other errors
 	}
 	return &comment, nil
 }
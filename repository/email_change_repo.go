@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errEmailChanged rolls back Apply when the user no longer has the old email
+var errEmailChanged = errors.New("email changed since the request")
+
+// EmailChangeRepository handles database operations for pending email changes
+type EmailChangeRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailChangeRepository creates a new instance of EmailChangeRepository
+func NewEmailChangeRepository(db *gorm.DB) *EmailChangeRepository {
+	return &EmailChangeRepository{db: db}
+}
+
+// Create inserts a new email change into the database
+func (repo *EmailChangeRepository) Create(change *models.EmailChange) error {
+	return repo.db.Create(change).Error
+}
+
+// GetPendingByUserID retrieves the user's unexpired pending email change
+func (repo *EmailChangeRepository) GetPendingByUserID(userID string) (*models.EmailChange, error) {
+	var change models.EmailChange
+	err := repo.db.Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.EmailChangePending, time.Now().UTC()).
+		Order("created_at DESC").
+		First(&change).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &change, nil
+}
+
+// CancelPendingByUserID ends every pending email change of the user
+func (repo *EmailChangeRepository) CancelPendingByUserID(userID string) error {
+	return repo.db.Model(&models.EmailChange{}).
+		Where("user_id = ? AND status = ?", userID, models.EmailChangePending).
+		Updates(map[string]interface{}{"status": models.EmailChangeCancelled, "completed_at": time.Now().UTC()}).Error
+}
+
+// Complete moves a pending email change to its final status. It reports false when the change
+// was no longer pending.
+func (repo *EmailChangeRepository) Complete(id string, status models.EmailChangeStatus) (bool, error) {
+	result := repo.db.Model(&models.EmailChange{}).
+		Where("id = ? AND status = ?", id, models.EmailChangePending).
+		Updates(map[string]interface{}{"status": status, "completed_at": time.Now().UTC()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Apply swaps the user's email and marks the change as confirmed in one transaction. The swap only
+// happens while the user still has the old email; ErrDuplicateKey is returned when the new email
+// was taken in the meantime.
+func (repo *EmailChangeRepository) Apply(change *models.EmailChange) (bool, error) {
+	applied := false
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.EmailChange{}).
+			Where("id = ? AND status = ?", change.ID, models.EmailChangePending).
+			Updates(map[string]interface{}{"status": models.EmailChangeConfirmed, "completed_at": time.Now().UTC()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		// The link was sent to the new address, so confirming it also verifies it
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND email = ?", change.UserID, change.OldEmail).
+			Updates(map[string]interface{}{"email": change.NewEmail, "verified": true})
+		if result.Error != nil {
+			if isDuplicateKey(result.Error) {
+				return ErrDuplicateKey
+			}
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// The email changed some other way since the request; roll back the confirmation
+			return errEmailChanged
+		}
+
+		applied = true
+		return nil
+	})
+	if errors.Is(err, errEmailChanged) {
+		return false, nil
+	}
+	return applied, err
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrDuplicateKey is returned when a write violates a unique constraint
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// mysqlDuplicateEntry is MySQL's error number for a unique constraint violation
+const mysqlDuplicateEntry = 1062
+
+// isDuplicateKey reports whether err is a unique constraint violation
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// IdentityRepository handles database operations for external identities and pending social logins
+type IdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewIdentityRepository creates a new instance of IdentityRepository
+func NewIdentityRepository(db *gorm.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+// Create links a new external identity to a user
+func (repo *IdentityRepository) Create(identity *models.UserIdentity) error {
+	return repo.db.Create(identity).Error
+}
+
+// GetByProviderSubject retrieves the identity a provider knows under the given subject
+func (repo *IdentityRepository) GetByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := repo.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// CreateState stores the secrets of a started social login
+func (repo *IdentityRepository) CreateState(state *models.OAuthState) error {
+	return repo.db.Create(state).Error
+}
+
+// ConsumeState retrieves and deletes a pending social login, so each state can only be used once
+func (repo *IdentityRepository) ConsumeState(state string) (*models.OAuthState, error) {
+	var pending models.OAuthState
+	if err := repo.db.Where("state = ?", state).First(&pending).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Only the request that actually deletes the row gets to use it
+	result := repo.db.Where("state = ?", state).Delete(&models.OAuthState{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &pending, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrUnbalancedTransfer is returned for a transfer whose debits do not add up to its credits
+var ErrUnbalancedTransfer = errors.New("unbalanced ledger transfer")
+
+// LedgerRepository handles database operations for the double-entry ledger
+type LedgerRepository struct {
+	db *gorm.DB
+}
+
+// NewLedgerRepository creates a new instance of LedgerRepository
+func NewLedgerRepository(db *gorm.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// Post records the entries of one money movement under a new transfer ID. Every entry must either
+// debit or credit a positive amount, and the debits must add up to the credits.
+func (repo *LedgerRepository) Post(paymentID uuid.UUID, entries []models.LedgerEntry) error {
+	var debits, credits int64
+	for _, entry := range entries {
+		if entry.Debit < 0 || entry.Credit < 0 || (entry.Debit == 0) == (entry.Credit == 0) {
+			return fmt.Errorf("%w: each entry must either debit or credit a positive amount", ErrUnbalancedTransfer)
+		}
+		debits += entry.Debit
+		credits += entry.Credit
+	}
+	if len(entries) < 2 || debits != credits {
+		return fmt.Errorf("%w: debits %d, credits %d", ErrUnbalancedTransfer, debits, credits)
+	}
+
+	transferID := uuid.New()
+	now := time.Now().UTC()
+	for i := range entries {
+		entries[i].ID = uuid.New()
+		entries[i].TransferID = transferID
+		entries[i].PaymentID = paymentID
+		entries[i].CreatedAt = now
+	}
+	return repo.db.Create(&entries).Error
+}
+
+// ListByPaymentID retrieves the entries of every movement of a payment, oldest first
+func (repo *LedgerRepository) ListByPaymentID(paymentID uuid.UUID) ([]models.LedgerEntry, error) {
+	var entries []models.LedgerEntry
+	if err := repo.db.
+		Where("payment_id = ?", paymentID).
+		Order("created_at ASC, transfer_id ASC, credit ASC").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Balance returns the credits minus the debits of an account, e.g. what the platform owes a seller.
+// A nil userID sums the account over all users.
+func (repo *LedgerRepository) Balance(account models.LedgerAccount, userID *uuid.UUID) (int64, error) {
+	query := repo.db.Model(&models.LedgerEntry{}).Where("account = ?", account)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var balance int64
+	if err := query.Select("COALESCE(SUM(credit) - SUM(debit), 0)").Scan(&balance).Error; err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
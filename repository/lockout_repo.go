@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"backend/models"
+	"backend/pagination"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LockoutRepository handles database operations for login attempts and throttling counters
+type LockoutRepository struct {
+	db *gorm.DB
+}
+
+// NewLockoutRepository creates a new instance of LockoutRepository
+func NewLockoutRepository(db *gorm.DB) *LockoutRepository {
+	return &LockoutRepository{db: db}
+}
+
+// CreateAttempt inserts a login attempt into the history
+func (repo *LockoutRepository) CreateAttempt(attempt *models.LoginAttempt) error {
+	return repo.db.Create(attempt).Error
+}
+
+// ListAttemptsByUserID retrieves a page of the login history of a user, newest first
+func (repo *LockoutRepository) ListAttemptsByUserID(userID string, params pagination.Params) ([]models.LoginAttempt, pagination.Page, error) {
+	query := repo.db.Model(&models.LoginAttempt{}).Where("user_id = ?", userID)
+	total, err := pagination.Count(query, params)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	var attempts []models.LoginAttempt
+	if err := pagination.Keyset(query, "login_attempts", params).Find(&attempts).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	attempts, page := pagination.Finish(attempts, params, total, func(a models.LoginAttempt) (time.Time, uuid.UUID) {
+		return a.CreatedAt, a.ID
+	})
+	return attempts, page, nil
+}
+
+// GetCounter retrieves the counter for a throttling key
+func (repo *LockoutRepository) GetCounter(key string) (*models.AttemptCounter, error) {
+	var counter models.AttemptCounter
+	if err := repo.db.First(&counter, "`key` = ?", key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &counter, nil
+}
+
+// UpdateCounter loads the counter for a key (a zero one if there is none yet), applies update to it
+// and saves it, all under a row lock so concurrent failures are not lost
+func (repo *LockoutRepository) UpdateCounter(key string, update func(counter *models.AttemptCounter)) (*models.AttemptCounter, error) {
+	var counter models.AttemptCounter
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&counter, "`key` = ?", key).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			counter = models.AttemptCounter{Key: key}
+		} else if err != nil {
+			return err
+		}
+
+		update(&counter)
+		return tx.Save(&counter).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &counter, nil
+}
+
+// DeleteCounter clears the failures recorded for a throttling key
+func (repo *LockoutRepository) DeleteCounter(key string) error {
+	return repo.db.Where("`key` = ?", key).Delete(&models.AttemptCounter{}).Error
+}
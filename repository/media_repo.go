@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MediaRepository handles database operations for product and transaction images
+type MediaRepository struct {
+	db *gorm.DB
+}
+
+// NewMediaRepository creates a new instance of MediaRepository
+func NewMediaRepository(db *gorm.DB) *MediaRepository {
+	return &MediaRepository{db: db}
+}
+
+// Create inserts gallery images
+func (repo *MediaRepository) Create(media []models.Media) error {
+	if len(media) == 0 {
+		return nil
+	}
+	return repo.db.Create(&media).Error
+}
+
+// GetByID retrieves a gallery image by its ID
+func (repo *MediaRepository) GetByID(id uuid.UUID) (*models.Media, error) {
+	var media models.Media
+	if err := repo.db.First(&media, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &media, nil
+}
+
+// GetByProductID retrieves a product's gallery in display order
+func (repo *MediaRepository) GetByProductID(productID uuid.UUID) ([]models.Media, error) {
+	var media []models.Media
+	if err := repo.db.Where("product_id = ?", productID).
+		Order("position ASC, created_at ASC").
+		Find(&media).Error; err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// GetByUploader retrieves the images a user uploaded
+func (repo *MediaRepository) GetByUploader(userID uuid.UUID) ([]models.Media, error) {
+	var media []models.Media
+	if err := repo.db.Where("uploaded_by = ?", userID).Order("created_at ASC").Find(&media).Error; err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// NextPosition returns the position after the last image of a product's gallery
+func (repo *MediaRepository) NextPosition(productID uuid.UUID) (int, error) {
+	var last *int
+	if err := repo.db.Model(&models.Media{}).
+		Where("product_id = ?", productID).
+		Select("MAX(position)").
+		Scan(&last).Error; err != nil {
+		return 0, err
+	}
+	if last == nil {
+		return 0, nil
+	}
+	return *last + 1, nil
+}
+
+// UpdateCaption saves the caption of a gallery image
+func (repo *MediaRepository) UpdateCaption(id uuid.UUID, caption string) error {
+	return repo.db.Model(&models.Media{}).Where("id = ?", id).Update("caption", caption).Error
+}
+
+// SetCover makes one image the cover of its product's gallery and clears the flag on the others
+func (repo *MediaRepository) SetCover(productID, mediaID uuid.UUID) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Media{}).
+			Where("product_id = ? AND is_cover = ?", productID, true).
+			Update("is_cover", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Media{}).Where("id = ?", mediaID).Update("is_cover", true).Error
+	})
+}
+
+// Reorder stores the new positions of a product's gallery images, given in display order
+func (repo *MediaRepository) Reorder(productID uuid.UUID, mediaIDs []uuid.UUID) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		for position, id := range mediaIDs {
+			if err := tx.Model(&models.Media{}).
+				Where("id = ? AND product_id = ?", id, productID).
+				Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes a gallery image. A transaction that used it as its own image keeps the key, which is
+// part of its provenance hash.
+func (repo *MediaRepository) Delete(media *models.Media) error {
+	return repo.db.Delete(&models.Media{}, "id = ?", media.ID).Error
+}
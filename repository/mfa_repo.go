@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MFARepository handles database operations for two-factor authentication
+type MFARepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new instance of MFARepository
+func NewMFARepository(db *gorm.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// GetByUserID retrieves the TOTP settings of a user
+func (repo *MFARepository) GetByUserID(userID string) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	if err := repo.db.First(&mfa, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// Save creates or replaces the TOTP settings of a user
+func (repo *MFARepository) Save(mfa *models.UserMFA) error {
+	return repo.db.Save(mfa).Error
+}
+
+// Enable switches on two-factor authentication and replaces the user's recovery codes, in one transaction
+func (repo *MFARepository) Enable(userID string, step int64, codes []models.RecoveryCode) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserMFA{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"enabled":        true,
+			"enabled_at":     time.Now().UTC(),
+			"last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// MarkStepUsed records an accepted TOTP step, unless a later or equal step was already used.
+// It reports false when the code was replayed.
+func (repo *MFARepository) MarkStepUsed(userID string, step int64) (bool, error) {
+	result := repo.db.Model(&models.UserMFA{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It reports false when no such code exists.
+func (repo *MFARepository) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	result := repo.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now().UTC())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Delete removes the TOTP settings and recovery codes of a user
+func (repo *MFARepository) Delete(userID string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.UserMFA{}).Error
+	})
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OneTimeTokenRepository handles database operations for single-use tokens
+type OneTimeTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewOneTimeTokenRepository creates a new instance of OneTimeTokenRepository
+func NewOneTimeTokenRepository(db *gorm.DB) *OneTimeTokenRepository {
+	return &OneTimeTokenRepository{db: db}
+}
+
+// Create inserts a new single-use token into the database
+func (repo *OneTimeTokenRepository) Create(token *models.OneTimeToken) error {
+	return repo.db.Create(token).Error
+}
+
+// Consume marks an unexpired token as used. It reports false when the token is unknown,
+// expired or was already consumed, so only one caller can ever redeem it.
+func (repo *OneTimeTokenRepository) Consume(jti, userID, purpose string) (bool, error) {
+	now := time.Now().UTC()
+	result := repo.db.Model(&models.OneTimeToken{}).
+		Where("jti = ? AND user_id = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", jti, userID, purpose, now).
+		Update("consumed_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ConsumeAllByUserID invalidates every outstanding token of a user for the given purpose
+func (repo *OneTimeTokenRepository) ConsumeAllByUserID(userID, purpose string) error {
+	return repo.db.Model(&models.OneTimeToken{}).
+		Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", userID, purpose).
+		Update("consumed_at", time.Now().UTC()).Error
+}
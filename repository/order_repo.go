@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"backend/models"
+	"backend/pagination"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrderRepository handles database operations for orders
+type OrderRepository struct {
+	db *gorm.DB
+}
+
+// NewOrderRepository creates a new instance of OrderRepository
+func NewOrderRepository(db *gorm.DB) *OrderRepository {
+	return &OrderRepository{db: db}
+}
+
+// Create inserts a new order
+func (repo *OrderRepository) Create(order *models.Order) error {
+	return repo.db.Create(order).Error
+}
+
+// GetByID retrieves an order by its ID
+func (repo *OrderRepository) GetByID(id uuid.UUID) (*models.Order, error) {
+	var order models.Order
+	if err := repo.db.First(&order, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetActiveByProductID retrieves the order that holds a product's reservation, if any
+func (repo *OrderRepository) GetActiveByProductID(productID uuid.UUID) (*models.Order, error) {
+	var order models.Order
+	if err := repo.db.
+		Where("product_id = ? AND status IN ?", productID, models.ActiveOrderStatuses).
+		Order("created_at DESC").
+		First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// List retrieves a page of a user's orders, newest first. With asBuyer or asSeller only the orders
+// in which the user has that part are returned; an empty status returns orders in every status.
+func (repo *OrderRepository) List(userID uuid.UUID, asBuyer, asSeller bool, status models.OrderStatus, params pagination.Params) ([]models.Order, pagination.Page, error) {
+	query := repo.db.Model(&models.Order{})
+	switch {
+	case asBuyer && !asSeller:
+		query = query.Where("buyer_id = ?", userID)
+	case asSeller && !asBuyer:
+		query = query.Where("seller_id = ?", userID)
+	default:
+		query = query.Where("buyer_id = ? OR seller_id = ?", userID, userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	total, err := pagination.Count(query, params)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	var orders []models.Order
+	if err := pagination.Keyset(query, "orders", params).Find(&orders).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	orders, page := pagination.Finish(orders, params, total, func(o models.Order) (time.Time, uuid.UUID) {
+		return o.CreatedAt, o.ID
+	})
+	return orders, page, nil
+}
+
+// ListExpired retrieves unpaid orders whose reservation has run out
+func (repo *OrderRepository) ListExpired(now time.Time, limit int) ([]models.Order, error) {
+	var orders []models.Order
+	if err := repo.db.
+		Where("status IN ? AND reserved_until <= ?", []models.OrderStatus{models.OrderPending, models.OrderAccepted}, now).
+		Order("reserved_until ASC").
+		Limit(limit).
+		Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// UpdateStatus moves an order from one status to another and applies further changes along with it.
+// It reports false when the order was no longer in the expected status, e.g. because the other party
+// or the timeout took a step first.
+func (repo *OrderRepository) UpdateStatus(id uuid.UUID, from, to models.OrderStatus, changes map[string]interface{}) (bool, error) {
+	updates := map[string]interface{}{"status": to, "updated_at": time.Now().UTC()}
+	for column, value := range changes {
+		updates[column] = value
+	}
+	result := repo.db.Model(&models.Order{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PaymentRepository handles database operations for order payments
+type PaymentRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentRepository creates a new instance of PaymentRepository
+func NewPaymentRepository(db *gorm.DB) *PaymentRepository {
+	return &PaymentRepository{db: db}
+}
+
+// Create inserts a new payment
+func (repo *PaymentRepository) Create(payment *models.Payment) error {
+	return repo.db.Create(payment).Error
+}
+
+// GetByOrderID retrieves the payment for an order, if it was paid
+func (repo *PaymentRepository) GetByOrderID(orderID uuid.UUID) (*models.Payment, error) {
+	return repo.first("order_id = ?", orderID)
+}
+
+// GetByCaptureID retrieves the payment the provider knows by a capture ID
+func (repo *PaymentRepository) GetByCaptureID(captureID string) (*models.Payment, error) {
+	return repo.first("capture_id = ?", captureID)
+}
+
+func (repo *PaymentRepository) first(query string, args ...interface{}) (*models.Payment, error) {
+	var payment models.Payment
+	if err := repo.db.Where(query, args...).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// UpdateStatus moves a payment from one status to another and applies further changes along with it.
+// It reports false when the payment was no longer in the expected status.
+func (repo *PaymentRepository) UpdateStatus(id uuid.UUID, from, to models.PaymentStatus, changes map[string]interface{}) (bool, error) {
+	updates := map[string]interface{}{"status": to, "updated_at": time.Now().UTC()}
+	for column, value := range changes {
+		updates[column] = value
+	}
+	result := repo.db.Model(&models.Payment{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PrivacyRepository handles database operations for privacy settings
+type PrivacyRepository struct {
+	db *gorm.DB
+}
+
+// NewPrivacyRepository creates a new instance of PrivacyRepository
+func NewPrivacyRepository(db *gorm.DB) *PrivacyRepository {
+	return &PrivacyRepository{db: db}
+}
+
+// GetByUserID retrieves the privacy settings of a user
+func (repo *PrivacyRepository) GetByUserID(userID string) (*models.UserPrivacy, error) {
+	var privacy models.UserPrivacy
+	if err := repo.db.First(&privacy, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &privacy, nil
+}
+
+// GetByUserIDs retrieves the privacy settings of several users, keyed by user ID.
+// Users without settings are missing from the map.
+func (repo *PrivacyRepository) GetByUserIDs(userIDs []uuid.UUID) (map[uuid.UUID]models.UserPrivacy, error) {
+	result := make(map[uuid.UUID]models.UserPrivacy, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var settings []models.UserPrivacy
+	if err := repo.db.Where("user_id IN ?", userIDs).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	for _, privacy := range settings {
+		result[privacy.UserID] = privacy
+	}
+	return result, nil
+}
+
+// Save creates or replaces the privacy settings of a user
+func (repo *PrivacyRepository) Save(privacy *models.UserPrivacy) error {
+	return repo.db.Save(privacy).Error
+}
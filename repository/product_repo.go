@@ -2,9 +2,12 @@ package repository
 
 import (
 	"backend/models"
+	"backend/pagination"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ProductRepository handles database operations for products
@@ -31,9 +34,32 @@ func (repo *ProductRepository) Update(product *models.Product) error {
 	return nil
 }
 
-// Delete removes a product from the database by ID
-func (r *ProductRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.Product{}, id).Error
+// UpdateFields applies a partial update to a product
+func (repo *ProductRepository) UpdateFields(productID uuid.UUID, changes map[string]interface{}) error {
+	return repo.db.Model(&models.Product{}).Where("id = ?", productID).Updates(changes).Error
+}
+
+// SetStatusIf changes the status of a product only if it still has the expected one. It reports false
+// when the status had changed in the meantime.
+func (repo *ProductRepository) SetStatusIf(productID uuid.UUID, from, to models.ProductStatus, changes map[string]interface{}) (bool, error) {
+	updates := map[string]interface{}{"status": to}
+	for column, value := range changes {
+		updates[column] = value
+	}
+	result := repo.db.Model(&models.Product{}).Where("id = ? AND status = ?", productID, from).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// MySQL counts the rows an update changed, not the ones it matched, so an update that leaves the
+	// product as it was affects none. A locking read tells that apart from a status that moved on.
+	var matched int64
+	err := repo.db.Model(&models.Product{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND status = ?", productID, from).Count(&matched).Error
+	return matched > 0, err
 }
 
 // GetByID retrieves a product by its ID
@@ -58,56 +84,50 @@ func (r *ProductRepository) GetProductsByIDs(ids []uuid.UUID) ([]models.Product,
 func (r *ProductRepository) GetRandomProducts() ([]models.Product, error) {
 	var products []models.Product
 	// Adjust the limit as needed
-	if err := r.db.Order("RAND()").Limit(10).Find(&products).Error; err != nil {
+	if err := r.db.Where("status <> ?", models.StatusWithdrawn).Order("RAND()").Limit(10).Find(&products).Error; err != nil {
 		return nil, err
 	}
 	return products, nil
 }
 
-// GetProductsByUserID retrieves products for a specific user by their UUID with pagination
-func (r *ProductRepository) GetProductsByUserID(userID uuid.UUID, count, offset int) ([]models.Product, error) {
-	var products []models.Product
-	// Add limit and offset for pagination
-	if err := r.db.Where("user_id = ?", userID).Limit(count).Offset(offset).Find(&products).Error; err != nil {
-		return nil, err
-	}
-	return products, nil
+// GetProductsByUserID retrieves a page of a user's products, newest first
+func (r *ProductRepository) GetProductsByUserID(userID uuid.UUID, params pagination.Params) ([]models.Product, pagination.Page, error) {
+	return r.page(r.db.Model(&models.Product{}).Where("user_id = ?", userID), params)
 }
 
-// GetByStatus retrieves 10 random products by their status// GetByStatusPaginated retrieves products by any given status with pagination
-func (repo *ProductRepository) GetByStatusPaginated(status string, limit int, offset int) ([]models.Product, error) {
+// GetAllByUserID retrieves every product of a user, oldest first
+func (r *ProductRepository) GetAllByUserID(userID uuid.UUID) ([]models.Product, error) {
 	var products []models.Product
-
-	// Query to fetch products by status with pagination
-	err := repo.db.
-		Where("status = ?", status). // Filter by the specified status
-		Order("created_at DESC").    // Order by CreatedAt in descending order
-		Limit(limit).                // Limit results for pagination
-		Offset(offset).              // Start from the specified offset
-		Find(&products).             // Execute query
-		Error
-
-	if err != nil {
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&products).Error; err != nil {
 		return nil, err
 	}
-
 	return products, nil
 }
 
-func (repo *ProductRepository) GetRandomProductsPaginated(count int, offset int) ([]models.Product, error) {
-	var products []models.Product
+// GetByStatusPaginated retrieves a page of products with the given status, newest first
+func (repo *ProductRepository) GetByStatusPaginated(status string, params pagination.Params) ([]models.Product, pagination.Page, error) {
+	return repo.page(repo.db.Model(&models.Product{}).Where("status = ?", status), params)
+}
 
-	// Query to fetch random products with pagination, ordered by CreatedAt
-	err := repo.db.
-		Order("created_at DESC"). // Ensure they're ordered by CreatedAt descending
-		Limit(count).             // Limit the results to the count
-		Offset(offset).           // Start from the specified offset
-		Find(&products).          // Perform the query and load results into products
-		Error
+// GetRandomProductsPaginated retrieves a page of the products still listed, newest first
+func (repo *ProductRepository) GetRandomProductsPaginated(params pagination.Params) ([]models.Product, pagination.Page, error) {
+	// Withdrawn listings are not for sale anymore
+	return repo.page(repo.db.Model(&models.Product{}).Where("status <> ?", models.StatusWithdrawn), params)
+}
 
+// page fetches one page of a product query along with the total, when asked for
+func (repo *ProductRepository) page(query *gorm.DB, params pagination.Params) ([]models.Product, pagination.Page, error) {
+	total, err := pagination.Count(query, params)
 	if err != nil {
-		return nil, err
+		return nil, pagination.Page{}, err
 	}
 
-	return products, nil
+	var products []models.Product
+	if err := pagination.Keyset(query, "products", params).Find(&products).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	products, page := pagination.Finish(products, params, total, func(p models.Product) (time.Time, uuid.UUID) {
+		return p.CreatedAt, p.ID
+	})
+	return products, page, nil
 }
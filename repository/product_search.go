@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"backend/models"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProductSearcher searches products by text with filters, sorting and facet counts.
+// Withdrawn products are never returned.
+type ProductSearcher interface {
+	Search(query models.ProductSearchQuery) (*models.ProductSearchResult, error)
+}
+
+// minFulltextTermLength is InnoDB's default innodb_ft_min_token_size; shorter queries fall back to LIKE
+const minFulltextTermLength = 3
+
+// fulltextMatch matches products against the FULLTEXT index on name and description
+const fulltextMatch = "MATCH(products.name, products.description) AGAINST (? IN NATURAL LANGUAGE MODE)"
+
+// FulltextProductSearch searches products with MySQL's FULLTEXT index on products(name, description)
+type FulltextProductSearch struct {
+	db *gorm.DB
+}
+
+// NewFulltextProductSearch creates a new instance of FulltextProductSearch
+func NewFulltextProductSearch(db *gorm.DB) *FulltextProductSearch {
+	return &FulltextProductSearch{db: db}
+}
+
+// Facets are computed without their own filter
+const (
+	facetNone     = ""
+	facetCategory = "category"
+	facetStatus   = "status"
+)
+
+// filtered applies the query's text and filters, except the one of the facet being counted
+func (s *FulltextProductSearch) filtered(query models.ProductSearchQuery, skip string) *gorm.DB {
+	tx := s.db.Model(&models.Product{}).Where("products.status <> ?", models.StatusWithdrawn)
+
+	if text := strings.TrimSpace(query.Text); text != "" {
+		if len(text) < minFulltextTermLength {
+			like := "%" + text + "%"
+			tx = tx.Where("products.name LIKE ? OR products.description LIKE ?", like, like)
+		} else {
+			tx = tx.Where(fulltextMatch, text)
+		}
+	}
+	if query.Category != "" && skip != facetCategory {
+		tx = tx.Where("products.category = ?", query.Category)
+	}
+	if query.Status != "" && skip != facetStatus {
+		tx = tx.Where("products.status = ?", query.Status)
+	}
+	if query.SubCategory != "" {
+		tx = tx.Where("products.sub_category = ?", query.SubCategory)
+	}
+	if query.MinPrice != nil {
+		tx = tx.Where("products.price >= ?", *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		tx = tx.Where("products.price <= ?", *query.MaxPrice)
+	}
+	if query.CreatedFrom != nil {
+		tx = tx.Where("products.created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		tx = tx.Where("products.created_at <= ?", *query.CreatedTo)
+	}
+	return tx
+}
+
+// Search runs the query against the database
+func (s *FulltextProductSearch) Search(query models.ProductSearchQuery) (*models.ProductSearchResult, error) {
+	result := &models.ProductSearchResult{}
+
+	if err := s.filtered(query, facetNone).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	tx := s.filtered(query, facetNone).Select("products.*")
+	text := strings.TrimSpace(query.Text)
+
+	// Newest first breaks ties, and keeps pages stable
+	const tieBreaker = "products.created_at DESC, products.id DESC"
+	switch query.Sort {
+	case models.SortPriceAsc:
+		tx = tx.Order("products.price ASC, " + tieBreaker)
+	case models.SortPriceDesc:
+		tx = tx.Order("products.price DESC, " + tieBreaker)
+	case models.SortRating:
+		tx = tx.Joins("LEFT JOIN (SELECT product_id, AVG(score) AS avg_score FROM ratings GROUP BY product_id) AS product_ratings ON product_ratings.product_id = products.id").
+			Order("COALESCE(product_ratings.avg_score, 0) DESC, " + tieBreaker)
+	default:
+		if query.Sort != models.SortNewest && len(text) >= minFulltextTermLength {
+			// The match score needs a bound parameter, which only an expression clause can carry
+			tx = tx.Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:                fulltextMatch + " DESC, " + tieBreaker,
+				Vars:               []interface{}{text},
+				WithoutParentheses: true,
+			}})
+		} else {
+			tx = tx.Order(tieBreaker)
+		}
+	}
+
+	if err := tx.Limit(query.Limit).Offset(query.Offset).Find(&result.Products).Error; err != nil {
+		return nil, err
+	}
+
+	facets, err := s.facets(query)
+	if err != nil {
+		return nil, err
+	}
+	result.Facets = *facets
+	return result, nil
+}
+
+// facets counts the matches per category and per status
+func (s *FulltextProductSearch) facets(query models.ProductSearchQuery) (*models.ProductFacets, error) {
+	facets := &models.ProductFacets{
+		Categories: map[string]int64{},
+		Statuses:   map[models.ProductStatus]int64{},
+	}
+
+	var categories []struct {
+		Category string
+		Count    int64
+	}
+	if err := s.filtered(query, facetCategory).
+		Select("products.category AS category, COUNT(*) AS count").
+		Group("products.category").
+		Scan(&categories).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range categories {
+		facets.Categories[row.Category] = row.Count
+	}
+
+	var statuses []struct {
+		Status models.ProductStatus
+		Count  int64
+	}
+	if err := s.filtered(query, facetStatus).
+		Select("products.status AS status, COUNT(*) AS count").
+		Group("products.status").
+		Scan(&statuses).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range statuses {
+		facets.Statuses[row.Status] = row.Count
+	}
+
+	return facets, nil
+}
+
+// MemoryProductSearch searches a fixed set of products in memory. It behaves like FulltextProductSearch,
+// with relevance approximated by counting term occurrences, and is meant for tests and local tooling.
+type MemoryProductSearch struct {
+	products []models.Product
+	ratings  map[uuid.UUID]float64 // Average rating per product
+}
+
+// NewMemoryProductSearch creates a new instance of MemoryProductSearch over the given products and
+// their average ratings
+func NewMemoryProductSearch(products []models.Product, ratings map[uuid.UUID]float64) *MemoryProductSearch {
+	return &MemoryProductSearch{products: products, ratings: ratings}
+}
+
+// matches reports whether a product passes the query's filters, except the one of the facet being counted
+func (s *MemoryProductSearch) matches(p models.Product, query models.ProductSearchQuery, skip string) bool {
+	switch {
+	case p.Status == models.StatusWithdrawn:
+		return false
+	case strings.TrimSpace(query.Text) != "" && relevance(p, query.Text) == 0:
+		return false
+	case query.Category != "" && skip != facetCategory && p.Category != query.Category:
+		return false
+	case query.Status != "" && skip != facetStatus && p.Status != query.Status:
+		return false
+	case query.SubCategory != "" && p.SubCategory != query.SubCategory:
+		return false
+	case query.MinPrice != nil && p.Price < *query.MinPrice:
+		return false
+	case query.MaxPrice != nil && p.Price > *query.MaxPrice:
+		return false
+	case query.CreatedFrom != nil && p.CreatedAt.Before(*query.CreatedFrom):
+		return false
+	case query.CreatedTo != nil && p.CreatedAt.After(*query.CreatedTo):
+		return false
+	}
+	return true
+}
+
+// Search runs the query over the products
+func (s *MemoryProductSearch) Search(query models.ProductSearchQuery) (*models.ProductSearchResult, error) {
+	result := &models.ProductSearchResult{
+		Facets: models.ProductFacets{
+			Categories: map[string]int64{},
+			Statuses:   map[models.ProductStatus]int64{},
+		},
+	}
+
+	var found []models.Product
+	for _, p := range s.products {
+		if s.matches(p, query, facetNone) {
+			found = append(found, p)
+		}
+		if s.matches(p, query, facetCategory) {
+			result.Facets.Categories[p.Category]++
+		}
+		if s.matches(p, query, facetStatus) {
+			result.Facets.Statuses[p.Status]++
+		}
+	}
+	result.Total = int64(len(found))
+
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		switch query.Sort {
+		case models.SortPriceAsc:
+			if a.Price != b.Price {
+				return a.Price < b.Price
+			}
+		case models.SortPriceDesc:
+			if a.Price != b.Price {
+				return a.Price > b.Price
+			}
+		case models.SortRating:
+			if s.ratings[a.ID] != s.ratings[b.ID] {
+				return s.ratings[a.ID] > s.ratings[b.ID]
+			}
+		case models.SortNewest:
+		default:
+			if ra, rb := relevance(a, query.Text), relevance(b, query.Text); ra != rb {
+				return ra > rb
+			}
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID.String() > b.ID.String()
+	})
+
+	start := query.Offset
+	if start > len(found) {
+		start = len(found)
+	}
+	end := len(found)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+	}
+	result.Products = found[start:end]
+	return result, nil
+}
+
+// relevance counts how often the query's terms occur in a product, with name matches counting double
+func relevance(p models.Product, text string) int {
+	name := strings.ToLower(p.Name)
+	description := strings.ToLower(p.Description)
+
+	score := 0
+	for _, term := range strings.Fields(strings.ToLower(text)) {
+		score += 2*strings.Count(name, term) + strings.Count(description, term)
+	}
+	return score
+}
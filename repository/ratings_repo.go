@@ -3,7 +3,8 @@ package repository
 
 import (
 	"backend/models"
-	"log"
+	"backend/pagination"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -29,6 +30,56 @@ func (repo *RatingRepository) Delete(id uuid.UUID) error {
 	return repo.db.Delete(&models.Rating{}, "id = ?", id).Error
 }
 
+// GetByID retrieves a rating by its ID
+func (repo *RatingRepository) GetByID(id uuid.UUID) (*models.Rating, error) {
+	var rating models.Rating
+	err := repo.db.Where("id = ?", id).First(&rating).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil // No rating found, return nil
+		}
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// List retrieves a page of all ratings, oldest first, for bulk export
+func (repo *RatingRepository) List(params pagination.Params) ([]models.Rating, pagination.Page, error) {
+	query := repo.db.Model(&models.Rating{})
+	total, err := pagination.Count(query, params)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	var ratings []models.Rating
+	if err := pagination.KeysetOldestFirst(query, "ratings", params).Find(&ratings).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	ratings, page := pagination.Finish(ratings, params, total, ratingKey)
+	return ratings, page, nil
+}
+
+// ListByUserID retrieves a page of the ratings a user gave, newest first
+func (repo *RatingRepository) ListByUserID(userID uuid.UUID, params pagination.Params) ([]models.Rating, pagination.Page, error) {
+	query := repo.db.Model(&models.Rating{}).Where("user_id = ?", userID)
+	total, err := pagination.Count(query, params)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	var ratings []models.Rating
+	if err := pagination.Keyset(query, "ratings", params).Find(&ratings).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	ratings, page := pagination.Finish(ratings, params, total, ratingKey)
+	return ratings, page, nil
+}
+
+// ratingKey is the pagination key of a rating
+func ratingKey(r models.Rating) (time.Time, uuid.UUID) {
+	return r.CreatedAt, r.ID
+}
+
 // GetRatedProductsByUserId retrieves all rated products by a user's ID
 func (repo *RatingRepository) GetRatedProductsByUserId(userID uuid.UUID) ([]models.Rating, error) {
 	var ratings []models.Rating
@@ -80,17 +131,3 @@ func (repo *RatingRepository) Update(rating *models.Rating) error {
 	}
 	return nil
 }
-
-// GetRatedItemsByUserID fetches product IDs rated by a specific user.
-func (repo *RatingRepository) GetRatedItemsByUserID(userID string) ([]string, error) {
-	var productIDs []string
-
-	// Fetch the ratings made by the user from the database
-	err := repo.db.Table("ratings").Where("user_id = ?", userID).Pluck("product_id", &productIDs).Error
-	if err != nil {
-		log.Printf("Error fetching rated items for user ID %s: %v", userID, err) // Log the error for debugging
-		return nil, err
-	}
-
-	return productIDs, nil
-}
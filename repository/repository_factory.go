@@ -34,3 +34,93 @@ func (f *RepositoryFactory) GetTransactionRepository() *TransactionRepository {
 func (f *RepositoryFactory) GetCommentRepository() *CommentRepository {
 	return NewCommentRepository(f.db)
 }
+
+// GetSessionRepository returns a new instance of SessionRepository
+func (f *RepositoryFactory) GetSessionRepository() *SessionRepository {
+	return NewSessionRepository(f.db)
+}
+
+// GetIdentityRepository returns a new instance of IdentityRepository
+func (f *RepositoryFactory) GetIdentityRepository() *IdentityRepository {
+	return NewIdentityRepository(f.db)
+}
+
+// GetMFARepository returns a new instance of MFARepository
+func (f *RepositoryFactory) GetMFARepository() *MFARepository {
+	return NewMFARepository(f.db)
+}
+
+// GetLockoutRepository returns a new instance of LockoutRepository
+func (f *RepositoryFactory) GetLockoutRepository() *LockoutRepository {
+	return NewLockoutRepository(f.db)
+}
+
+// GetOneTimeTokenRepository returns a new instance of OneTimeTokenRepository
+func (f *RepositoryFactory) GetOneTimeTokenRepository() *OneTimeTokenRepository {
+	return NewOneTimeTokenRepository(f.db)
+}
+
+// GetEmailChangeRepository returns a new instance of EmailChangeRepository
+func (f *RepositoryFactory) GetEmailChangeRepository() *EmailChangeRepository {
+	return NewEmailChangeRepository(f.db)
+}
+
+// GetAPIKeyRepository returns a new instance of APIKeyRepository
+func (f *RepositoryFactory) GetAPIKeyRepository() *APIKeyRepository {
+	return NewAPIKeyRepository(f.db)
+}
+
+// GetAccountDeletionRepository returns a new instance of AccountDeletionRepository
+func (f *RepositoryFactory) GetAccountDeletionRepository() *AccountDeletionRepository {
+	return NewAccountDeletionRepository(f.db)
+}
+
+// GetPrivacyRepository returns a new instance of PrivacyRepository
+func (f *RepositoryFactory) GetPrivacyRepository() *PrivacyRepository {
+	return NewPrivacyRepository(f.db)
+}
+
+// GetProductSearcher returns the product search backed by the database's FULLTEXT index
+func (f *RepositoryFactory) GetProductSearcher() ProductSearcher {
+	return NewFulltextProductSearch(f.db)
+}
+
+// GetCategoryRepository returns a new instance of CategoryRepository
+func (f *RepositoryFactory) GetCategoryRepository() *CategoryRepository {
+	return NewCategoryRepository(f.db)
+}
+
+// GetMediaRepository returns a new instance of MediaRepository
+func (f *RepositoryFactory) GetMediaRepository() *MediaRepository {
+	return NewMediaRepository(f.db)
+}
+
+// GetUnitOfWork returns a new UnitOfWork for operations that span several repositories
+func (f *RepositoryFactory) GetUnitOfWork() *UnitOfWork {
+	return NewUnitOfWork(f.db)
+}
+
+// GetOrderRepository returns a new instance of OrderRepository
+func (f *RepositoryFactory) GetOrderRepository() *OrderRepository {
+	return NewOrderRepository(f.db)
+}
+
+// GetPaymentRepository returns a new instance of PaymentRepository
+func (f *RepositoryFactory) GetPaymentRepository() *PaymentRepository {
+	return NewPaymentRepository(f.db)
+}
+
+// GetLedgerRepository returns a new instance of LedgerRepository
+func (f *RepositoryFactory) GetLedgerRepository() *LedgerRepository {
+	return NewLedgerRepository(f.db)
+}
+
+// GetAmendmentRepository returns a new instance of AmendmentRepository
+func (f *RepositoryFactory) GetAmendmentRepository() *AmendmentRepository {
+	return NewAmendmentRepository(f.db)
+}
+
+// GetRevitalizationRepository returns a new instance of RevitalizationRepository
+func (f *RepositoryFactory) GetRevitalizationRepository() *RevitalizationRepository {
+	return NewRevitalizationRepository(f.db)
+}
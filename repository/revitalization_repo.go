@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"backend/models"
+	"backend/pagination"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RevitalizationRepository handles database operations for revitalization requests and their bids
+type RevitalizationRepository struct {
+	db *gorm.DB
+}
+
+// NewRevitalizationRepository creates a new instance of RevitalizationRepository
+func NewRevitalizationRepository(db *gorm.DB) *RevitalizationRepository {
+	return &RevitalizationRepository{db: db}
+}
+
+// Create inserts a new revitalization request
+func (repo *RevitalizationRepository) Create(request *models.RevitalizationRequest) error {
+	return repo.db.Create(request).Error
+}
+
+// GetByID retrieves a revitalization request by its ID
+func (repo *RevitalizationRepository) GetByID(id uuid.UUID) (*models.RevitalizationRequest, error) {
+	var request models.RevitalizationRequest
+	if err := repo.db.First(&request, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetByProductID retrieves the newest request of a product in one of the statuses, if any
+func (repo *RevitalizationRepository) GetByProductID(productID uuid.UUID, statuses []models.RevitalizationStatus) (*models.RevitalizationRequest, error) {
+	var request models.RevitalizationRequest
+	if err := repo.db.
+		Where("product_id = ? AND status IN ?", productID, statuses).
+		Order("created_at DESC").
+		First(&request).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// List retrieves a page of the requests in a status, newest first
+func (repo *RevitalizationRepository) List(status models.RevitalizationStatus, params pagination.Params) ([]models.RevitalizationRequest, pagination.Page, error) {
+	query := repo.db.Model(&models.RevitalizationRequest{}).Where("status = ?", status)
+
+	total, err := pagination.Count(query, params)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	var requests []models.RevitalizationRequest
+	if err := pagination.Keyset(query, "revitalization_requests", params).Find(&requests).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	requests, page := pagination.Finish(requests, params, total, func(r models.RevitalizationRequest) (time.Time, uuid.UUID) {
+		return r.CreatedAt, r.ID
+	})
+	return requests, page, nil
+}
+
+// UpdateStatus moves a request from one of the statuses in from to another and applies further changes
+// along with it. It reports false when the request was no longer in one of the expected statuses.
+func (repo *RevitalizationRepository) UpdateStatus(id uuid.UUID, from []models.RevitalizationStatus, to models.RevitalizationStatus, changes map[string]interface{}) (bool, error) {
+	updates := map[string]interface{}{"status": to, "updated_at": time.Now().UTC()}
+	for column, value := range changes {
+		updates[column] = value
+	}
+	result := repo.db.Model(&models.RevitalizationRequest{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CreateBid inserts a new bid. ErrDuplicateKey is returned when the workshop already bid on the request.
+func (repo *RevitalizationRepository) CreateBid(bid *models.RevitalizationBid) error {
+	if err := repo.db.Create(bid).Error; err != nil {
+		if isDuplicateKey(err) {
+			return ErrDuplicateKey
+		}
+		return err
+	}
+	return nil
+}
+
+// GetBid retrieves a bid on a request by its ID
+func (repo *RevitalizationRepository) GetBid(requestID, bidID uuid.UUID) (*models.RevitalizationBid, error) {
+	var bid models.RevitalizationBid
+	if err := repo.db.First(&bid, "id = ? AND request_id = ?", bidID, requestID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &bid, nil
+}
+
+// ListBids retrieves the bids on a request, cheapest first. With a workshop ID only that workshop's bid
+// is returned.
+func (repo *RevitalizationRepository) ListBids(requestID uuid.UUID, workshopID *uuid.UUID) ([]models.RevitalizationBid, error) {
+	query := repo.db.Where("request_id = ?", requestID)
+	if workshopID != nil {
+		query = query.Where("workshop_id = ?", *workshopID)
+	}
+
+	var bids []models.RevitalizationBid
+	if err := query.Order("price ASC, created_at ASC").Find(&bids).Error; err != nil {
+		return nil, err
+	}
+	return bids, nil
+}
+
+// ResolveBids settles the pending bids on a request: the accepted one, if any, is marked accepted and
+// all others rejected
+func (repo *RevitalizationRepository) ResolveBids(requestID uuid.UUID, acceptedID *uuid.UUID) error {
+	now := time.Now().UTC()
+	if acceptedID != nil {
+		if err := repo.db.Model(&models.RevitalizationBid{}).
+			Where("id = ? AND request_id = ?", *acceptedID, requestID).
+			Updates(map[string]interface{}{"status": models.BidAccepted, "updated_at": now}).Error; err != nil {
+			return err
+		}
+	}
+	return repo.db.Model(&models.RevitalizationBid{}).
+		Where("request_id = ? AND status = ?", requestID, models.BidPending).
+		Updates(map[string]interface{}{"status": models.BidRejected, "updated_at": now}).Error
+}
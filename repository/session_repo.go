@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"backend/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SessionRepository handles database operations for login sessions
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new instance of SessionRepository
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create inserts a new session into the database
+func (repo *SessionRepository) Create(session *models.Session) error {
+	return repo.db.Create(session).Error
+}
+
+// GetByID retrieves a session by its ID
+func (repo *SessionRepository) GetByID(id string) (*models.Session, error) {
+	var session models.Session
+	if err := repo.db.First(&session, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetByRefreshTokenHash retrieves the session whose current refresh token matches the hash
+func (repo *SessionRepository) GetByRefreshTokenHash(hash string) (*models.Session, error) {
+	var session models.Session
+	if err := repo.db.First(&session, "refresh_token_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetByPreviousTokenHash retrieves the session whose last rotated-out refresh token matches the hash
+func (repo *SessionRepository) GetByPreviousTokenHash(hash string) (*models.Session, error) {
+	var session models.Session
+	if err := repo.db.First(&session, "previous_token_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Rotate swaps the refresh token of a session and moves its expiry, but only if the caller still holds
+// the current one. It reports false when another request rotated or revoked the session first.
+func (repo *SessionRepository) Rotate(id, currentHash, newHash string, expiresAt time.Time) (bool, error) {
+	result := repo.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", id, currentHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": currentHash,
+			"last_refreshed_at":   time.Now().UTC(),
+			"expires_at":          expiresAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Revoke marks a single session as revoked
+func (repo *SessionRepository) Revoke(id string) error {
+	return repo.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC()).Error
+}
+
+// RevokeAllByUserID marks every active session of a user as revoked
+func (repo *SessionRepository) RevokeAllByUserID(userID string) error {
+	return repo.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now().UTC()).Error
+}
@@ -2,9 +2,14 @@ package repository
 
 import (
 	"backend/models"
+	"backend/pagination"
+	"backend/provenance"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // TransactionRepository handles database operations for transactions
@@ -17,9 +22,47 @@ func NewTransactionRepository(db *gorm.DB) *TransactionRepository {
 	return &TransactionRepository{db: db}
 }
 
-// Create inserts a new transaction into the database
+// Create appends a transaction to its product's provenance chain: it gets the next sequence number and
+// is hashed together with the hash of the product's newest entry, which is locked until it is inserted
 func (r *TransactionRepository) Create(transaction *models.Transaction) error {
-	return r.db.Create(transaction).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var newest []models.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("item_id = ?", transaction.ItemID).
+			Order("sequence DESC").
+			Limit(1).
+			Find(&newest).Error; err != nil {
+			return err
+		}
+
+		var prev *models.Transaction
+		if len(newest) > 0 {
+			prev = &newest[0]
+		}
+		provenance.Link(transaction, prev)
+		return tx.Create(transaction).Error
+	})
+}
+
+// GetChain retrieves the provenance chain of a product, oldest entry first
+func (r *TransactionRepository) GetChain(itemID uuid.UUID) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	if err := r.db.Where("item_id = ?", itemID).Order("sequence ASC").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// GetLatestByActions retrieves the newest entry of a product's chain with one of the actions, if any
+func (r *TransactionRepository) GetLatestByActions(itemID uuid.UUID, actions []models.TransactionAction) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.Where("item_id = ? AND action IN ?", itemID, actions).Order("sequence DESC").First(&transaction).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &transaction, nil
 }
 
 // GetByProductID retrieves transactions for a specific item ID, ordered by created timestamp.
@@ -33,6 +76,49 @@ func (r *TransactionRepository) GetByProductID(itemID uuid.UUID) ([]models.Trans
 	return transactions, nil
 }
 
+// List retrieves a page of the transactions matching the filter, newest first
+func (r *TransactionRepository) List(filter models.TransactionFilter, params pagination.Params) ([]models.Transaction, pagination.Page, error) {
+	query := r.db.Model(&models.Transaction{})
+	if filter.ProductID != nil {
+		query = query.Where("item_id = ?", *filter.ProductID)
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if len(filter.Actions) > 0 {
+		query = query.Where("action IN ?", filter.Actions)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	total, err := pagination.Count(query, params)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	var transactions []models.Transaction
+	if err := pagination.Keyset(query, "transactions", params).Find(&transactions).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	transactions, page := pagination.Finish(transactions, params, total, func(t models.Transaction) (time.Time, uuid.UUID) {
+		return t.CreatedAt, t.ID
+	})
+	return transactions, page, nil
+}
+
+// GetByUserID retrieves the transactions a user performed, oldest first
+func (r *TransactionRepository) GetByUserID(userID uuid.UUID) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
 func (r *TransactionRepository) GetByImageURLs(imageURLs []string) ([]models.Transaction, error) {
 	var transactions []models.Transaction
 	err := r.db.Where("image_url IN ?", imageURLs).Find(&transactions).Error
@@ -41,3 +127,15 @@ func (r *TransactionRepository) GetByImageURLs(imageURLs []string) ([]models.Tra
 	}
 	return transactions, nil
 }
+
+// GetByID retrieves a transaction by its ID
+func (r *TransactionRepository) GetByID(id uuid.UUID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.First(&transaction, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &transaction, nil
+}
@@ -0,0 +1,22 @@
+package repository
+
+import "gorm.io/gorm"
+
+// UnitOfWork runs operations that span several repositories in one database transaction
+type UnitOfWork struct {
+	db *gorm.DB
+}
+
+// NewUnitOfWork creates a new unit of work on the GORM database connection
+func NewUnitOfWork(db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// Do calls fn with a factory whose repositories all work inside one transaction. The transaction is
+// committed when fn returns nil and rolled back when it returns an error or panics. Repository methods
+// that open a transaction of their own get a savepoint inside it.
+func (u *UnitOfWork) Do(fn func(repos *RepositoryFactory) error) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewRepositoryFactory(tx))
+	})
+}
@@ -3,9 +3,11 @@ package repository
 
 import (
 	"backend/models"
+	"backend/pagination"
 	"errors"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -46,21 +48,23 @@ func (repo *UserRepository) GetByID(id string) (*models.User, error) {
 	return &user, nil
 }
 
+// GetByIDs retrieves the users with the given IDs; unknown IDs are skipped
+func (repo *UserRepository) GetByIDs(ids []uuid.UUID) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	if err := repo.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // Update modifies an existing user's information
 func (repo *UserRepository) Update(userID string, user *models.User) error {
 	return repo.db.Model(&models.User{}).Where("id = ?", userID).Updates(user).Error
 }
 
-// UpdateEmail modifies an existing user's email
-func (repo *UserRepository) UpdateEmail(userID, newEmail string) error {
-	return repo.db.Model(&models.User{}).
-		Where("id = ?", userID).
-		Updates(map[string]interface{}{
-			"email":    newEmail,
-			"verified": false,
-		}).Error
-}
-
 // UpdatePassword modifies an existing user's password
 func (repo *UserRepository) UpdatePassword(userID, hashedPassword string) error {
 	return repo.db.Model(&models.User{}).Where("id = ?", userID).Update("password", hashedPassword).Error
@@ -85,14 +89,9 @@ func (repo *UserRepository) VerifyEmail(userID string) error {
 	return nil
 }
 
-// FindByNamePrefix finds users whose names start with the provided prefix (up to 10 users)
-func (r *UserRepository) FindByNamePrefix(name string) ([]models.User, error) {
-	var users []models.User
-	result := r.db.Where("name LIKE ?", name+"%").Limit(10).Find(&users)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	return users, nil
+// FindByNamePrefix retrieves a page of the users whose names start with the provided prefix, newest first
+func (r *UserRepository) FindByNamePrefix(name string, params pagination.Params) ([]models.User, pagination.Page, error) {
+	return r.page(r.db.Model(&models.User{}).Where("name LIKE ?", name+"%"), params)
 }
 
 func (repo *UserRepository) AddPremiumByDay(userID string, day int) (*models.User, error) {
@@ -134,3 +133,39 @@ func (repo *UserRepository) AddPremiumByDay(userID string, day int) (*models.Use
 	// Return the updated user
 	return &user, nil
 }
+
+// List retrieves a page of the users whose name or email contains the search term, newest first
+func (repo *UserRepository) List(search string, params pagination.Params) ([]models.User, pagination.Page, error) {
+	query := repo.db.Model(&models.User{})
+	if search != "" {
+		query = query.Where("name LIKE ? OR email LIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+	return repo.page(query, params)
+}
+
+// page fetches one page of a user query along with the total, when asked for
+func (repo *UserRepository) page(query *gorm.DB, params pagination.Params) ([]models.User, pagination.Page, error) {
+	total, err := pagination.Count(query, params)
+	if err != nil {
+		return nil, pagination.Page{}, err
+	}
+
+	var users []models.User
+	if err := pagination.Keyset(query, "users", params).Find(&users).Error; err != nil {
+		return nil, pagination.Page{}, err
+	}
+	users, page := pagination.Finish(users, params, total, func(u models.User) (time.Time, uuid.UUID) {
+		return u.CreatedAt, u.ID
+	})
+	return users, page, nil
+}
+
+// UpdateRole changes the role of a user
+func (repo *UserRepository) UpdateRole(userID string, role models.Role) error {
+	return repo.db.Model(&models.User{}).Where("id = ?", userID).Update("role", role).Error
+}
+
+// PromoteByEmails gives the role to the users with the given emails
+func (repo *UserRepository) PromoteByEmails(emails []string, role models.Role) error {
+	return repo.db.Model(&models.User{}).Where("email IN ?", emails).Update("role", role).Error
+}
@@ -3,8 +3,13 @@ package routes
 import (
 	"backend/controller"
 	"backend/middleware" // Import JWT middleware
+	"backend/models"
+	"backend/payments"
 	"backend/repository"
 	"backend/service"
+	"backend/token"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -19,77 +24,241 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 	userRepo := repoFactory.GetUserRepository()
 	transactionRepo := repoFactory.GetTransactionRepository()
 	commentRepo := repoFactory.GetCommentRepository() // Add comment repository
+	sessionRepo := repoFactory.GetSessionRepository()
+	identityRepo := repoFactory.GetIdentityRepository()
+	mfaRepo := repoFactory.GetMFARepository()
+	lockoutRepo := repoFactory.GetLockoutRepository()
+	tokenRepo := repoFactory.GetOneTimeTokenRepository()
+	emailChangeRepo := repoFactory.GetEmailChangeRepository()
+	apiKeyRepo := repoFactory.GetAPIKeyRepository()
+	accountDeletionRepo := repoFactory.GetAccountDeletionRepository()
+	privacyRepo := repoFactory.GetPrivacyRepository()
+	categoryRepo := repoFactory.GetCategoryRepository()
+	mediaRepo := repoFactory.GetMediaRepository()
+	orderRepo := repoFactory.GetOrderRepository()
+	paymentRepo := repoFactory.GetPaymentRepository()
+	ledgerRepo := repoFactory.GetLedgerRepository()
+	amendmentRepo := repoFactory.GetAmendmentRepository()
+	revitalizationRepo := repoFactory.GetRevitalizationRepository()
+	uow := repoFactory.GetUnitOfWork()
 
 	// Create services
-	productService := service.NewProductService(productRepo)
+	sessionService := service.NewSessionService(sessionRepo, userRepo)
+	mfaService := service.NewMFAService(mfaRepo, userRepo)
+	lockoutService := service.NewLockoutService(lockoutRepo, userRepo)
+	tokenService := service.NewOneTimeTokenService(tokenRepo)
+	emailChangeService := service.NewEmailChangeService(emailChangeRepo, userRepo, tokenService, sessionService)
+	categoryService := service.NewCategoryService(categoryRepo)
+	mediaService := service.NewMediaService(mediaRepo, productRepo, transactionRepo)
+	productService := service.NewProductService(productRepo, repoFactory.GetProductSearcher(), categoryService, mediaService, revitalizationRepo, uow)
 	ratingService := service.NewRatingService(ratingRepo)
-	userService := service.NewUserService(userRepo)
-	transactionService := service.NewTransactionService(transactionRepo)
+	privacyService := service.NewPrivacyService(privacyRepo)
+	userService := service.NewUserService(userRepo, sessionService, mfaService, lockoutService, tokenService, privacyService)
+	socialLoginService := service.NewSocialLoginService(service.LoadOIDCProvidersFromEnv(), identityRepo, userRepo, sessionService, mfaService)
+	transactionService := service.NewTransactionService(transactionRepo, amendmentRepo, productRepo, mediaService, uow)
 	commentService := service.NewCommentService(commentRepo) // Create comment service
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo)
+	paymentProvider, err := payments.LoadProviderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up payments: %v", err)
+	}
+	paymentService := service.NewPaymentService(paymentProvider, paymentRepo, ledgerRepo, uow)
+	provenanceKeys, err := token.LoadProvenanceKeySetFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load provenance keys: %v", err)
+	}
+	provenanceService := service.NewProvenanceService(transactionRepo, amendmentRepo, productRepo, provenanceKeys)
+	orderService := service.NewOrderService(orderRepo, productRepo, paymentService, uow)
+	revitalizationService := service.NewRevitalizationService(revitalizationRepo, productRepo, transactionRepo, uow)
+	accountService := service.NewAccountService(userRepo, productRepo, transactionRepo, ratingRepo, commentRepo, accountDeletionRepo, mediaRepo)
 
 	// Create controllers
-	productController := controller.NewProductController(productService, transactionService, userService, ratingService)
-	ratingController := controller.NewRatingController(ratingService)
-	userController := controller.NewUserController(userService)
+	productController := controller.NewProductController(productService, transactionService, userService, ratingService, privacyService)
+	ratingController := controller.NewRatingController(ratingService, privacyService)
+	userController := controller.NewUserController(userService, sessionService, emailChangeService)
 	homeController := controller.NewHomeController()
-	transactionController := controller.NewTransactionController(transactionService, productService)
+	transactionController := controller.NewTransactionController(transactionService, productService, userService, privacyService)
 	commentController := controller.NewCommentController(commentService, *userService)
+	adminController := controller.NewAdminController(userService, sessionService, productService, lockoutService)
+	oauthController := controller.NewOAuthController(socialLoginService)
+	mfaController := controller.NewMFAController(userService, mfaService)
+	jwksController := controller.NewJWKSController(token.Default(), provenanceKeys)
+	apiKeyController := controller.NewAPIKeyController(apiKeyService)
+	accountController := controller.NewAccountController(accountService)
+	privacyController := controller.NewPrivacyController(privacyService)
+	categoryController := controller.NewCategoryController(categoryService)
+	mediaController := controller.NewMediaController(mediaService)
+	orderController := controller.NewOrderController(orderService)
+	revitalizationController := controller.NewRevitalizationController(revitalizationService)
+	paymentController := controller.NewPaymentController(paymentService)
+	provenanceController := controller.NewProvenanceController(provenanceService)
+
+	// Promote the users listed in ADMIN_EMAILS so there is always someone who can manage roles
+	if err := userService.PromoteBootstrapAdmins(); err != nil {
+		log.Printf("Failed to promote bootstrap admins: %v", err)
+	}
+
+	// Purge the personal data of closed accounts once their grace period has ended
+	go accountService.RunPurgeJob(time.Hour)
+	// Release the products of unpaid orders whose reservation ran out
+	go orderService.RunExpiryJob(time.Minute)
+
+	// Authentication middleware, backed by the session store so revoked tokens are rejected
+	jwtAuth := middleware.JWTAuth(sessionService)
+	// Routes machine clients may call accept API keys as well; account security routes stay JWT-only
+	auth := middleware.JWTOrAPIKeyAuth(jwtAuth, apiKeyService)
+	scope := middleware.RequireScope
+	// Public routes that show user data recognize logged-in members, whose view depends on privacy settings
+	optionalAuth := middleware.OptionalJWTAuth(sessionService)
 
 	// Define routes
 	router.GET("/", homeController.Index) // Home route
+	router.GET("/.well-known/jwks.json", jwksController.Keys)
 
 	// User routes
 	users := router.Group("/users")
 	{
-		users.POST("/signup", userController.SignUp)                                 // DONE!
-		users.POST("/login", userController.Login)                                   // DONE!
-		users.GET("/:id", userController.GetDemographicInformation)                  // DONE!
-		users.PUT("/", middleware.JWTAuth(), userController.UpdateUser)              // DONE!
-		users.PUT("/email", middleware.JWTAuth(), userController.UpdateEmail)        // DONE!
+		users.POST("/signup", userController.SignUp) // DONE!
+		users.POST("/login", userController.Login)   // DONE!
+		users.POST("/login/mfa", mfaController.LoginMFA)
+		users.POST("/refresh", userController.Refresh)
+		users.POST("/logout", jwtAuth, userController.Logout)
+		users.POST("/logout/all", jwtAuth, userController.LogoutAll)
+		users.POST("/mfa/enroll", jwtAuth, mfaController.Enroll)
+		users.POST("/mfa/verify", jwtAuth, mfaController.Confirm)
+		users.POST("/mfa/disable", jwtAuth, mfaController.Disable)
+		users.GET("/oauth/providers", oauthController.Providers)
+		users.GET("/oauth/:provider/start", oauthController.Start)
+		users.POST("/oauth/:provider/callback", oauthController.Callback)
+		users.GET("/me/export", jwtAuth, accountController.Export)
+		users.DELETE("/me", jwtAuth, accountController.Delete)
+		users.GET("/me/privacy", jwtAuth, privacyController.Get)
+		users.PUT("/me/privacy", jwtAuth, privacyController.Update)
+		users.GET("/:id", optionalAuth, userController.GetDemographicInformation) // DONE!
+		users.GET("/:id/transactions", optionalAuth, transactionController.ListByUser)
+		users.PUT("/", auth, scope(models.ScopeProfileWrite), userController.UpdateUser) // DONE!
+		users.PUT("/email", jwtAuth, userController.UpdateEmail)                         // DONE!
+		users.POST("/email/confirm", userController.ConfirmEmailChange)
+		users.POST("/email/cancel", userController.CancelEmailChange)
 		users.PUT("/password", userController.UpdatePassword)                        // DONE!
 		users.POST("/password/reset", userController.SendPasswordResetEmail)         // DONE!
 		users.POST("/verify", userController.VerifyEmail)                            // DONE!
 		users.POST("/email/send-verification", userController.SendEmailVerification) // DONE!
-		users.GET("/search", userController.GetByName)
-		users.GET("/email", userController.GetUserByEmail)
-		users.PUT("/premium", middleware.JWTAuth(), userController.AddPremiumDaysHandler)
+		users.GET("/search", optionalAuth, userController.GetByName)
+		users.GET("/email", optionalAuth, userController.GetUserByEmail)
+		users.PUT("/premium", auth, scope(models.ScopeProfileWrite), userController.AddPremiumDaysHandler)
+		users.POST("/api-keys", jwtAuth, apiKeyController.Create)
+		users.GET("/api-keys", jwtAuth, apiKeyController.List)
+		users.DELETE("/api-keys/:id", jwtAuth, apiKeyController.Revoke)
 	}
 
 	// Product routes
 	products := router.Group("/products")
 	{
-		products.POST("/", middleware.JWTAuth(), productController.Create)                       // Create a new product
-		products.GET("/", productController.GetOne)                                              // Get a product by ID
-		products.GET("/user", productController.GetProductsByUserID)                             // Get products by user ID (from JWT)
-		products.GET("/content-based", productController.GetContentBased)                        // Get content-based recommendations
-		products.GET("/collaborative", middleware.JWTAuth(), productController.GetCollaborative) // Get collaborative-based recommendations
-		products.GET("/status", productController.GetProductsByStatus)                           // Get restored products
-		products.GET("/random", productController.GetRandomProducts)                             // Get random products
-		products.GET("/rated", productController.GetRatedProductsByUserID)
-		products.GET("/random/paginated", productController.GetPaginatedRandomProducts)
-		products.GET("/item-based", productController.GetItemBased)
+		products.POST("/", auth, scope(models.ScopeProductsWrite), productController.Create)                      // Create a new product
+		products.GET("/", optionalAuth, productController.GetOne)                                                 // Get a product by ID
+		products.GET("/user", optionalAuth, productController.GetProductsByUserID)                                // Get products by user ID (from JWT)
+		products.GET("/content-based", optionalAuth, productController.GetContentBased)                           // Get content-based recommendations
+		products.GET("/collaborative", auth, scope(models.ScopeProductsRead), productController.GetCollaborative) // Get collaborative-based recommendations
+		products.GET("/status", optionalAuth, productController.GetProductsByStatus)                              // Get restored products
+		products.GET("/random", optionalAuth, productController.GetRandomProducts)                                // Get random products
+		products.GET("/rated", optionalAuth, productController.GetRatedProductsByUserID)
+		products.GET("/search", optionalAuth, productController.Search) // Full-text search with filters and facets
+		products.GET("/random/paginated", optionalAuth, productController.GetPaginatedRandomProducts)
+		products.GET("/item-based", optionalAuth, productController.GetItemBased)
+		products.PATCH("/:id", auth, scope(models.ScopeProductsWrite), productController.Update)    // Edit a listing (owner or moderator)
+		products.DELETE("/:id", auth, scope(models.ScopeProductsWrite), productController.Withdraw) // Withdraw a listing (owner or moderator)
+		products.GET("/:id/media", mediaController.List)
+		products.GET("/:id/provenance", provenanceController.Get)                     // Hash-chained history with verification
+		products.GET("/:id/provenance/certificate", provenanceController.Certificate) // Signed, offline-verifiable snapshot
+		products.POST("/:id/media", auth, scope(models.ScopeProductsWrite), mediaController.Add)
+		products.PUT("/:id/media/order", auth, scope(models.ScopeProductsWrite), mediaController.Reorder)
+		products.PATCH("/:id/media/:media_id", auth, scope(models.ScopeProductsWrite), mediaController.Update)
+		products.DELETE("/:id/media/:media_id", auth, scope(models.ScopeProductsWrite), mediaController.Delete)
 	}
 
+	// Category routes
+	router.GET("/categories", categoryController.Tree)
+
 	// Rating routes
 	ratings := router.Group("/ratings")
 	{
-		ratings.POST("/", middleware.JWTAuth(), ratingController.Create)                          // Create a new rating
-		ratings.DELETE("/:id", middleware.JWTAuth(), ratingController.Delete)                     // Delete a rating by ID
-		ratings.GET("/user/:user_id", ratingController.GetRatedProductsByUserId)                  // Get all rated products by user ID
+		ratings.POST("/", auth, scope(models.ScopeRatingsWrite), ratingController.Create)      // Create a new rating
+		ratings.DELETE("/:id", auth, scope(models.ScopeRatingsWrite), ratingController.Delete) // Delete a rating by ID
+		ratings.GET("/export", auth, scope(models.ScopeRatingsExport), middleware.RequireRole(models.RoleModerator, models.RoleAdmin), ratingController.Export)
+		ratings.GET("/user/:user_id", optionalAuth, ratingController.GetRatedProductsByUserId)    // Get all rated products by user ID
 		ratings.GET("/product/:product_id/average", ratingController.GetAverageRatingByProductId) // Get average rating and count by product ID
 	}
 
 	// Transaction routes
 	transactions := router.Group("/transactions")
 	{
-		transactions.POST("/:item_id/", middleware.JWTAuth(), transactionController.AddTransactionToItem) // Add transaction to item
+		transactions.POST("/:item_id/", auth, scope(models.ScopeTransactionsWrite), transactionController.AddTransactionToItem) // Add transaction to item
+		transactions.GET("/", optionalAuth, transactionController.List)                                                         // History of a product
+		transactions.GET("/:id", optionalAuth, transactionController.Get)
+		transactions.POST("/:item_id/amendments", auth, scope(models.ScopeTransactionsWrite), transactionController.Amend) // Correct a transaction
+	}
+
+	// Order routes
+	orders := router.Group("/orders", auth)
+	{
+		orders.POST("/", scope(models.ScopeOrdersWrite), orderController.Place) // Order a product, reserving it
+		orders.GET("/", scope(models.ScopeOrdersRead), orderController.List)    // Order history as buyer and seller
+		orders.GET("/:id", scope(models.ScopeOrdersRead), orderController.Get)
+		orders.GET("/:id/payment", scope(models.ScopeOrdersRead), orderController.Payment) // Payment and its ledger entries
+		orders.POST("/:id/accept", scope(models.ScopeOrdersWrite), orderController.Accept)
+		orders.POST("/:id/decline", scope(models.ScopeOrdersWrite), orderController.Decline)
+		orders.POST("/:id/pay", scope(models.ScopeOrdersWrite), orderController.Pay)
+		orders.POST("/:id/hand-over", scope(models.ScopeOrdersWrite), orderController.HandOver)
+		orders.POST("/:id/complete", scope(models.ScopeOrdersWrite), orderController.Complete) // Sells the product and pays the seller
+		orders.POST("/:id/cancel", scope(models.ScopeOrdersWrite), orderController.Cancel)
+	}
+
+	// Revitalization routes
+	revitalizations := router.Group("/revitalizations")
+	{
+		revitalizations.POST("/", auth, scope(models.ScopeRevitalizationsWrite), revitalizationController.Publish) // Ask workshops to revitalize a product
+		revitalizations.GET("/", revitalizationController.List)                                                    // Open requests workshops can bid on
+		revitalizations.GET("/:id", optionalAuth, revitalizationController.Get)
+		revitalizations.POST("/:id/bids", auth, scope(models.ScopeRevitalizationsWrite), revitalizationController.Bid)
+		revitalizations.POST("/:id/bids/:bid_id/accept", auth, scope(models.ScopeRevitalizationsWrite), revitalizationController.Accept) // Hands the job to the bid's workshop
+		revitalizations.POST("/:id/start", auth, scope(models.ScopeRevitalizationsWrite), revitalizationController.Start)
+		revitalizations.POST("/:id/cancel", auth, scope(models.ScopeRevitalizationsWrite), revitalizationController.Cancel)
+	}
+
+	// Payment routes
+	paymentRoutes := router.Group("/payments")
+	{
+		paymentRoutes.GET("/balance", auth, scope(models.ScopeOrdersRead), paymentController.Balance)
+		paymentRoutes.POST("/webhook", paymentController.Webhook) // Authenticated by the provider's signature
 	}
 
 	// Comment routes
 	comments := router.Group("/comments")
 	{
-		comments.POST("/", middleware.JWTAuth(), commentController.Create)      // Create comment
-		comments.GET("/product/:product_id", commentController.GetByProductID)  // Get comments by product
-		comments.DELETE("/:id", middleware.JWTAuth(), commentController.Delete) // Delete comment
+		comments.POST("/", auth, scope(models.ScopeCommentsWrite), commentController.Create)      // Create comment
+		comments.GET("/product/:product_id", optionalAuth, commentController.GetByProductID)      // Get comments by product
+		comments.DELETE("/:id", auth, scope(models.ScopeCommentsWrite), commentController.Delete) // Delete comment
+	}
+
+	// Admin routes
+	admin := router.Group("/admin", jwtAuth, middleware.RequireRole(models.RoleModerator, models.RoleAdmin))
+	{
+		admin.GET("/users", adminController.ListUsers)
+		admin.GET("/users/:id", adminController.GetUser)
+		admin.PUT("/users/:id/role", middleware.RequireRole(models.RoleAdmin), adminController.UpdateUserRole)
+		admin.DELETE("/users/:id/sessions", adminController.RevokeUserSessions)
+		admin.GET("/users/:id/lockout", adminController.GetUserLockout)
+		admin.DELETE("/users/:id/lockout", adminController.UnlockUser)
+		admin.GET("/users/:id/login-attempts", adminController.ListLoginAttempts)
+		admin.GET("/products", adminController.ListProducts)
+		admin.PUT("/products/:id/status", adminController.UpdateProductStatus)
+		admin.DELETE("/products/:id", adminController.DeleteProduct)
+		admin.GET("/api-keys", apiKeyController.ListAll)
+		admin.DELETE("/api-keys/:id", apiKeyController.Revoke)
+		admin.POST("/users/:id/api-keys", middleware.RequireRole(models.RoleAdmin), apiKeyController.CreateForUser)
+		admin.POST("/categories", middleware.RequireRole(models.RoleAdmin), categoryController.Create)
+		admin.DELETE("/categories/:id", middleware.RequireRole(models.RoleAdmin), categoryController.Delete)
+		admin.POST("/categories/migrate", middleware.RequireRole(models.RoleAdmin), categoryController.Migrate)
 	}
 }
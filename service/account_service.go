@@ -0,0 +1,280 @@
+package service
+
+import (
+	"archive/zip"
+	"backend/models"
+	"backend/repository"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultDeletionGracePeriod is how long personal data of a closed account is kept before it is purged
+	defaultDeletionGracePeriod = 30 * 24 * time.Hour
+	// purgeBatchSize limits how many accounts one run of the purge job handles
+	purgeBatchSize = 50
+)
+
+var ErrAccountDeleted = errors.New("account has been deleted")
+
+// AccountService lets users take out their data (GDPR export) and close their account
+type AccountService struct {
+	userRepo        *repository.UserRepository
+	productRepo     *repository.ProductRepository
+	transactionRepo *repository.TransactionRepository
+	ratingRepo      *repository.RatingRepository
+	commentRepo     *repository.CommentRepository
+	deletionRepo    *repository.AccountDeletionRepository
+	mediaRepo       *repository.MediaRepository
+	gracePeriod     time.Duration
+}
+
+// NewAccountService creates a new instance of AccountService. The grace period before closed accounts
+// are purged can be set in days with ACCOUNT_DELETION_GRACE_DAYS.
+func NewAccountService(userRepo *repository.UserRepository, productRepo *repository.ProductRepository, transactionRepo *repository.TransactionRepository,
+	ratingRepo *repository.RatingRepository, commentRepo *repository.CommentRepository, deletionRepo *repository.AccountDeletionRepository, mediaRepo *repository.MediaRepository) *AccountService {
+	gracePeriod := defaultDeletionGracePeriod
+	if days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS")); err == nil && days >= 0 {
+		gracePeriod = time.Duration(days) * 24 * time.Hour
+	}
+
+	return &AccountService{
+		userRepo:        userRepo,
+		productRepo:     productRepo,
+		transactionRepo: transactionRepo,
+		ratingRepo:      ratingRepo,
+		commentRepo:     commentRepo,
+		deletionRepo:    deletionRepo,
+		mediaRepo:       mediaRepo,
+		gracePeriod:     gracePeriod,
+	}
+}
+
+// Export gathers everything stored about a user into a zip archive: one JSON file per kind of data,
+// plus their avatar, transaction and gallery images. Images that cannot be downloaded are listed in manifest.json.
+func (s *AccountService) Export(userID string) ([]byte, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	user.Password = ""
+
+	products, err := s.productRepo.GetAllByUserID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	transactions, err := s.transactionRepo.GetByUserID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	ratings, err := s.ratingRepo.GetRatedProductsByUserId(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ratings: %w", err)
+	}
+	comments, err := s.commentRepo.GetByUserID(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comments: %w", err)
+	}
+	media, err := s.mediaRepo.GetByUploader(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch images: %w", err)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", user},
+		{"products.json", products},
+		{"transactions.json", transactions},
+		{"ratings.json", ratings},
+		{"comments.json", comments},
+		{"gallery.json", media},
+	}
+	for _, file := range files {
+		if err := writeJSONToZip(archive, file.name, file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	// Images live in S3; the archive keeps the key they are stored under as file name
+	images := map[string]string{}
+	if key := avatarKey(user); key != "" {
+		images[key] = "images/avatar/" + path.Base(key)
+	}
+	for _, transaction := range transactions {
+		if transaction.ImageURL != "" {
+			images["images/"+transaction.ImageURL] = "images/transactions/" + path.Base(transaction.ImageURL)
+		}
+	}
+	for _, item := range media {
+		if _, ok := images[item.ImageKey]; !ok {
+			images[item.ImageKey] = "images/gallery/" + path.Base(item.ImageKey)
+		}
+	}
+
+	var missing []string
+	for key, name := range images {
+		data, err := GetImageData(key)
+		if err != nil {
+			log.Printf("Export for user %s: failed to download image %s: %v", user.ID, key, err)
+			missing = append(missing, name)
+			continue
+		}
+		w, err := archive.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to export: %w", name, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to add %s to export: %w", name, err)
+		}
+	}
+
+	manifest := map[string]interface{}{
+		"user_id":        user.ID,
+		"generated_at":   time.Now().UTC(),
+		"missing_images": missing,
+	}
+	if err := writeJSONToZip(archive, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish export: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Delete closes a user's account after checking their password. The profile is anonymized and the avatar
+// removed right away, so their products, transactions and comments show "Deleted user" as author; the
+// remaining personal data is purged by PurgeDue once the grace period has passed.
+func (s *AccountService) Delete(userID, password string) (*models.AccountDeletion, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.DeletedAt != nil {
+		return nil, ErrAccountDeleted
+	}
+	if !CheckPasswordHash(password, user.Password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Avatars are removed on a best-effort basis; a missing S3 setup must not keep anyone from leaving
+	for _, key := range avatarKeys(user) {
+		if err := DeleteImage(key); err != nil {
+			log.Printf("Failed to delete avatar %s of user %s: %v", key, user.ID, err)
+		}
+	}
+
+	now := time.Now().UTC()
+	deletion := &models.AccountDeletion{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Status:      models.AccountDeletionPending,
+		RequestedAt: now,
+		PurgeAfter:  now.Add(s.gracePeriod),
+	}
+	if err := s.deletionRepo.Schedule(deletion); err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return nil, ErrAccountDeleted
+		}
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	log.Printf("Account of user %s closed, personal data will be purged after %s", user.ID, deletion.PurgeAfter.Format(time.RFC3339))
+	return deletion, nil
+}
+
+// PurgeDue hard-deletes the personal data of closed accounts whose grace period has ended
+func (s *AccountService) PurgeDue() (int, error) {
+	deletions, err := s.deletionRepo.ListDue(time.Now().UTC(), purgeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due account deletions: %w", err)
+	}
+
+	purged := 0
+	for i := range deletions {
+		if err := s.deletionRepo.Purge(&deletions[i]); err != nil {
+			log.Printf("Failed to purge account of user %s: %v", deletions[i].UserID, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// RunPurgeJob calls PurgeDue every interval. It blocks, so start it in its own goroutine.
+func (s *AccountService) RunPurgeJob(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := s.PurgeDue()
+		if err != nil {
+			log.Printf("Account purge job: %v", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("Account purge job: purged %d closed accounts", purged)
+		}
+	}
+}
+
+// avatarKey returns the S3 key of the user's current avatar. Avatars uploaded at signup are stored with
+// their full key, later uploads only with the file name under "users/".
+func avatarKey(user *models.User) string {
+	switch {
+	case user.ImageURL == "":
+		return ""
+	case strings.Contains(user.ImageURL, "/"):
+		return user.ImageURL
+	default:
+		return "users/" + user.ImageURL
+	}
+}
+
+// avatarKeys lists every key an avatar of the user may have been uploaded under
+func avatarKeys(user *models.User) []string {
+	keys := []string{
+		fmt.Sprintf("users/%s.jpg", user.ID),
+		fmt.Sprintf("user-images/%s.jpg", user.Email),
+	}
+	if key := avatarKey(user); key != "" && key != keys[0] && key != keys[1] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// writeJSONToZip adds a pretty-printed JSON file to a zip archive
+func writeJSONToZip(archive *zip.Writer, name string, data interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to export: %w", name, err)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return nil
+}
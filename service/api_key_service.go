@@ -0,0 +1,172 @@
+package service
+
+import (
+	"backend/models"
+	"backend/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// apiKeyPrefix marks our keys so they are easy to recognize, e.g. by secret scanners
+	apiKeyPrefix = "eco"
+	// apiKeyTouchInterval limits how often last_used_at is written for a busy key
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidScope   = errors.New("invalid scope")
+)
+
+// APIKeyService manages API keys for machine clients and authenticates requests made with them
+type APIKeyService struct {
+	apiKeyRepo *repository.APIKeyRepository
+	userRepo   *repository.UserRepository
+}
+
+// NewAPIKeyService creates a new instance of APIKeyService
+func NewAPIKeyService(apiKeyRepo *repository.APIKeyRepository, userRepo *repository.UserRepository) *APIKeyService {
+	return &APIKeyService{apiKeyRepo: apiKeyRepo, userRepo: userRepo}
+}
+
+// Create issues a new key for ownerID. Users create keys for themselves; admins may create them for anyone.
+// The returned plain key is shown once and cannot be recovered.
+func (s *APIKeyService) Create(actor Actor, ownerID uuid.UUID, req *models.CreateAPIKey) (*models.CreatedAPIKey, error) {
+	if err := Authorize(actor, PermManageAPIKey, ownerID); err != nil {
+		return nil, err
+	}
+
+	scopes := make(models.Scopes, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !scope.IsValid() {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+		if !scopes.Has(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	owner, err := s.userRepo.GetByID(ownerID.String())
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, ErrUserNotFound
+	}
+
+	prefix, err := randomHex(6)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key prefix: %w", err)
+	}
+	secret, err := randomURLSafe(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	plainKey := fmt.Sprintf("%s_%s_%s", apiKeyPrefix, prefix, secret)
+
+	key := &models.APIKey{
+		ID:        uuid.New(),
+		UserID:    owner.ID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKey(plainKey),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().UTC().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := s.apiKeyRepo.Create(key); err != nil {
+		return nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	return &models.CreatedAPIKey{Key: plainKey, APIKey: key}, nil
+}
+
+// List returns the keys of a user, or of everyone when userID is empty
+func (s *APIKeyService) List(userID string) ([]models.APIKey, error) {
+	return s.apiKeyRepo.List(userID)
+}
+
+// Revoke disables a key. Only its owner or an admin may revoke it.
+func (s *APIKeyService) Revoke(actor Actor, id string) error {
+	key, err := s.apiKeyRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return ErrAPIKeyNotFound
+	}
+	if err := Authorize(actor, PermManageAPIKey, key.UserID); err != nil {
+		return err
+	}
+	return s.apiKeyRepo.Revoke(id)
+}
+
+// Authenticate checks a plain key and returns it with the role of its owner, whose permissions the key acts with
+func (s *APIKeyService) Authenticate(plainKey string) (*models.APIKey, models.Role, error) {
+	parts := strings.SplitN(plainKey, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return nil, "", ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetByPrefix(parts[1])
+	if err != nil {
+		return nil, "", err
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashAPIKey(plainKey))) != 1 {
+		return nil, "", ErrInvalidAPIKey
+	}
+	now := time.Now().UTC()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, "", ErrInvalidAPIKey
+	}
+
+	// Re-read the owner so role changes apply to their keys right away
+	owner, err := s.userRepo.GetByID(key.UserID.String())
+	if err != nil {
+		return nil, "", err
+	}
+	if owner == nil {
+		return nil, "", ErrInvalidAPIKey
+	}
+	role := owner.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.apiKeyRepo.TouchLastUsed(key.ID.String(), now); err != nil {
+			log.Printf("Failed to record use of API key %s: %v", key.ID, err)
+		}
+	}
+
+	return key, role, nil
+}
+
+// hashAPIKey hashes a plain key for storage; only the hash ever reaches the database
+func hashAPIKey(plainKey string) string {
+	sum := sha256.Sum256([]byte(plainKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
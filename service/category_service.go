@@ -0,0 +1,384 @@
+package service
+
+import (
+	"backend/models"
+	"backend/repository"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryExists   = errors.New("a category with this slug already exists")
+	ErrCategoryInUse    = errors.New("category is still in use")
+	ErrInvalidCategory  = errors.New("invalid category")
+)
+
+// slugPattern is the shape of category slugs: lowercase words joined by hyphens
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// CategoryService manages the product taxonomy. It has two levels, matching the category and
+// subcategory stored on products: top-level categories and their subcategories.
+type CategoryService struct {
+	categoryRepo *repository.CategoryRepository
+}
+
+// NewCategoryService creates a new instance of CategoryService
+func NewCategoryService(categoryRepo *repository.CategoryRepository) *CategoryService {
+	return &CategoryService{categoryRepo: categoryRepo}
+}
+
+// taxonomy is the category tree loaded into memory for lookups
+type taxonomy struct {
+	roots    []*models.Category
+	children map[uuid.UUID][]*models.Category
+	bySlug   map[string]*models.Category
+}
+
+// load reads the whole taxonomy; it is small enough to be looked up in memory
+func (s *CategoryService) load() (*taxonomy, error) {
+	categories, err := s.categoryRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+
+	t := &taxonomy{
+		children: map[uuid.UUID][]*models.Category{},
+		bySlug:   make(map[string]*models.Category, len(categories)),
+	}
+	for i := range categories {
+		category := &categories[i]
+		t.bySlug[category.Slug] = category
+		if category.ParentID == nil {
+			t.roots = append(t.roots, category)
+		} else {
+			t.children[*category.ParentID] = append(t.children[*category.ParentID], category)
+		}
+	}
+	return t, nil
+}
+
+// resolve checks that category is a top-level category and subCategory, if given, one of its subcategories.
+// Both are looked up by slug, then by name, so clients still sending the free-text values products were
+// listed under before the taxonomy get the slugs they were seeded as.
+func (t *taxonomy) resolve(category, subCategory string) (string, string, error) {
+	category = strings.ToLower(strings.TrimSpace(category))
+	subCategory = strings.ToLower(strings.TrimSpace(subCategory))
+	if category == "" {
+		return "", "", fmt.Errorf("%w: category is required", ErrInvalidCategory)
+	}
+
+	root, ok := t.bySlug[category]
+	if !ok || root.ParentID != nil {
+		if root = match(category, t.roots); root == nil {
+			return "", "", fmt.Errorf("%w: unknown category %q", ErrInvalidCategory, category)
+		}
+	}
+	if subCategory == "" {
+		return root.Slug, "", nil
+	}
+	sub, ok := t.bySlug[subCategory]
+	if !ok || sub.ParentID == nil || *sub.ParentID != root.ID {
+		if sub = match(subCategory, t.children[root.ID]); sub == nil {
+			return "", "", fmt.Errorf("%w: %q is not a subcategory of %q", ErrInvalidCategory, subCategory, category)
+		}
+	}
+	return root.Slug, sub.Slug, nil
+}
+
+// match finds the category among candidates whose slug, name or a localized name equals the free-text
+// value, ignoring case, separators and a plural "s"
+func match(value string, candidates []*models.Category) *models.Category {
+	key := normalizeCategoryName(value)
+	if key == "" {
+		return nil
+	}
+	for _, category := range candidates {
+		if normalizeCategoryName(category.Slug) == key || normalizeCategoryName(category.Name) == key {
+			return category
+		}
+		for _, name := range category.Names {
+			if normalizeCategoryName(name.Name) == key {
+				return category
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeCategoryName folds the spelling variants seen in free-text categories, so "Furniture",
+// "furniture" and "Furnitures" compare equal
+func normalizeCategoryName(value string) string {
+	value = strings.Join(strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), " ")
+	switch {
+	case strings.HasSuffix(value, "ies"):
+		return strings.TrimSuffix(value, "ies") + "y"
+	case strings.HasSuffix(value, "s") && !strings.HasSuffix(value, "ss"):
+		return strings.TrimSuffix(value, "s")
+	}
+	return value
+}
+
+// Resolve validates a category/subcategory pair against the taxonomy and returns their canonical slugs.
+// The subcategory is optional.
+func (s *CategoryService) Resolve(category, subCategory string) (string, string, error) {
+	t, err := s.load()
+	if err != nil {
+		return "", "", err
+	}
+	return t.resolve(category, subCategory)
+}
+
+// Tree returns the taxonomy as a tree with the number of listed products per category, named in the
+// given language where a translation exists
+func (s *CategoryService) Tree(locale string) ([]models.CategoryNode, error) {
+	t, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.categoryRepo.CountProducts(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	counts := map[string]int64{}
+	for _, u := range usage {
+		counts[u.Category] += u.Count
+		if u.SubCategory != "" {
+			counts[u.Category+"/"+u.SubCategory] += u.Count
+		}
+	}
+
+	tree := make([]models.CategoryNode, 0, len(t.roots))
+	for _, root := range t.roots {
+		node := models.CategoryNode{
+			ID:           root.ID,
+			Slug:         root.Slug,
+			Name:         localizedName(root, locale),
+			ProductCount: counts[root.Slug],
+		}
+		for _, child := range t.children[root.ID] {
+			node.Children = append(node.Children, models.CategoryNode{
+				ID:           child.ID,
+				Slug:         child.Slug,
+				Name:         localizedName(child, locale),
+				ProductCount: counts[root.Slug+"/"+child.Slug],
+			})
+		}
+		tree = append(tree, node)
+	}
+	return tree, nil
+}
+
+// localizedName returns the category's name in the given language, falling back from a regional
+// variant ("pt-BR") to the base language ("pt") and then to the default name
+func localizedName(category *models.Category, locale string) string {
+	locale = strings.ToLower(locale)
+	base, _, _ := strings.Cut(locale, "-")
+	fallback := ""
+	for _, name := range category.Names {
+		switch name.Locale {
+		case locale:
+			return name.Name
+		case base:
+			fallback = name.Name
+		}
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return category.Name
+}
+
+// Create adds a category to the taxonomy. Subcategories must belong to a top-level category.
+func (s *CategoryService) Create(req *models.CreateCategory) (*models.Category, error) {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if !slugPattern.MatchString(slug) {
+		return nil, fmt.Errorf("%w: slug must be lowercase letters and digits separated by hyphens", ErrInvalidCategory)
+	}
+
+	category := &models.Category{
+		ID:        uuid.New(),
+		Slug:      slug,
+		Name:      strings.TrimSpace(req.Name),
+		Position:  req.Position,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if req.ParentSlug != "" {
+		t, err := s.load()
+		if err != nil {
+			return nil, err
+		}
+		parent, ok := t.bySlug[strings.ToLower(req.ParentSlug)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown parent %q", ErrInvalidCategory, req.ParentSlug)
+		}
+		if parent.ParentID != nil {
+			return nil, fmt.Errorf("%w: %q is a subcategory and can't have subcategories", ErrInvalidCategory, parent.Slug)
+		}
+		category.ParentID = &parent.ID
+	}
+
+	for locale, name := range req.Names {
+		category.Names = append(category.Names, models.CategoryName{
+			CategoryID: category.ID,
+			Locale:     strings.ToLower(strings.TrimSpace(locale)),
+			Name:       strings.TrimSpace(name),
+		})
+	}
+
+	if err := s.categoryRepo.Create(category); err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return nil, ErrCategoryExists
+		}
+		return nil, fmt.Errorf("failed to create category: %w", err)
+	}
+	return category, nil
+}
+
+// Delete removes a category that has no subcategories and no products
+func (s *CategoryService) Delete(id uuid.UUID) error {
+	category, err := s.categoryRepo.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to fetch category: %w", err)
+	}
+	if category == nil {
+		return ErrCategoryNotFound
+	}
+
+	children, err := s.categoryRepo.CountChildren(id)
+	if err != nil {
+		return fmt.Errorf("failed to count subcategories: %w", err)
+	}
+	if children > 0 {
+		return fmt.Errorf("%w: it has %d subcategories", ErrCategoryInUse, children)
+	}
+
+	parentSlug := ""
+	if category.ParentID != nil {
+		parent, err := s.categoryRepo.GetByID(*category.ParentID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch parent category: %w", err)
+		}
+		if parent != nil {
+			parentSlug = parent.Slug
+		}
+	}
+	usage, err := s.categoryRepo.CountProducts(true)
+	if err != nil {
+		return fmt.Errorf("failed to count products: %w", err)
+	}
+	for _, u := range usage {
+		if (category.ParentID == nil && u.Category == category.Slug) ||
+			(category.ParentID != nil && u.Category == parentSlug && u.SubCategory == category.Slug) {
+			return fmt.Errorf("%w: products are listed under it", ErrCategoryInUse)
+		}
+	}
+
+	return s.categoryRepo.Delete(id)
+}
+
+// Migrate moves products with free-text categories onto the taxonomy. Explicit mappings win; other
+// values are matched against the category slugs and names. Values that match nothing are reported
+// as unmapped and left alone. On a dry run nothing is changed.
+func (s *CategoryService) Migrate(req *models.CategoryMigration) (*models.CategoryMigrationReport, error) {
+	t, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for i, mapping := range req.Mappings {
+		category, subCategory, err := t.resolve(mapping.Category, mapping.SubCategory)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %d: %w", i+1, err)
+		}
+		req.Mappings[i].Category, req.Mappings[i].SubCategory = category, subCategory
+	}
+
+	usage, err := s.categoryRepo.CountProducts(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	report := &models.CategoryMigrationReport{DryRun: req.DryRun, Mapped: []models.CategoryRemap{}, Unmapped: []models.CategoryRemap{}}
+	var remaps []models.CategoryRemap
+	for _, u := range usage {
+		remap := models.CategoryRemap{FromCategory: u.Category, FromSubCategory: u.SubCategory, Products: u.Count}
+		category, subCategory, ok := t.mapValue(u.Category, u.SubCategory, req.Mappings)
+		if !ok {
+			report.Unmapped = append(report.Unmapped, remap)
+			continue
+		}
+		remap.Category, remap.SubCategory = category, subCategory
+
+		// The database compares case-insensitively, so a group that already reads canonical may still
+		// hold rows spelled differently; it is remapped too but not reported
+		remaps = append(remaps, remap)
+		if category != u.Category || subCategory != u.SubCategory {
+			report.Mapped = append(report.Mapped, remap)
+		}
+	}
+
+	if req.DryRun || len(remaps) == 0 {
+		return report, nil
+	}
+	if report.Updated, err = s.categoryRepo.Remap(remaps); err != nil {
+		return nil, fmt.Errorf("failed to migrate categories: %w", err)
+	}
+	return report, nil
+}
+
+// mapValue finds the canonical category/subcategory for a free-text pair, first through the explicit
+// mappings, then by matching names. A category value that names a subcategory maps onto it and its parent.
+func (t *taxonomy) mapValue(category, subCategory string, mappings []models.CategoryMapping) (string, string, bool) {
+	var wildcard *models.CategoryMapping
+	for i, mapping := range mappings {
+		if !strings.EqualFold(strings.TrimSpace(mapping.FromCategory), category) {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(mapping.FromSubCategory), subCategory) {
+			return mapping.Category, mapping.SubCategory, true
+		}
+		if mapping.FromSubCategory == "" && wildcard == nil {
+			wildcard = &mappings[i]
+		}
+	}
+	if wildcard != nil {
+		if wildcard.SubCategory != "" || subCategory == "" {
+			return wildcard.Category, wildcard.SubCategory, true
+		}
+		// Keep the subcategory when it matches one under the mapped category
+		root := t.bySlug[wildcard.Category]
+		if sub := match(subCategory, t.children[root.ID]); sub != nil {
+			return root.Slug, sub.Slug, true
+		}
+		return "", "", false
+	}
+
+	root := match(category, t.roots)
+	if root == nil {
+		if subCategory != "" {
+			return "", "", false
+		}
+		for _, parent := range t.roots {
+			if sub := match(category, t.children[parent.ID]); sub != nil {
+				return parent.Slug, sub.Slug, true
+			}
+		}
+		return "", "", false
+	}
+	if subCategory == "" {
+		return root.Slug, "", true
+	}
+	if sub := match(subCategory, t.children[root.ID]); sub != nil {
+		return root.Slug, sub.Slug, true
+	}
+	return "", "", false
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"backend/models"
+	"backend/pagination"
 	"backend/repository"
 	"errors"
 
@@ -11,8 +12,8 @@ import (
 // CommentService defines the interface for comment services
 type CommentService interface {
 	Create(commentData *models.AddComment, userID string) (*models.Comment, error)
-	Delete(id uuid.UUID) error
-	GetByProductID(productID uuid.UUID) ([]models.Comment, error)
+	Delete(id uuid.UUID, actor Actor) error
+	GetByProductID(productID uuid.UUID, params pagination.Params) ([]models.Comment, pagination.Page, error)
 	Update(id uuid.UUID, content string) (*models.Comment, error)
 	GetByID(id uuid.UUID) (*models.Comment, error)
 }
@@ -51,14 +52,27 @@ func (s *commentService) Create(commentData *models.AddComment, userID string) (
 	return comment, nil
 }
 
-// Delete removes a comment by ID
-func (s *commentService) Delete(id uuid.UUID) error {
+// Delete removes a comment by ID, if the actor is allowed to
+func (s *commentService) Delete(id uuid.UUID, actor Actor) error {
+	comment, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if comment == nil {
+		return ErrCommentNotFound
+	}
+
+	// Only the author or a moderator may delete a comment
+	if err := Authorize(actor, PermDeleteComment, comment.UserID); err != nil {
+		return err
+	}
+
 	return s.repo.Delete(id)
 }
 
-// GetByProductID fetches all comments for a given product
-func (s *commentService) GetByProductID(productID uuid.UUID) ([]models.Comment, error) {
-	return s.repo.GetByProductID(productID)
+// GetByProductID fetches a page of the comments on a given product, newest first
+func (s *commentService) GetByProductID(productID uuid.UUID, params pagination.Params) ([]models.Comment, pagination.Page, error) {
+	return s.repo.GetByProductID(productID, params)
 }
 
 // GetByID retrieves a comment by its ID from the repository
@@ -66,7 +80,10 @@ func (s *commentService) GetByID(id uuid.UUID) (*models.Comment, error) {
 	// Call the repository to find the comment by its ID
 	comment, err := s.repo.FindByID(id)
 	if err != nil {
-		return nil, err // return the error if comment is not found or any other error occurs
+		return nil, err // return the error if any error occurs
+	}
+	if comment == nil {
+		return nil, ErrCommentNotFound
 	}
 	return comment, nil
 }
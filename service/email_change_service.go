@@ -0,0 +1,167 @@
+package service
+
+import (
+	"backend/models"
+	"backend/repository"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// emailChangeTTL is how long the confirmation and cancellation links of an email change stay valid
+const emailChangeTTL = 24 * time.Hour
+
+var ErrEmailChangeNotFound = errors.New("no pending email change")
+
+// EmailChangeService handles changing a user's email. The new address has to be confirmed before
+// the swap, and the old address gets a link to cancel the change.
+type EmailChangeService struct {
+	emailChangeRepo *repository.EmailChangeRepository
+	userRepo        *repository.UserRepository
+	tokenService    *OneTimeTokenService
+	sessionService  *SessionService
+}
+
+// NewEmailChangeService creates a new instance of EmailChangeService
+func NewEmailChangeService(emailChangeRepo *repository.EmailChangeRepository, userRepo *repository.UserRepository, tokenService *OneTimeTokenService, sessionService *SessionService) *EmailChangeService {
+	return &EmailChangeService{
+		emailChangeRepo: emailChangeRepo,
+		userRepo:        userRepo,
+		tokenService:    tokenService,
+		sessionService:  sessionService,
+	}
+}
+
+// Request starts an email change, replacing any change the user still had pending
+func (s *EmailChangeService) Request(userID, newEmail string) (*models.EmailChange, error) {
+	newEmail = strings.TrimSpace(newEmail)
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if strings.EqualFold(user.Email, newEmail) {
+		return nil, ErrInvalidInput
+	}
+
+	taken, err := s.userRepo.GetByEmail(newEmail)
+	if err != nil {
+		return nil, err
+	}
+	if taken != nil {
+		return nil, ErrEmailAlreadyExists
+	}
+
+	// Only the latest request can be confirmed
+	if err := s.cancelPending(userID); err != nil {
+		return nil, err
+	}
+
+	change := &models.EmailChange{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		Status:    models.EmailChangePending,
+		ExpiresAt: time.Now().UTC().Add(emailChangeTTL),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.emailChangeRepo.Create(change); err != nil {
+		return nil, fmt.Errorf("failed to store email change: %w", err)
+	}
+
+	confirmToken, err := s.tokenService.Issue(user.ID, models.TokenPurposeEmailChange, emailChangeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate confirmation token: %w", err)
+	}
+	cancelToken, err := s.tokenService.Issue(user.ID, models.TokenPurposeEmailChangeCancel, emailChangeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate cancellation token: %w", err)
+	}
+
+	confirmLink := fmt.Sprintf("https://%s/confirm-email-change?token=%s", os.Getenv("FE_PORT"), confirmToken)
+	cancelLink := fmt.Sprintf("https://%s/cancel-email-change?token=%s", os.Getenv("FE_PORT"), cancelToken)
+	go func() {
+		if err := SendEmailChangeConfirmation(change.NewEmail, confirmLink); err != nil {
+			log.Printf("Failed to send email change confirmation: %v", err)
+		}
+		if err := SendEmailChangeAlert(change.OldEmail, change.NewEmail, cancelLink); err != nil {
+			log.Printf("Failed to send email change alert: %v", err)
+		}
+	}()
+
+	return change, nil
+}
+
+// Confirm redeems the link sent to the new address and swaps the email
+func (s *EmailChangeService) Confirm(token string) error {
+	userID, err := s.tokenService.Redeem(token, models.TokenPurposeEmailChange)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	change, err := s.emailChangeRepo.GetPendingByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if change == nil {
+		return ErrEmailChangeNotFound
+	}
+
+	applied, err := s.emailChangeRepo.Apply(change)
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			// Someone else took the address after the request was made
+			if _, err := s.emailChangeRepo.Complete(change.ID.String(), models.EmailChangeFailed); err != nil {
+				log.Printf("Failed to mark email change %s as failed: %v", change.ID, err)
+			}
+			return ErrEmailAlreadyExists
+		}
+		return fmt.Errorf("failed to change email: %w", err)
+	}
+	if !applied {
+		return ErrEmailChangeNotFound
+	}
+
+	// The cancellation link has nothing left to cancel
+	if err := s.tokenService.RevokeAll(userID, models.TokenPurposeEmailChangeCancel); err != nil {
+		log.Printf("Failed to revoke email change cancellation tokens of user %s: %v", userID, err)
+	}
+	return nil
+}
+
+// Cancel redeems the link sent to the old address. Since the user did not ask for the change,
+// their account may be compromised, so all sessions are revoked as well.
+func (s *EmailChangeService) Cancel(token string) error {
+	userID, err := s.tokenService.Redeem(token, models.TokenPurposeEmailChangeCancel)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if err := s.cancelPending(userID); err != nil {
+		return err
+	}
+
+	return s.sessionService.RevokeAll(userID)
+}
+
+// cancelPending ends the user's pending changes and invalidates their links
+func (s *EmailChangeService) cancelPending(userID string) error {
+	if err := s.emailChangeRepo.CancelPendingByUserID(userID); err != nil {
+		return fmt.Errorf("failed to cancel pending email change: %w", err)
+	}
+	for _, purpose := range []string{models.TokenPurposeEmailChange, models.TokenPurposeEmailChangeCancel} {
+		if err := s.tokenService.RevokeAll(userID, purpose); err != nil {
+			return fmt.Errorf("failed to revoke email change tokens: %w", err)
+		}
+	}
+	return nil
+}
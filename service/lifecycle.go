@@ -0,0 +1,151 @@
+package service
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidAction     = errors.New("invalid action")
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+// transition describes what an action does to a product: the statuses it may be taken from, the status
+// it leads to, who may take it and the guards it must pass
+type transition struct {
+	from         []models.ProductStatus // Empty for the action that creates a product
+	to           models.ProductStatus   // Empty when the status stays as it is
+	perm         Permission             // Empty when the service recording the action decides who may take it
+	requireImage bool                   // The action must come with at least one image
+	via          string                 // How the action is recorded when it has its own endpoint
+}
+
+// lifecycle is the single source of truth for how products move between statuses. A product is
+// submitted, reserved by a buyer's order and sold to them, and may be revitalized and listed again
+// by its new owner; at any point before a sale it can be withdrawn.
+var lifecycle = map[models.TransactionAction]transition{
+	models.Submitted: {
+		to:   models.StatusAvailable,
+		perm: PermAddTransaction,
+	},
+	models.Revitalized: {
+		from:         []models.ProductStatus{models.StatusAvailable, models.StatusSold},
+		to:           models.StatusRestored,
+		perm:         PermAddTransaction,
+		requireImage: true, // The photos are the proof of the work
+	},
+	models.SubmittedRevitalized: {
+		from: []models.ProductStatus{models.StatusRestored},
+		to:   models.StatusRestoredAvailable,
+		perm: PermAddTransaction,
+	},
+	models.Reserved: {
+		from: []models.ProductStatus{models.StatusAvailable, models.StatusRestoredAvailable},
+		to:   models.StatusReserved,
+		via:  "placing an order",
+	},
+	models.Released: {
+		from: []models.ProductStatus{models.StatusReserved},
+		to:   "", // Back to the status before the reservation, which the order keeps
+		via:  "declining or cancelling the order",
+	},
+	models.Sold: {
+		from: []models.ProductStatus{models.StatusReserved},
+		to:   models.StatusSold,
+		via:  "completing the order",
+	},
+	models.Updated: {
+		from: []models.ProductStatus{models.StatusAvailable, models.StatusRestored, models.StatusRestoredAvailable},
+		perm: PermEditProduct,
+		via:  "editing the product",
+	},
+	models.Overridden: {
+		from: []models.ProductStatus{models.StatusAvailable, models.StatusRestored, models.StatusRestoredAvailable, models.StatusReserved, models.StatusSold, models.StatusWithdrawn},
+		to:   "", // Whatever status the moderator sets
+		perm: PermOverrideStatus,
+		via:  "a moderator's status override",
+	},
+	models.Withdrawn: {
+		from: []models.ProductStatus{models.StatusAvailable, models.StatusRestored, models.StatusRestoredAvailable},
+		to:   models.StatusWithdrawn,
+		perm: PermEditProduct,
+		via:  "withdrawing the product",
+	},
+	models.Amended: {
+		from: []models.ProductStatus{models.StatusAvailable, models.StatusRestored, models.StatusRestoredAvailable, models.StatusReserved, models.StatusSold, models.StatusWithdrawn},
+		to:   "", // Corrections never move the product
+		via:  "amending a transaction",
+	},
+}
+
+// InitialStatus is the status a product starts in once it is submitted
+func InitialStatus() models.ProductStatus {
+	return lifecycle[models.Submitted].to
+}
+
+// Transition checks that the actor may take action on the product in its current status and returns the
+// status the product moves to. hasImage tells whether the action comes with images. The errors wrap
+// ErrInvalidAction for unknown actions and failed guards, ErrInvalidTransition when the product's status
+// does not allow the action, and ErrForbidden when the actor may not take it.
+func Transition(actor Actor, product *models.Product, action models.TransactionAction, hasImage bool) (models.ProductStatus, error) {
+	return transitionAs(actor, product, action, hasImage, lifecycle[action].perm, product.UserID)
+}
+
+// transitionAs is Transition for an action the owner handed over to someone else, such as the workshop
+// that won a revitalization job: perm is checked against ownerID instead of the product's owner
+func transitionAs(actor Actor, product *models.Product, action models.TransactionAction, hasImage bool, perm Permission, ownerID uuid.UUID) (models.ProductStatus, error) {
+	t, ok := lifecycle[action]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown action %q", ErrInvalidAction, action)
+	}
+	if len(t.from) == 0 {
+		return "", fmt.Errorf("%w: the product was already %s", ErrInvalidTransition, action)
+	}
+	if !containsStatus(t.from, product.Status) {
+		return "", fmt.Errorf("%w: cannot record %q on a product that is %q; allowed when it is %s",
+			ErrInvalidTransition, action, product.Status, joinStatuses(t.from))
+	}
+	if perm != "" {
+		if err := Authorize(actor, perm, ownerID); err != nil {
+			return "", err
+		}
+	}
+	if t.requireImage && !hasImage {
+		return "", fmt.Errorf("%w: %q requires at least one image", ErrInvalidAction, action)
+	}
+
+	if t.to == "" {
+		return product.Status, nil
+	}
+	return t.to, nil
+}
+
+func containsStatus(statuses []models.ProductStatus, status models.ProductStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAction(actions []models.TransactionAction, action models.TransactionAction) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func joinStatuses(statuses []models.ProductStatus) string {
+	quoted := make([]string, len(statuses))
+	for i, s := range statuses {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, " or ")
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"backend/models"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var allStatuses = []models.ProductStatus{
+	models.StatusAvailable,
+	models.StatusRestored,
+	models.StatusRestoredAvailable,
+	models.StatusReserved,
+	models.StatusSold,
+	models.StatusWithdrawn,
+}
+
+var allActions = []models.TransactionAction{
+	models.Submitted,
+	models.SubmittedRevitalized,
+	models.Revitalized,
+	models.Sold,
+	models.Updated,
+	models.Withdrawn,
+	models.Reserved,
+	models.Released,
+	models.Overridden,
+	models.Amended,
+}
+
+// expectedTransitions spells out the lifecycle independently of the lifecycle map: the status each action
+// leads to from every status it may be taken in, and who may take it. Actions the order and transaction
+// services decide on are open to everyone here.
+var expectedTransitions = map[models.TransactionAction]struct {
+	to                             map[models.ProductStatus]models.ProductStatus
+	owner, other, moderator, admin bool
+}{
+	models.Submitted: {
+		owner: true,
+	},
+	models.Revitalized: {
+		to: map[models.ProductStatus]models.ProductStatus{
+			models.StatusAvailable: models.StatusRestored,
+			models.StatusSold:      models.StatusRestored,
+		},
+		owner: true,
+	},
+	models.SubmittedRevitalized: {
+		to: map[models.ProductStatus]models.ProductStatus{
+			models.StatusRestored: models.StatusRestoredAvailable,
+		},
+		owner: true,
+	},
+	models.Reserved: {
+		to: map[models.ProductStatus]models.ProductStatus{
+			models.StatusAvailable:         models.StatusReserved,
+			models.StatusRestoredAvailable: models.StatusReserved,
+		},
+		owner: true, other: true, moderator: true, admin: true,
+	},
+	models.Released: {
+		to: map[models.ProductStatus]models.ProductStatus{
+			models.StatusReserved: models.StatusReserved, // The order service restores the status from before
+		},
+		owner: true, other: true, moderator: true, admin: true,
+	},
+	models.Sold: {
+		to: map[models.ProductStatus]models.ProductStatus{
+			models.StatusReserved: models.StatusSold,
+		},
+		owner: true, other: true, moderator: true, admin: true,
+	},
+	models.Updated: {
+		to: map[models.ProductStatus]models.ProductStatus{
+			models.StatusAvailable:         models.StatusAvailable,
+			models.StatusRestored:          models.StatusRestored,
+			models.StatusRestoredAvailable: models.StatusRestoredAvailable,
+		},
+		owner: true, moderator: true, admin: true,
+	},
+	models.Withdrawn: {
+		to: map[models.ProductStatus]models.ProductStatus{
+			models.StatusAvailable:         models.StatusWithdrawn,
+			models.StatusRestored:          models.StatusWithdrawn,
+			models.StatusRestoredAvailable: models.StatusWithdrawn,
+		},
+		owner: true, moderator: true, admin: true,
+	},
+	models.Overridden: {
+		to: map[models.ProductStatus]models.ProductStatus{
+			models.StatusAvailable:         models.StatusAvailable, // The service sets the status the moderator chose
+			models.StatusRestored:          models.StatusRestored,
+			models.StatusRestoredAvailable: models.StatusRestoredAvailable,
+			models.StatusReserved:          models.StatusReserved,
+			models.StatusSold:              models.StatusSold,
+			models.StatusWithdrawn:         models.StatusWithdrawn,
+		},
+		moderator: true, admin: true,
+	},
+	models.Amended: {
+		to: map[models.ProductStatus]models.ProductStatus{
+			models.StatusAvailable:         models.StatusAvailable,
+			models.StatusRestored:          models.StatusRestored,
+			models.StatusRestoredAvailable: models.StatusRestoredAvailable,
+			models.StatusReserved:          models.StatusReserved,
+			models.StatusSold:              models.StatusSold,
+			models.StatusWithdrawn:         models.StatusWithdrawn,
+		},
+		owner: true, other: true, moderator: true, admin: true,
+	},
+}
+
+func TestLifecycleIsCovered(t *testing.T) {
+	for action := range lifecycle {
+		if _, ok := expectedTransitions[action]; !ok {
+			t.Errorf("action %s has no expectation in this test", action)
+		}
+	}
+	for _, action := range allActions {
+		if _, ok := lifecycle[action]; !ok {
+			t.Errorf("expected a transition for %s", action)
+		}
+	}
+}
+
+func TestTransition(t *testing.T) {
+	ownerID := uuid.New()
+	actors := []struct {
+		name  string
+		actor Actor
+		allow func(owner, other, moderator, admin bool) bool
+	}{
+		{"owner", Actor{ID: ownerID, Role: models.RoleUser}, func(owner, _, _, _ bool) bool { return owner }},
+		{"other user", Actor{ID: uuid.New(), Role: models.RoleUser}, func(_, other, _, _ bool) bool { return other }},
+		{"moderator", Actor{ID: uuid.New(), Role: models.RoleModerator}, func(_, _, moderator, _ bool) bool { return moderator }},
+		{"admin", Actor{ID: uuid.New(), Role: models.RoleAdmin}, func(_, _, _, admin bool) bool { return admin }},
+	}
+
+	for _, action := range allActions {
+		want := expectedTransitions[action]
+		for _, status := range allStatuses {
+			for _, a := range actors {
+				t.Run(string(action)+"/"+string(status)+"/"+a.name, func(t *testing.T) {
+					product := &models.Product{ID: uuid.New(), UserID: ownerID, Status: status}
+					got, err := Transition(a.actor, product, action, true)
+
+					to, allowedFrom := want.to[status]
+					switch {
+					case !allowedFrom:
+						if !errors.Is(err, ErrInvalidTransition) {
+							t.Fatalf("expected ErrInvalidTransition, got %q, %v", got, err)
+						}
+					case !a.allow(want.owner, want.other, want.moderator, want.admin):
+						if !errors.Is(err, ErrForbidden) {
+							t.Fatalf("expected ErrForbidden, got %q, %v", got, err)
+						}
+					case err != nil:
+						t.Fatalf("expected %q, got %v", to, err)
+					case got != to:
+						t.Fatalf("expected %q, got %q", to, got)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestTransitionRejectsUnknownAction(t *testing.T) {
+	ownerID := uuid.New()
+	product := &models.Product{ID: uuid.New(), UserID: ownerID, Status: models.StatusAvailable}
+	if _, err := Transition(Actor{ID: ownerID, Role: models.RoleUser}, product, models.TransactionAction("repainted"), true); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected ErrInvalidAction, got %v", err)
+	}
+}
+
+func TestRevitalizedRequiresImage(t *testing.T) {
+	ownerID := uuid.New()
+	for _, status := range []models.ProductStatus{models.StatusAvailable, models.StatusSold} {
+		product := &models.Product{ID: uuid.New(), UserID: ownerID, Status: status}
+
+		if _, err := Transition(Actor{ID: ownerID, Role: models.RoleUser}, product, models.Revitalized, false); !errors.Is(err, ErrInvalidAction) {
+			t.Errorf("%s: expected ErrInvalidAction without an image, got %v", status, err)
+		}
+		// Who may take the action is checked before its guards
+		if _, err := Transition(Actor{ID: uuid.New(), Role: models.RoleUser}, product, models.Revitalized, false); !errors.Is(err, ErrForbidden) {
+			t.Errorf("%s: expected ErrForbidden for another user, got %v", status, err)
+		}
+	}
+
+	// Actions without the guard do not need an image
+	product := &models.Product{ID: uuid.New(), UserID: ownerID, Status: models.StatusRestored}
+	if _, err := Transition(Actor{ID: ownerID, Role: models.RoleUser}, product, models.SubmittedRevitalized, false); err != nil {
+		t.Fatalf("expected submittedRevitalized without an image to be allowed, got %v", err)
+	}
+}
+
+func TestTransitionAsWorkshop(t *testing.T) {
+	ownerID, workshopID := uuid.New(), uuid.New()
+	actors := []struct {
+		name  string
+		actor Actor
+		allow bool
+	}{
+		{"workshop", Actor{ID: workshopID, Role: models.RoleWorkshop}, true},
+		{"owner", Actor{ID: ownerID, Role: models.RoleUser}, false},
+		{"other user", Actor{ID: uuid.New(), Role: models.RoleUser}, false},
+		{"moderator", Actor{ID: uuid.New(), Role: models.RoleModerator}, false},
+		{"admin", Actor{ID: uuid.New(), Role: models.RoleAdmin}, false},
+	}
+
+	for _, status := range allStatuses {
+		for _, a := range actors {
+			t.Run(string(status)+"/"+a.name, func(t *testing.T) {
+				product := &models.Product{ID: uuid.New(), UserID: ownerID, Status: status}
+				got, err := transitionAs(a.actor, product, models.Revitalized, true, PermWorkRevitalization, workshopID)
+
+				switch {
+				case status != models.StatusAvailable && status != models.StatusSold:
+					if !errors.Is(err, ErrInvalidTransition) {
+						t.Fatalf("expected ErrInvalidTransition, got %q, %v", got, err)
+					}
+				case !a.allow:
+					if !errors.Is(err, ErrForbidden) {
+						t.Fatalf("expected ErrForbidden, got %q, %v", got, err)
+					}
+				case err != nil || got != models.StatusRestored:
+					t.Fatalf("expected %q, got %q, %v", models.StatusRestored, got, err)
+				}
+			})
+		}
+	}
+
+	// The workshop must still bring the photos of its work
+	product := &models.Product{ID: uuid.New(), UserID: ownerID, Status: models.StatusAvailable}
+	if _, err := transitionAs(Actor{ID: workshopID, Role: models.RoleWorkshop}, product, models.Revitalized, false, PermWorkRevitalization, workshopID); !errors.Is(err, ErrInvalidAction) {
+		t.Fatalf("expected ErrInvalidAction without an image, got %v", err)
+	}
+}
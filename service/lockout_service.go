@@ -0,0 +1,294 @@
+package service
+
+import (
+	"backend/models"
+	"backend/pagination"
+	"backend/repository"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrTooManyAttempts = errors.New("too many attempts, please try again later")
+
+// ThrottledError is returned while a throttling key is blocked. It matches ErrTooManyAttempts.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// lockoutPolicy describes how failures for one kind of key are punished
+type lockoutPolicy struct {
+	delayAfter int           // Failures after which every further attempt has to wait
+	baseDelay  time.Duration // First wait, doubled with every further failure
+	maxDelay   time.Duration
+	lockAfter  int           // Failures after which the key is locked out
+	lockFor    time.Duration // First lockout, doubled with every further failure
+	maxLock    time.Duration
+	window     time.Duration // Failures are forgotten after this long without a new one
+}
+
+// throttleScope is the kind of key a counter is kept for
+type throttleScope string
+
+const (
+	scopeLoginAccount throttleScope = "login-account"
+	scopeLoginIP      throttleScope = "login-ip"
+	scopeEmailAccount throttleScope = "email-account"
+	scopeEmailIP      throttleScope = "email-ip"
+)
+
+// lockoutPolicies are deliberately looser per IP, since many users can share one
+var lockoutPolicies = map[throttleScope]lockoutPolicy{
+	scopeLoginAccount: {delayAfter: 3, baseDelay: time.Second, maxDelay: 30 * time.Second, lockAfter: 10, lockFor: 15 * time.Minute, maxLock: 24 * time.Hour, window: time.Hour},
+	scopeLoginIP:      {delayAfter: 20, baseDelay: time.Second, maxDelay: 30 * time.Second, lockAfter: 100, lockFor: 15 * time.Minute, maxLock: 24 * time.Hour, window: time.Hour},
+	scopeEmailAccount: {delayAfter: 1, baseDelay: time.Minute, maxDelay: 15 * time.Minute, lockAfter: 10, lockFor: time.Hour, maxLock: 24 * time.Hour, window: 24 * time.Hour},
+	scopeEmailIP:      {delayAfter: 10, baseDelay: 10 * time.Second, maxDelay: 5 * time.Minute, lockAfter: 50, lockFor: time.Hour, maxLock: 24 * time.Hour, window: time.Hour},
+}
+
+// throttleKey identifies one counter, e.g. the login failures of one email address
+type throttleKey struct {
+	scope throttleScope
+	value string
+}
+
+func (k throttleKey) String() string {
+	return fmt.Sprintf("%s:%s", k.scope, k.value)
+}
+
+// LockoutService throttles login, password reset and verification attempts per account and per IP,
+// and keeps the login history of every user
+type LockoutService struct {
+	lockoutRepo *repository.LockoutRepository
+	userRepo    *repository.UserRepository
+}
+
+// NewLockoutService creates a new instance of LockoutService
+func NewLockoutService(lockoutRepo *repository.LockoutRepository, userRepo *repository.UserRepository) *LockoutService {
+	return &LockoutService{lockoutRepo: lockoutRepo, userRepo: userRepo}
+}
+
+// CheckLogin rejects a login attempt while the account or the client's IP is blocked. Accounts are
+// keyed by email, so unknown emails are throttled exactly like real ones.
+func (s *LockoutService) CheckLogin(action models.AttemptAction, email string, client models.ClientInfo) error {
+	if err := s.check(loginKeys(email, client)...); err != nil {
+		if errors.Is(err, ErrTooManyAttempts) {
+			s.record(action, email, nil, client, false, "locked")
+		}
+		return err
+	}
+	return nil
+}
+
+// LoginFailed counts a failed login or second-factor attempt and adds it to the history
+func (s *LockoutService) LoginFailed(action models.AttemptAction, email string, user *models.User, client models.ClientInfo, reason string) {
+	for _, key := range loginKeys(email, client) {
+		if err := s.fail(key); err != nil {
+			log.Printf("Failed to count failed attempt for %s: %v", key, err)
+		}
+	}
+	s.record(action, email, user, client, false, reason)
+}
+
+// LoginSucceeded clears the account's failures and adds the login to the history. The IP counter is
+// kept, so logging into one account does not reset an attack on others.
+func (s *LockoutService) LoginSucceeded(action models.AttemptAction, user *models.User, client models.ClientInfo) {
+	if err := s.lockoutRepo.DeleteCounter(accountKey(scopeLoginAccount, user.Email).String()); err != nil {
+		log.Printf("Failed to clear failed attempts of user %s: %v", user.ID, err)
+	}
+	s.record(action, user.Email, user, client, true, "")
+}
+
+// ThrottleEmail limits how often password reset or verification emails can be requested. Every
+// request counts, whether or not the email belongs to an account.
+func (s *LockoutService) ThrottleEmail(action models.AttemptAction, email string, client models.ClientInfo) error {
+	keys := []throttleKey{accountKey(scopeEmailAccount, email)}
+	if client.IPAddress != "" {
+		keys = append(keys, throttleKey{scope: scopeEmailIP, value: client.IPAddress})
+	}
+
+	if err := s.check(keys...); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.fail(key); err != nil {
+			return err
+		}
+	}
+	s.record(action, email, nil, client, true, "")
+	return nil
+}
+
+// Status returns the login lockout state of a user
+func (s *LockoutService) Status(userID string) (*models.LockoutStatus, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	counter, err := s.lockoutRepo.GetCounter(accountKey(scopeLoginAccount, user.Email).String())
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.LockoutStatus{}
+	if counter == nil {
+		return status, nil
+	}
+	status.Failures = counter.Failures
+	status.LastFailureAt = &counter.LastFailureAt
+	if counter.Locked && counter.BlockedUntil != nil && time.Now().Before(*counter.BlockedUntil) {
+		status.Locked = true
+		status.LockedUntil = counter.BlockedUntil
+	}
+	return status, nil
+}
+
+// Unlock lifts the login lockout of a user and forgets their failed attempts
+func (s *LockoutService) Unlock(userID string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.lockoutRepo.DeleteCounter(accountKey(scopeLoginAccount, user.Email).String())
+}
+
+// ListAttempts returns a page of the login history of a user, newest first
+func (s *LockoutService) ListAttempts(userID string, params pagination.Params) ([]models.LoginAttempt, pagination.Page, error) {
+	return s.lockoutRepo.ListAttemptsByUserID(userID, params)
+}
+
+// check returns a ThrottledError for the longest running block among the keys
+func (s *LockoutService) check(keys ...throttleKey) error {
+	var retryAfter time.Duration
+	for _, key := range keys {
+		counter, err := s.lockoutRepo.GetCounter(key.String())
+		if err != nil {
+			return fmt.Errorf("failed to check attempts: %w", err)
+		}
+		if counter == nil || counter.BlockedUntil == nil {
+			continue
+		}
+		if wait := time.Until(*counter.BlockedUntil); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return &ThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// fail counts a failure for the key and blocks it according to its policy
+func (s *LockoutService) fail(key throttleKey) error {
+	policy := lockoutPolicies[key.scope]
+	now := time.Now().UTC()
+
+	_, err := s.lockoutRepo.UpdateCounter(key.String(), func(counter *models.AttemptCounter) {
+		if now.Sub(counter.LastFailureAt) > policy.window {
+			counter.Failures = 0
+		}
+		counter.Failures++
+		counter.LastFailureAt = now
+		counter.Locked = false
+		counter.BlockedUntil = nil
+
+		switch {
+		case counter.Failures >= policy.lockAfter:
+			until := now.Add(backoff(policy.lockFor, policy.maxLock, counter.Failures-policy.lockAfter))
+			counter.Locked = true
+			counter.BlockedUntil = &until
+		case counter.Failures >= policy.delayAfter:
+			until := now.Add(backoff(policy.baseDelay, policy.maxDelay, counter.Failures-policy.delayAfter))
+			counter.BlockedUntil = &until
+		}
+	})
+	return err
+}
+
+// record adds an attempt to the login history. The history is best effort and never fails a request.
+func (s *LockoutService) record(action models.AttemptAction, email string, user *models.User, client models.ClientInfo, success bool, reason string) {
+	attempt := &models.LoginAttempt{
+		ID:        uuid.New(),
+		Email:     truncate(normalizeEmail(email), 255),
+		IPAddress: client.IPAddress,
+		UserAgent: truncate(client.UserAgent, 255),
+		Action:    action,
+		Success:   success,
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+	if err := s.lockoutRepo.CreateAttempt(attempt); err != nil {
+		log.Printf("Failed to record %s attempt: %v", action, err)
+	}
+}
+
+// loginKeys returns the account and IP keys a login attempt is counted against
+func loginKeys(email string, client models.ClientInfo) []throttleKey {
+	keys := []throttleKey{accountKey(scopeLoginAccount, email)}
+	if client.IPAddress != "" {
+		keys = append(keys, throttleKey{scope: scopeLoginIP, value: client.IPAddress})
+	}
+	return keys
+}
+
+func accountKey(scope throttleScope, email string) throttleKey {
+	return throttleKey{scope: scope, value: truncate(normalizeEmail(email), 160)}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash is a bcrypt hash nobody knows the password of, checked against for unknown emails
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		password, err := randomURLSafe(32)
+		if err == nil {
+			dummyHash, err = HashPassword(password)
+		}
+		if err != nil {
+			log.Printf("Failed to create dummy password hash: %v", err)
+		}
+	})
+	return dummyHash
+}
+
+// backoff doubles base once per step, capped at max
+func backoff(base, max time.Duration, step int) time.Duration {
+	delay := base
+	for i := 0; i < step && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
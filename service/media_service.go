@@ -0,0 +1,262 @@
+package service
+
+import (
+	"backend/models"
+	"backend/repository"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMediaNotFound = errors.New("image not found")
+	ErrInvalidMedia  = errors.New("invalid image")
+)
+
+// MediaService manages product galleries: the ordered images of a product and of its transactions
+type MediaService struct {
+	mediaRepo       *repository.MediaRepository
+	productRepo     *repository.ProductRepository
+	transactionRepo *repository.TransactionRepository
+}
+
+// NewMediaService creates a new instance of MediaService
+func NewMediaService(mediaRepo *repository.MediaRepository, productRepo *repository.ProductRepository, transactionRepo *repository.TransactionRepository) *MediaService {
+	return &MediaService{mediaRepo: mediaRepo, productRepo: productRepo, transactionRepo: transactionRepo}
+}
+
+// upload decodes and stores images in S3 and returns their gallery rows, positioned after the product's
+// existing images. The rows are not saved yet, so callers can store them together with the record
+// they belong to.
+func (s *MediaService) upload(productID uuid.UUID, transactionID *uuid.UUID, uploadedBy uuid.UUID, uploads []models.MediaUpload) ([]models.Media, error) {
+	if len(uploads) == 0 {
+		return nil, nil
+	}
+
+	position, err := s.mediaRepo.NextPosition(productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gallery: %w", err)
+	}
+
+	media := make([]models.Media, 0, len(uploads))
+	for i, upload := range uploads {
+		imageData, err := base64.StdEncoding.DecodeString(upload.ImageData)
+		if err != nil {
+			s.discard(media)
+			return nil, fmt.Errorf("%w: image %d is not valid base64: %v", ErrInvalidMedia, i+1, err)
+		}
+
+		item := models.Media{
+			ID:            uuid.New(),
+			ProductID:     productID,
+			TransactionID: transactionID,
+			UploadedBy:    uploadedBy,
+			Caption:       upload.Caption,
+			Position:      position + i,
+			CreatedAt:     time.Now().UTC(),
+		}
+		item.ImageKey = fmt.Sprintf("images/%s.jpg", item.ID)
+		if item.URL, err = PutImage(item.ImageKey, imageData); err != nil {
+			s.discard(media)
+			return nil, fmt.Errorf("failed to upload image %d: %w", i+1, err)
+		}
+		media = append(media, item)
+	}
+	return media, nil
+}
+
+// save stores gallery rows returned by upload. When that fails, the uploaded images are removed again.
+func (s *MediaService) save(media []models.Media) error {
+	if err := s.mediaRepo.Create(media); err != nil {
+		s.discard(media)
+		return fmt.Errorf("failed to save images: %w", err)
+	}
+	return nil
+}
+
+// discard removes the S3 objects of gallery rows returned by upload that could not be saved, so a failed
+// operation leaves no orphaned images behind. Failures are logged; the objects are unreachable anyway.
+func (s *MediaService) discard(media []models.Media) {
+	for _, item := range media {
+		if err := DeleteImage(item.ImageKey); err != nil {
+			log.Printf("Failed to delete image %s from S3: %v", item.ImageKey, err)
+		}
+	}
+}
+
+// Gallery returns a product's images in display order with pre-signed URLs. When no image was chosen
+// as cover, the first one is.
+func (s *MediaService) Gallery(productID uuid.UUID) ([]models.Media, error) {
+	media, err := s.mediaRepo.GetByProductID(productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gallery: %w", err)
+	}
+
+	hasCover := false
+	for i := range media {
+		url, err := GetImage(media[i].ImageKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve image URL: %v", err)
+		}
+		media[i].URL = url
+		hasCover = hasCover || media[i].IsCover
+	}
+	if !hasCover && len(media) > 0 {
+		media[0].IsCover = true
+	}
+	return media, nil
+}
+
+// Add uploads images to a product's gallery on behalf of its owner or a moderator. Images attached to
+// a transaction must belong to one of the product's transactions.
+func (s *MediaService) Add(actor Actor, productID uuid.UUID, req *models.AddMedia) ([]models.Media, error) {
+	if _, err := s.getEditableProduct(actor, productID); err != nil {
+		return nil, err
+	}
+	if req.TransactionID != nil {
+		transaction, err := s.transactionRepo.GetByID(*req.TransactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch transaction: %w", err)
+		}
+		if transaction == nil || transaction.ItemID != productID {
+			return nil, fmt.Errorf("%w: transaction %s is not part of this product", ErrInvalidMedia, req.TransactionID)
+		}
+	}
+
+	media, err := s.upload(productID, req.TransactionID, actor.ID, req.Images)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(media); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// Update changes the caption of a gallery image or makes it the cover
+func (s *MediaService) Update(actor Actor, productID, mediaID uuid.UUID, req *models.UpdateMedia) (*models.Media, error) {
+	media, err := s.getEditableMedia(actor, productID, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.IsCover != nil && !*req.IsCover {
+		return nil, fmt.Errorf("%w: make another image the cover instead", ErrInvalidMedia)
+	}
+	if req.Caption != nil {
+		if err := s.mediaRepo.UpdateCaption(media.ID, *req.Caption); err != nil {
+			return nil, fmt.Errorf("failed to update caption: %w", err)
+		}
+		media.Caption = *req.Caption
+	}
+	if req.IsCover != nil {
+		if err := s.mediaRepo.SetCover(productID, media.ID); err != nil {
+			return nil, fmt.Errorf("failed to set cover: %w", err)
+		}
+		media.IsCover = true
+	}
+	if media.URL, err = GetImage(media.ImageKey); err != nil {
+		return nil, fmt.Errorf("failed to retrieve image URL: %v", err)
+	}
+	return media, nil
+}
+
+// Reorder puts a product's gallery in the given order. Every image of the gallery must be listed once.
+func (s *MediaService) Reorder(actor Actor, productID uuid.UUID, mediaIDs []uuid.UUID) ([]models.Media, error) {
+	if _, err := s.getEditableProduct(actor, productID); err != nil {
+		return nil, err
+	}
+
+	current, err := s.mediaRepo.GetByProductID(productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gallery: %w", err)
+	}
+	remaining := make(map[uuid.UUID]bool, len(current))
+	for _, media := range current {
+		remaining[media.ID] = true
+	}
+	for _, id := range mediaIDs {
+		if !remaining[id] {
+			return nil, fmt.Errorf("%w: %s is not in the gallery or is listed twice", ErrInvalidMedia, id)
+		}
+		delete(remaining, id)
+	}
+	if len(remaining) > 0 {
+		return nil, fmt.Errorf("%w: %d images of the gallery are missing from the order", ErrInvalidMedia, len(remaining))
+	}
+
+	if err := s.mediaRepo.Reorder(productID, mediaIDs); err != nil {
+		return nil, fmt.Errorf("failed to reorder gallery: %w", err)
+	}
+	return s.Gallery(productID)
+}
+
+// Delete removes an image from a product's gallery. Images of a transaction document the product's history,
+// so only whoever uploaded them or a moderator may delete those, not a later owner. The S3 object is removed
+// on a best-effort basis.
+func (s *MediaService) Delete(actor Actor, productID, mediaID uuid.UUID) error {
+	media, err := s.getEditableMedia(actor, productID, mediaID)
+	if err != nil {
+		return err
+	}
+	if media.TransactionID != nil {
+		if err := Authorize(actor, PermDeleteTransactionMedia, media.UploadedBy); err != nil {
+			return err
+		}
+	}
+
+	if err := s.mediaRepo.Delete(media); err != nil {
+		return fmt.Errorf("failed to delete image: %w", err)
+	}
+	if err := DeleteImage(media.ImageKey); err != nil {
+		log.Printf("Failed to delete image %s from S3: %v", media.ImageKey, err)
+	}
+	return nil
+}
+
+// getEditableProduct loads a product whose gallery the actor wants to change and checks they may do so
+func (s *MediaService) getEditableProduct(actor Actor, productID uuid.UUID) (*models.Product, error) {
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil || product == nil {
+		return nil, ErrProductNotFound
+	}
+	if err := Authorize(actor, PermEditProduct, product.UserID); err != nil {
+		return nil, err
+	}
+	if product.Status == models.StatusWithdrawn {
+		return nil, fmt.Errorf("%w: product is %s", ErrProductNotEditable, product.Status)
+	}
+	return product, nil
+}
+
+// getEditableMedia loads a gallery image of a product the actor may change
+func (s *MediaService) getEditableMedia(actor Actor, productID, mediaID uuid.UUID) (*models.Media, error) {
+	if _, err := s.getEditableProduct(actor, productID); err != nil {
+		return nil, err
+	}
+	media, err := s.mediaRepo.GetByID(mediaID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image: %w", err)
+	}
+	if media == nil || media.ProductID != productID {
+		return nil, ErrMediaNotFound
+	}
+	return media, nil
+}
+
+// attachImages hands each transaction the gallery images that were uploaded with it
+func attachImages(transactions []models.Transaction, gallery []models.Media) {
+	byTransaction := map[uuid.UUID][]models.Media{}
+	for _, media := range gallery {
+		if media.TransactionID != nil {
+			byTransaction[*media.TransactionID] = append(byTransaction[*media.TransactionID], media)
+		}
+	}
+	for i := range transactions {
+		transactions[i].Images = byTransaction[transactions[i].ID]
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"backend/models"
+	"backend/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// mfaTokenTTL is how long the user has to enter their second factor after the password step
+	mfaTokenTTL = 5 * time.Minute
+	// recoveryCodeCount is how many recovery codes are issued when 2FA is enabled
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication enrollment has not been started")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFACode    = errors.New("invalid two-factor authentication code")
+)
+
+// MFAService handles TOTP two-factor authentication and recovery codes
+type MFAService struct {
+	mfaRepo  *repository.MFARepository
+	userRepo *repository.UserRepository
+}
+
+// NewMFAService creates a new instance of MFAService
+func NewMFAService(mfaRepo *repository.MFARepository, userRepo *repository.UserRepository) *MFAService {
+	return &MFAService{mfaRepo: mfaRepo, userRepo: userRepo}
+}
+
+// IsEnabled reports whether the user has to provide a second factor when logging in
+func (s *MFAService) IsEnabled(userID string) (bool, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return false, err
+	}
+	return mfa != nil && mfa.Enabled, nil
+}
+
+// Enroll generates a new pending TOTP secret. 2FA is only switched on once Confirm succeeds.
+func (s *MFAService) Enroll(userID string) (*models.MFAEnrollment, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	existing, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	mfa := &models.UserMFA{
+		UserID:    user.ID,
+		Secret:    secret,
+		Enabled:   false,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.mfaRepo.Save(mfa); err != nil {
+		return nil, fmt.Errorf("failed to save secret: %w", err)
+	}
+
+	return &models.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: TOTPProvisioningURI(mfaIssuer(), user.Email, secret),
+	}, nil
+}
+
+// Confirm verifies a code from the newly enrolled app, enables 2FA and returns fresh recovery codes.
+// The plain recovery codes are only ever shown here; only their hashes are stored.
+func (s *MFAService) Confirm(userID, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if mfa.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := VerifyTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	plainCodes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		plain, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		plainCodes = append(plainCodes, plain)
+		records = append(records, models.RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    mfa.UserID,
+			CodeHash:  hashRecoveryCode(plain),
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+
+	if err := s.mfaRepo.Enable(userID, step, records); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	return plainCodes, nil
+}
+
+// Disable switches off 2FA after checking a current TOTP or recovery code
+func (s *MFAService) Disable(userID, code, recoveryCode string) error {
+	if err := s.Verify(userID, code, recoveryCode); err != nil {
+		return err
+	}
+	return s.mfaRepo.Delete(userID)
+}
+
+// Verify checks the second factor of a user: a TOTP code, or else an unused recovery code.
+// Accepted TOTP steps and recovery codes cannot be used again.
+func (s *MFAService) Verify(userID, code, recoveryCode string) error {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return ErrMFANotEnabled
+	}
+
+	if code != "" {
+		if step, ok := VerifyTOTP(mfa.Secret, code, time.Now()); ok {
+			fresh, err := s.mfaRepo.MarkStepUsed(userID, step)
+			if err != nil {
+				return err
+			}
+			if fresh {
+				return nil
+			}
+		}
+	}
+
+	if recoveryCode != "" {
+		used, err := s.mfaRepo.UseRecoveryCode(userID, hashRecoveryCode(recoveryCode))
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	}
+
+	return ErrInvalidMFACode
+}
+
+// beginLogin finishes the first login step: users with 2FA get a short-lived "mfa" token to exchange
+// for a session in the second step, everyone else gets a session right away
+func beginLogin(mfaService *MFAService, sessionService *SessionService, user *models.User, client models.ClientInfo) (*models.LoginResult, error) {
+	enabled, err := mfaService.IsEnabled(user.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to check two-factor authentication: %w", err)
+	}
+
+	if enabled {
+		mfaToken, err := GenerateJWT(user.ID.String(), "mfa", mfaTokenTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate JWT: %w", err)
+		}
+		return &models.LoginResult{
+			UserID:       user.ID.String(),
+			MFARequired:  true,
+			MFAToken:     mfaToken,
+			MFAExpiresAt: time.Now().Add(mfaTokenTTL),
+		}, nil
+	}
+
+	tokens, err := sessionService.Issue(user, client)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoginResult{UserID: user.ID.String(), Tokens: tokens}, nil
+}
+
+// mfaIssuer is the account issuer shown in authenticator apps
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Renova"
+}
+
+// generateRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789" // No look-alike characters
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i := range buf {
+		buf[i] = alphabet[int(buf[i])%len(alphabet)]
+	}
+	return string(buf[:5]) + "-" + string(buf[5:]), nil
+}
+
+// hashRecoveryCode normalizes and hashes a recovery code for storage and lookup
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
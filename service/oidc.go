@@ -0,0 +1,445 @@
+package service
+
+import (
+	"backend/models"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// OIDCProvider is an external identity provider users can log in with (authorization code flow with PKCE)
+type OIDCProvider interface {
+	// Name is the identifier used in routes and stored on linked identities, e.g. "google"
+	Name() string
+	// AuthCodeURL builds the URL the user is sent to in order to log in at the provider
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems the authorization code and returns the verified identity of the user
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*models.ExternalIdentity, error)
+}
+
+// OIDCProviderConfig configures a generic OpenID Connect provider. When IssuerURL is set the endpoints
+// are discovered from it; the explicit endpoint fields override discovery, which also allows plain
+// OAuth2 providers that only offer a userinfo endpoint (GitHub-style).
+type OIDCProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	JWKSURL      string
+}
+
+// oidcDiscovery is the subset of the provider's discovery document we use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jsonWebKey is a single RSA key of a provider's JWKS document
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// oidcProvider is the generic OIDCProvider implementation
+type oidcProvider struct {
+	config     OIDCProviderConfig
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+// NewOIDCProvider creates a generic OpenID Connect provider from its configuration
+func NewOIDCProvider(config OIDCProviderConfig, httpClient *http.Client) OIDCProvider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &oidcProvider{config: config, httpClient: httpClient}
+}
+
+// LoadOIDCProvidersFromEnv builds the providers listed in OIDC_PROVIDERS (comma separated names).
+// Each provider NAME is configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL
+// and optionally _SCOPES, _AUTH_URL, _TOKEN_URL, _USERINFO_URL and _JWKS_URL.
+func LoadOIDCProvidersFromEnv() map[string]OIDCProvider {
+	providers := make(map[string]OIDCProvider)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		config := OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			AuthURL:      os.Getenv(prefix + "AUTH_URL"),
+			TokenURL:     os.Getenv(prefix + "TOKEN_URL"),
+			UserInfoURL:  os.Getenv(prefix + "USERINFO_URL"),
+			JWKSURL:      os.Getenv(prefix + "JWKS_URL"),
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			config.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+
+		providers[name] = NewOIDCProvider(config, nil)
+	}
+	return providers
+}
+
+// Name returns the configured provider name
+func (p *oidcProvider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL builds the authorization request URL, including the PKCE challenge and nonce
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	endpoints, err := p.endpoints(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return endpoints.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems the code at the token endpoint and extracts the identity from the ID token,
+// falling back to the userinfo endpoint for providers that do not issue one
+func (p *oidcProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*models.ExternalIdentity, error) {
+	endpoints, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.doJSON(req, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if tokenResponse.Error != "" {
+		return nil, fmt.Errorf("token exchange failed: %s", tokenResponse.Error)
+	}
+
+	var claims map[string]interface{}
+	if tokenResponse.IDToken != "" {
+		claims, err = p.verifyIDToken(ctx, tokenResponse.IDToken, nonce)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if endpoints.UserInfoEndpoint == "" || tokenResponse.AccessToken == "" {
+			return nil, errors.New("provider returned neither an ID token nor a usable userinfo endpoint")
+		}
+		claims, err = p.userInfo(ctx, endpoints.UserInfoEndpoint, tokenResponse.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.identityFromClaims(claims)
+}
+
+// verifyIDToken checks the ID token's signature against the provider's JWKS, its issuer, audience and
+// nonce, and that it carries an expiry and issue time. ID tokens are only accepted from providers with a
+// configured issuer, so a key in the JWKS cannot vouch for tokens of another issuer.
+func (p *oidcProvider) verifyIDToken(ctx context.Context, idToken, nonce string) (map[string]interface{}, error) {
+	endpoints, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected ID token signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid ID token")
+	}
+	if endpoints.Issuer == "" {
+		return nil, fmt.Errorf("provider %s has no issuer configured to verify ID tokens against", p.config.Name)
+	}
+	if !claims.VerifyIssuer(endpoints.Issuer, true) {
+		return nil, errors.New("ID token issuer mismatch")
+	}
+	// MapClaims.Valid only checks exp and iat when they are present
+	if _, ok := claims["exp"].(float64); !ok {
+		return nil, errors.New("ID token has no expiry")
+	}
+	if _, ok := claims["iat"].(float64); !ok {
+		return nil, errors.New("ID token has no issue time")
+	}
+	if !audienceContains(claims["aud"], p.config.ClientID) {
+		return nil, errors.New("ID token audience mismatch")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, errors.New("ID token nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+// userInfo fetches the user's claims from the userinfo endpoint
+func (p *oidcProvider) userInfo(ctx context.Context, endpoint, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var claims map[string]interface{}
+	if err := p.doJSON(req, &claims); err != nil {
+		return nil, fmt.Errorf("userinfo request failed: %w", err)
+	}
+	return claims, nil
+}
+
+// identityFromClaims maps standard OIDC claims onto an ExternalIdentity
+func (p *oidcProvider) identityFromClaims(claims map[string]interface{}) (*models.ExternalIdentity, error) {
+	identity := &models.ExternalIdentity{Provider: p.config.Name}
+
+	switch sub := claims["sub"].(type) {
+	case string:
+		identity.Subject = sub
+	default:
+		// Plain OAuth2 userinfo endpoints (e.g. GitHub) use a numeric "id" instead of "sub"
+		if id, ok := claims["id"].(float64); ok {
+			identity.Subject = fmt.Sprintf("%.0f", id)
+		}
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("provider did not return a subject")
+	}
+
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	return identity, nil
+}
+
+// endpoints returns the provider endpoints, fetching the discovery document once
+func (p *oidcProvider) endpoints(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	discovery := &oidcDiscovery{}
+	if p.config.IssuerURL != "" {
+		wellKnown := strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.doJSON(req, discovery); err != nil {
+			return nil, fmt.Errorf("failed to fetch OIDC discovery document: %w", err)
+		}
+
+		// The discovery document must be about the configured issuer, which ID tokens are checked against
+		configured := strings.TrimSuffix(p.config.IssuerURL, "/")
+		if discovery.Issuer == "" {
+			discovery.Issuer = configured
+		} else if strings.TrimSuffix(discovery.Issuer, "/") != configured {
+			return nil, fmt.Errorf("provider %s discovery issuer %q does not match the configured issuer", p.config.Name, discovery.Issuer)
+		}
+	}
+
+	// Explicit configuration wins over discovery
+	if p.config.AuthURL != "" {
+		discovery.AuthorizationEndpoint = p.config.AuthURL
+	}
+	if p.config.TokenURL != "" {
+		discovery.TokenEndpoint = p.config.TokenURL
+	}
+	if p.config.UserInfoURL != "" {
+		discovery.UserInfoEndpoint = p.config.UserInfoURL
+	}
+	if p.config.JWKSURL != "" {
+		discovery.JWKSURI = p.config.JWKSURL
+	}
+
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" {
+		return nil, fmt.Errorf("provider %s is missing its authorization or token endpoint", p.config.Name)
+	}
+
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// publicKey returns the provider's signing key with the given kid, refetching the JWKS once on a miss
+func (p *oidcProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	endpoints, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	// Unknown kid: the provider may have rotated its keys
+	if endpoints.JWKSURI == "" {
+		return nil, errors.New("provider has no JWKS endpoint")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoints.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.doJSON(req, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		publicKey, err := rsaPublicKeyFromJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = publicKey
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no JWKS key found for kid %q", kid)
+	}
+	return key, nil
+}
+
+// doJSON performs the request and decodes a JSON response body into out
+func (p *oidcProvider) doJSON(req *http.Request, out interface{}) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-200 response: %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// rsaPublicKeyFromJWK decodes the modulus and exponent of an RSA JWK
+func rsaPublicKeyFromJWK(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// audienceContains reports whether the "aud" claim (a string or a list) contains clientID
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
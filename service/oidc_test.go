@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	standInClientID     = "econova-test"
+	standInClientSecret = "secret"
+	standInRedirectURL  = "http://localhost/auth/oidc/standin/callback"
+)
+
+// standInOIDC is a local OpenID Connect provider: it serves discovery, the authorization endpoint, the
+// token endpoint with PKCE checks, and its JWKS
+type standInOIDC struct {
+	server *httptest.Server
+	issuer string // Issuer advertised in discovery; the server URL unless a test changes it
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	kid    string
+	codes  map[string]standInAuthorization
+	tamper func(claims jwt.MapClaims) // Changes the claims of the next ID tokens
+}
+
+// standInAuthorization is what the stand-in remembers about an authorization code
+type standInAuthorization struct {
+	challenge string
+	nonce     string
+	redirect  string
+}
+
+func newStandInOIDC(t *testing.T) *standInOIDC {
+	t.Helper()
+	p := &standInOIDC{codes: make(map[string]standInAuthorization)}
+	p.rotateKey(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	p.server = httptest.NewServer(mux)
+	p.issuer = p.server.URL
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// rotateKey replaces the signing key with a new one under a new kid
+func (p *standInOIDC) rotateKey(t *testing.T) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	kid, err := randomURLSafe(8)
+	if err != nil {
+		t.Fatalf("failed to generate kid: %v", err)
+	}
+	p.mu.Lock()
+	p.key, p.kid = key, kid
+	p.mu.Unlock()
+}
+
+func (p *standInOIDC) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 p.issuer,
+		"authorization_endpoint": p.server.URL + "/authorize",
+		"token_endpoint":         p.server.URL + "/token",
+		"jwks_uri":               p.server.URL + "/jwks",
+	})
+}
+
+// authorize plays the user logging in: it remembers the PKCE challenge and nonce and redirects back
+// with a code
+func (p *standInOIDC) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != standInClientID || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code, _ := randomURLSafe(16)
+	p.mu.Lock()
+	p.codes[code] = standInAuthorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), redirect: q.Get("redirect_uri")}
+	p.mu.Unlock()
+
+	callback := q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, callback, http.StatusFound)
+}
+
+// token redeems a code once, checking the client, the redirect URI and the PKCE verifier
+func (p *standInOIDC) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("client_id") != standInClientID || r.PostForm.Get("client_secret") != standInClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	key, kid, tamper := p.key, p.kid, p.tamper
+	p.mu.Unlock()
+	if !ok || auth.redirect != r.PostForm.Get("redirect_uri") || pkceChallenge(r.PostForm.Get("code_verifier")) != auth.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.issuer,
+		"aud":            standInClientID,
+		"sub":            "standin-user-1",
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"name":           "Ada",
+		"nonce":          auth.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+	if tamper != nil {
+		tamper(claims)
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = kid
+	signed, err := idToken.SignedString(key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": "access", "id_token": signed, "token_type": "Bearer"})
+}
+
+func (p *standInOIDC) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	key, kid := p.key, p.kid
+	p.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+		"kid": kid,
+		"kty": "RSA",
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// provider returns an OIDCProvider configured for the stand-in through discovery
+func (p *standInOIDC) provider() OIDCProvider {
+	return NewOIDCProvider(OIDCProviderConfig{
+		Name:         "standin",
+		IssuerURL:    p.server.URL,
+		ClientID:     standInClientID,
+		ClientSecret: standInClientSecret,
+		RedirectURL:  standInRedirectURL,
+	}, p.server.Client())
+}
+
+// login runs the browser part of the flow the way SocialLoginService.Start does: it builds the
+// authorization URL with a fresh state, nonce and PKCE challenge, follows it and returns the code from
+// the callback together with the verifier and nonce to redeem it with
+func login(t *testing.T, provider OIDCProvider) (code, verifier, nonce string) {
+	t.Helper()
+	ctx := context.Background()
+	state, _ := randomURLSafe(32)
+	nonce, _ = randomURLSafe(32)
+	verifier, _ = randomURLSafe(64)
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, pkceChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	params, _ := url.Parse(authURL)
+	if params.Query().Get("code_challenge") == verifier {
+		t.Fatal("the PKCE verifier must not be sent to the provider")
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorization request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorization request: got status %d", resp.StatusCode)
+	}
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("callback URL: %v", err)
+	}
+	if !strings.HasPrefix(callback.String(), standInRedirectURL) {
+		t.Fatalf("redirected to %s instead of the callback", callback)
+	}
+	if callback.Query().Get("state") != state {
+		t.Fatalf("callback state %q does not match %q", callback.Query().Get("state"), state)
+	}
+	return callback.Query().Get("code"), verifier, nonce
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	standIn := newStandInOIDC(t)
+	provider := standIn.provider()
+
+	code, verifier, nonce := login(t, provider)
+	identity, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Provider != "standin" || identity.Subject != "standin-user-1" || identity.Email != "ada@example.com" || !identity.EmailVerified {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	// Codes are single use
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil {
+		t.Fatal("expected a replayed code to be rejected")
+	}
+}
+
+func TestOIDCRejectsWrongVerifier(t *testing.T) {
+	standIn := newStandInOIDC(t)
+	provider := standIn.provider()
+
+	code, _, nonce := login(t, provider)
+	other, _ := randomURLSafe(64)
+	if _, err := provider.Exchange(context.Background(), code, other, nonce); err == nil {
+		t.Fatal("expected the exchange with another PKCE verifier to fail")
+	}
+}
+
+func TestOIDCRejectsNonceMismatch(t *testing.T) {
+	standIn := newStandInOIDC(t)
+	provider := standIn.provider()
+
+	code, verifier, _ := login(t, provider)
+	if _, err := provider.Exchange(context.Background(), code, verifier, "another-nonce"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("expected a nonce mismatch, got %v", err)
+	}
+}
+
+func TestOIDCRejectsInvalidIDTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(claims jwt.MapClaims)
+	}{
+		{"other issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"no issuer", func(c jwt.MapClaims) { delete(c, "iss") }},
+		{"other audience", func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{"no expiry", func(c jwt.MapClaims) { delete(c, "exp") }},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{"no issue time", func(c jwt.MapClaims) { delete(c, "iat") }},
+		{"issued in the future", func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{"no nonce", func(c jwt.MapClaims) { delete(c, "nonce") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn := newStandInOIDC(t)
+			standIn.tamper = tt.tamper
+			provider := standIn.provider()
+
+			code, verifier, nonce := login(t, provider)
+			if identity, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil {
+				t.Fatalf("expected the ID token to be rejected, got %+v", identity)
+			}
+		})
+	}
+}
+
+func TestOIDCRequiresIssuerForIDTokens(t *testing.T) {
+	standIn := newStandInOIDC(t)
+	// Endpoints configured by hand, without an issuer: ID tokens cannot be checked against one
+	provider := NewOIDCProvider(OIDCProviderConfig{
+		Name:         "standin",
+		ClientID:     standInClientID,
+		ClientSecret: standInClientSecret,
+		RedirectURL:  standInRedirectURL,
+		AuthURL:      standIn.server.URL + "/authorize",
+		TokenURL:     standIn.server.URL + "/token",
+		JWKSURL:      standIn.server.URL + "/jwks",
+	}, standIn.server.Client())
+
+	code, verifier, nonce := login(t, provider)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil || !strings.Contains(err.Error(), "no issuer") {
+		t.Fatalf("expected the ID token to be rejected for lack of an issuer, got %v", err)
+	}
+}
+
+func TestOIDCRejectsDiscoveryForAnotherIssuer(t *testing.T) {
+	standIn := newStandInOIDC(t)
+	standIn.issuer = "https://evil.example.com"
+
+	if _, err := standIn.provider().AuthCodeURL(context.Background(), "state", "nonce", "challenge"); err == nil {
+		t.Fatal("expected a discovery document for another issuer to be rejected")
+	}
+}
+
+func TestOIDCRefetchesRotatedKeys(t *testing.T) {
+	standIn := newStandInOIDC(t)
+	provider := standIn.provider()
+
+	code, verifier, nonce := login(t, provider)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	// The provider caches the JWKS; a token signed with a new key must make it fetch the keys again
+	standIn.rotateKey(t)
+	code, verifier, nonce = login(t, provider)
+	if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("Exchange after key rotation: %v", err)
+	}
+}
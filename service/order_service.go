@@ -0,0 +1,506 @@
+package service
+
+import (
+	"backend/models"
+	"backend/pagination"
+	"backend/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultReservationTTL is how long an unpaid order holds its product: the seller has that long to
+	// accept it, and once accepted the buyer has that long again to pay
+	defaultReservationTTL = 48 * time.Hour
+	// expiryBatchSize limits how many orders one run of the expiry job handles
+	expiryBatchSize = 100
+)
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrInvalidOrder  = errors.New("invalid order")
+)
+
+// orderStep describes a step on an order: the statuses it may be taken from, the status it leads to
+// and who may take it
+type orderStep struct {
+	from        []models.OrderStatus
+	to          models.OrderStatus
+	perm        Permission           // PermBuyOrder and PermSellOrder are checked against that party, others against both
+	partiesFrom []models.OrderStatus // When set, buyer and seller may only take the step from these statuses; moderators from all
+}
+
+// orderSteps is the single source of truth for how orders move between statuses
+var orderSteps = map[models.OrderAction]orderStep{
+	models.OrderActionAccept: {
+		from: []models.OrderStatus{models.OrderPending},
+		to:   models.OrderAccepted,
+		perm: PermSellOrder,
+	},
+	models.OrderActionDecline: {
+		from: []models.OrderStatus{models.OrderPending},
+		to:   models.OrderDeclined,
+		perm: PermSellOrder,
+	},
+	models.OrderActionPay: {
+		from: []models.OrderStatus{models.OrderAccepted},
+		to:   models.OrderPaid,
+		perm: PermBuyOrder,
+	},
+	models.OrderActionHandOver: {
+		from: []models.OrderStatus{models.OrderPaid},
+		to:   models.OrderHandedOver,
+		perm: PermSellOrder,
+	},
+	models.OrderActionComplete: {
+		from: []models.OrderStatus{models.OrderHandedOver},
+		to:   models.OrderCompleted,
+		perm: PermBuyOrder,
+	},
+	models.OrderActionCancel: {
+		from:        models.ActiveOrderStatuses,
+		to:          models.OrderCancelled,
+		perm:        PermCancelOrder,
+		partiesFrom: []models.OrderStatus{models.OrderPending, models.OrderAccepted}, // Paid orders need a moderator to sort out the money
+	},
+	models.OrderActionExpire: {
+		from: []models.OrderStatus{models.OrderPending, models.OrderAccepted},
+		to:   models.OrderExpired,
+	},
+}
+
+// OrderService handles buyers' orders: placing one reserves the product, the seller accepts or declines,
+// the buyer's payment is held in escrow, and completing the order sells the product to the buyer and
+// releases the money to the seller
+type OrderService struct {
+	orderRepo      *repository.OrderRepository
+	productRepo    *repository.ProductRepository
+	payments       *PaymentService
+	uow            *repository.UnitOfWork
+	reservationTTL time.Duration
+}
+
+// NewOrderService creates a new instance of OrderService. How long unpaid orders hold their product can
+// be set in hours with ORDER_RESERVATION_HOURS.
+func NewOrderService(orderRepo *repository.OrderRepository, productRepo *repository.ProductRepository, payments *PaymentService, uow *repository.UnitOfWork) *OrderService {
+	reservationTTL := defaultReservationTTL
+	if hours, err := strconv.Atoi(os.Getenv("ORDER_RESERVATION_HOURS")); err == nil && hours > 0 {
+		reservationTTL = time.Duration(hours) * time.Hour
+	}
+
+	return &OrderService{orderRepo: orderRepo, productRepo: productRepo, payments: payments, uow: uow, reservationTTL: reservationTTL}
+}
+
+// Place orders a product for the actor and reserves it for them until the seller responds or the
+// reservation runs out. The order keeps the product's current price. A product a workshop holds a
+// revitalization job on cannot be ordered, and nothing can be ordered while payments are disabled.
+func (s *OrderService) Place(actor Actor, req *models.PlaceOrder) (*models.Order, error) {
+	if err := s.payments.Enabled(); err != nil {
+		return nil, err
+	}
+	product, err := s.productRepo.GetByID(req.ProductID)
+	if err != nil || product == nil {
+		return nil, ErrProductNotFound
+	}
+	if product.UserID == actor.ID {
+		return nil, fmt.Errorf("%w: you cannot order your own product", ErrInvalidOrder)
+	}
+
+	// A reservation that ran out but was not released by the job yet must not block the product
+	if product.Status == models.StatusReserved {
+		if product, err = s.releaseIfExpired(product); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := Transition(actor, product, models.Reserved, false); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	reservedUntil := now.Add(s.reservationTTL)
+	order := &models.Order{
+		ID:            uuid.New(),
+		ProductID:     product.ID,
+		BuyerID:       actor.ID,
+		SellerID:      product.UserID,
+		Price:         product.Price,
+		Status:        models.OrderPending,
+		ProductStatus: product.Status,
+		Note:          req.Note,
+		ReservedUntil: &reservedUntil,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	err = s.uow.Do(func(repos *repository.RepositoryFactory) error {
+		reserved, err := repos.GetProductRepository().SetStatusIf(product.ID, product.Status, models.StatusReserved, nil)
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			return fmt.Errorf("%w: the product was reserved or changed in the meantime", ErrInvalidTransition)
+		}
+		if err := checkNoJob(repos, product.ID); err != nil {
+			return err
+		}
+		if err := repos.GetOrderRepository().Create(order); err != nil {
+			return err
+		}
+		entry := newProductEntry(Actor{ID: product.UserID}, product, models.Reserved, "Reserved for an order")
+		return repos.GetTransactionRepository().Create(entry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to place order: %w", err)
+	}
+	return order, nil
+}
+
+// Get retrieves an order for its buyer, its seller or a moderator
+func (s *OrderService) Get(actor Actor, id uuid.UUID) (*models.Order, error) {
+	order, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	if err := authorizeOrder(actor, PermViewOrder, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Payment retrieves an order's payment and its ledger entries for the order's buyer, its seller or
+// a moderator
+func (s *OrderService) Payment(actor Actor, id uuid.UUID) (*models.Payment, []models.LedgerEntry, error) {
+	if _, err := s.Get(actor, id); err != nil {
+		return nil, nil, err
+	}
+	return s.payments.ForOrder(id)
+}
+
+// List retrieves a page of the actor's order history, newest first. Role narrows it down to the orders
+// in which the actor is the "buyer" or the "seller"; status to orders in that status.
+func (s *OrderService) List(actor Actor, role string, status models.OrderStatus, params pagination.Params) ([]models.Order, pagination.Page, error) {
+	if role != "" && role != "buyer" && role != "seller" {
+		return nil, pagination.Page{}, fmt.Errorf("%w: unknown role %q, expected buyer or seller", ErrInvalidOrder, role)
+	}
+	if status != "" && !status.IsValid() {
+		return nil, pagination.Page{}, fmt.Errorf("%w: unknown status %q", ErrInvalidOrder, status)
+	}
+	return s.orderRepo.List(actor.ID, role == "buyer", role == "seller", status, params)
+}
+
+// Step takes a step on an order on behalf of the actor, e.g. the seller accepting it or the buyer
+// confirming they received the product. Cancelling a paid order refunds the buyer. Unpaid orders whose
+// reservation ran out expire instead.
+//
+// A paid order is moved to "cancelling" before the money goes back, so the buyer cannot complete it
+// while the refund is under way and have the payment released as well. If the refund fails, the order
+// stays there until a moderator cancels it again.
+func (s *OrderService) Step(ctx context.Context, actor Actor, id uuid.UUID, action models.OrderAction, reason string) (*models.Order, error) {
+	if action == models.OrderActionPay || action == models.OrderActionExpire {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidOrder, action)
+	}
+	order, err := s.prepare(actor, id, action)
+	if err != nil {
+		return nil, err
+	}
+
+	var refund *models.Payment
+	if action == models.OrderActionCancel && (order.Status == models.OrderPaid || order.Status == models.OrderHandedOver || order.Status == models.OrderCancelling) {
+		if err := s.beginCancel(order, reason); err != nil {
+			return nil, err
+		}
+		if refund, err = s.payments.Refund(ctx, order.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.apply(order, action, reason, func(repos *repository.RepositoryFactory) error {
+		return s.payments.RecordRefund(repos, refund)
+	})
+	if err != nil {
+		if refund != nil {
+			log.Printf("Payment %s of order %s was refunded at the provider but not recorded: %v", refund.ID, order.ID, err)
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+// beginCancel moves a paid order to "cancelling", unless it is there already because an earlier refund
+// failed. It fails when the order moved on in the meantime, e.g. because the buyer completed it.
+func (s *OrderService) beginCancel(order *models.Order, reason string) error {
+	if order.Status == models.OrderCancelling {
+		return nil
+	}
+	moved, err := s.orderRepo.UpdateStatus(order.ID, order.Status, models.OrderCancelling, map[string]interface{}{"reason": reason})
+	if err != nil {
+		return fmt.Errorf("failed to cancel order: %w", err)
+	}
+	if !moved {
+		return fmt.Errorf("%w: the order was changed in the meantime", ErrInvalidTransition)
+	}
+	order.Status = models.OrderCancelling
+	order.Reason = reason
+	return nil
+}
+
+// Pay charges the buyer for an accepted order with the payment source from the provider's client SDK.
+// The money is held in escrow until the buyer confirms receipt.
+func (s *OrderService) Pay(ctx context.Context, actor Actor, id uuid.UUID, source string) (*models.Order, error) {
+	order, err := s.prepare(actor, id, models.OrderActionPay)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := s.payments.Charge(ctx, order, source)
+	if err != nil {
+		return nil, err
+	}
+	err = s.apply(order, models.OrderActionPay, "", func(repos *repository.RepositoryFactory) error {
+		return s.payments.Hold(repos, payment)
+	})
+	if err != nil {
+		s.payments.Void(ctx, payment)
+		return nil, err
+	}
+	return order, nil
+}
+
+// prepare loads an order and checks that the actor may take action on it in its current status. An
+// unpaid order whose reservation ran out is expired on the way and reported as such.
+func (s *OrderService) prepare(actor Actor, id uuid.UUID, action models.OrderAction) (*models.Order, error) {
+	step := orderSteps[action]
+	order, err := s.orderRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	if err := authorizeOrder(actor, step.perm, order); err != nil {
+		return nil, err
+	}
+	if !containsOrderStatus(step.from, order.Status) {
+		return nil, fmt.Errorf("%w: cannot %s an order that is %s", ErrInvalidTransition, action, order.Status)
+	}
+	if step.partiesFrom != nil && !containsOrderStatus(step.partiesFrom, order.Status) && !isModerator(actor) {
+		return nil, fmt.Errorf("%w: an order that is %s can only be called off by a moderator", ErrForbidden, order.Status)
+	}
+
+	if expired(order) {
+		if err := s.apply(order, models.OrderActionExpire, "Reservation expired", nil); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: the reservation ran out and the order expired", ErrInvalidTransition)
+	}
+	return order, nil
+}
+
+// ExpireDue expires unpaid orders whose reservation ran out and releases their products
+func (s *OrderService) ExpireDue() (int, error) {
+	orders, err := s.orderRepo.ListExpired(time.Now().UTC(), expiryBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list expired orders: %w", err)
+	}
+
+	expiredCount := 0
+	for i := range orders {
+		if err := s.apply(&orders[i], models.OrderActionExpire, "Reservation expired", nil); err != nil {
+			log.Printf("Failed to expire order %s: %v", orders[i].ID, err)
+			continue
+		}
+		expiredCount++
+	}
+	return expiredCount, nil
+}
+
+// RunExpiryJob calls ExpireDue every interval. It blocks, so start it in its own goroutine.
+func (s *OrderService) RunExpiryJob(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expiredCount, err := s.ExpireDue()
+		if err != nil {
+			log.Printf("Order expiry job: %v", err)
+			continue
+		}
+		if expiredCount > 0 {
+			log.Printf("Order expiry job: expired %d orders", expiredCount)
+		}
+	}
+}
+
+// apply moves an order along a step and makes the matching changes to its product and payment, in one
+// database transaction: orders that end without a sale release the product, completed ones sell it to
+// the buyer and release the payment to the seller. within, when set, runs in the same transaction.
+// The order is updated in place.
+func (s *OrderService) apply(order *models.Order, action models.OrderAction, reason string, within func(repos *repository.RepositoryFactory) error) error {
+	step := orderSteps[action]
+	now := time.Now().UTC()
+
+	changes := map[string]interface{}{}
+	switch step.to {
+	case models.OrderAccepted:
+		reservedUntil := now.Add(s.reservationTTL)
+		changes["reserved_until"] = &reservedUntil
+	case models.OrderPaid:
+		changes["reserved_until"] = nil
+	case models.OrderCompleted:
+		changes["completed_at"] = &now
+	case models.OrderDeclined, models.OrderCancelled, models.OrderExpired:
+		changes["reason"] = reason
+	}
+
+	err := s.uow.Do(func(repos *repository.RepositoryFactory) error {
+		moved, err := repos.GetOrderRepository().UpdateStatus(order.ID, order.Status, step.to, changes)
+		if err != nil {
+			return err
+		}
+		if !moved {
+			return fmt.Errorf("%w: the order was changed in the meantime", ErrInvalidTransition)
+		}
+
+		if within != nil {
+			if err := within(repos); err != nil {
+				return err
+			}
+		}
+
+		switch step.to {
+		case models.OrderCompleted:
+			if err := sell(repos, order); err != nil {
+				return err
+			}
+			return s.payments.Release(repos, order.ID)
+		case models.OrderDeclined, models.OrderCancelled, models.OrderExpired:
+			return release(repos, order, fmt.Sprintf("Reservation released: order %s", step.to))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to %s order: %w", action, err)
+	}
+
+	order.Status = step.to
+	order.UpdatedAt = now
+	switch step.to {
+	case models.OrderAccepted:
+		reservedUntil := now.Add(s.reservationTTL)
+		order.ReservedUntil = &reservedUntil
+	case models.OrderPaid:
+		order.ReservedUntil = nil
+	case models.OrderCompleted:
+		order.CompletedAt = &now
+	case models.OrderDeclined, models.OrderCancelled, models.OrderExpired:
+		order.Reason = reason
+	}
+	return nil
+}
+
+// releaseIfExpired expires the order holding a product when its reservation ran out, and returns the
+// product as it is afterwards
+func (s *OrderService) releaseIfExpired(product *models.Product) (*models.Product, error) {
+	order, err := s.orderRepo.GetActiveByProductID(product.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
+	}
+	if order == nil || !expired(order) {
+		return product, nil
+	}
+	if err := s.apply(order, models.OrderActionExpire, "Reservation expired", nil); err != nil {
+		return nil, err
+	}
+
+	product, err = s.productRepo.GetByID(product.ID)
+	if err != nil || product == nil {
+		return nil, ErrProductNotFound
+	}
+	return product, nil
+}
+
+// sell records the sale of an order's product and makes the buyer its owner
+func sell(repos *repository.RepositoryFactory, order *models.Order) error {
+	product, err := repos.GetProductRepository().GetByID(order.ProductID)
+	if err != nil || product == nil {
+		return ErrProductNotFound
+	}
+	status, err := Transition(Actor{ID: order.SellerID}, product, models.Sold, false)
+	if err != nil {
+		return err
+	}
+
+	sold, err := repos.GetProductRepository().SetStatusIf(product.ID, product.Status, status, map[string]interface{}{"user_id": order.BuyerID})
+	if err != nil {
+		return err
+	}
+	if !sold {
+		return fmt.Errorf("%w: the product was changed in the meantime", ErrInvalidTransition)
+	}
+	entry := newProductEntry(Actor{ID: order.SellerID}, product, models.Sold, fmt.Sprintf("Sold for %.2f", order.Price))
+	return repos.GetTransactionRepository().Create(entry)
+}
+
+// release gives a reserved product back the status it had before the order. A product a moderator
+// has moved out of the reservation in the meantime is left alone.
+func release(repos *repository.RepositoryFactory, order *models.Order, description string) error {
+	product, err := repos.GetProductRepository().GetByID(order.ProductID)
+	if err != nil || product == nil {
+		return ErrProductNotFound
+	}
+	if product.Status != models.StatusReserved {
+		return nil
+	}
+	if _, err := Transition(Actor{ID: order.SellerID}, product, models.Released, false); err != nil {
+		return err
+	}
+
+	if _, err := repos.GetProductRepository().SetStatusIf(product.ID, models.StatusReserved, order.ProductStatus, nil); err != nil {
+		return err
+	}
+	entry := newProductEntry(Actor{ID: product.UserID}, product, models.Released, description)
+	return repos.GetTransactionRepository().Create(entry)
+}
+
+// authorizeOrder checks that the actor has the part in the order the permission asks for
+func authorizeOrder(actor Actor, perm Permission, order *models.Order) error {
+	switch perm {
+	case PermBuyOrder:
+		return Authorize(actor, perm, order.BuyerID)
+	case PermSellOrder:
+		return Authorize(actor, perm, order.SellerID)
+	}
+	if err := Authorize(actor, perm, order.BuyerID); err == nil {
+		return nil
+	}
+	return Authorize(actor, perm, order.SellerID)
+}
+
+// expired reports whether an unpaid order's reservation ran out
+func expired(order *models.Order) bool {
+	return order.ReservedUntil != nil && !order.ReservedUntil.After(time.Now().UTC()) &&
+		containsOrderStatus(orderSteps[models.OrderActionExpire].from, order.Status)
+}
+
+func isModerator(actor Actor) bool {
+	return actor.Role == models.RoleModerator || actor.Role == models.RoleAdmin
+}
+
+func containsOrderStatus(statuses []models.OrderStatus, status models.OrderStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
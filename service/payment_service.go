@@ -0,0 +1,299 @@
+package service
+
+import (
+	"backend/models"
+	"backend/payments"
+	"backend/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultPlatformFee is the platform's share of every sale in basis points (1/100 of a percent)
+	defaultPlatformFee = 500
+	// defaultCurrency is the currency product prices are in
+	defaultCurrency = "EUR"
+)
+
+var (
+	ErrPaymentDeclined = errors.New("payment declined")
+	ErrPaymentNotFound = errors.New("payment not found")
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+	// ErrPaymentsDisabled is returned for anything that needs the payment provider when none is set up
+	ErrPaymentsDisabled = errors.New("payments are disabled")
+)
+
+// PaymentService moves the money of orders through the payment provider. A buyer's payment is held
+// in escrow until they confirm receipt and is then released to the seller minus the platform fee, or
+// refunded when the order is called off. Every movement is posted to the double-entry ledger.
+type PaymentService struct {
+	provider    payments.Provider // Nil when payments are disabled
+	paymentRepo *repository.PaymentRepository
+	ledgerRepo  *repository.LedgerRepository
+	uow         *repository.UnitOfWork
+	feeRate     int64
+	currency    string
+}
+
+// NewPaymentService creates a new instance of PaymentService. Without a provider payments are disabled.
+// The platform fee can be set in basis points with PLATFORM_FEE_BASIS_POINTS and the currency with
+// PAYMENT_CURRENCY.
+func NewPaymentService(provider payments.Provider, paymentRepo *repository.PaymentRepository, ledgerRepo *repository.LedgerRepository, uow *repository.UnitOfWork) *PaymentService {
+	feeRate := int64(defaultPlatformFee)
+	if rate, err := strconv.Atoi(os.Getenv("PLATFORM_FEE_BASIS_POINTS")); err == nil && rate >= 0 && rate <= 10000 {
+		feeRate = int64(rate)
+	}
+	currency := defaultCurrency
+	if code := strings.TrimSpace(os.Getenv("PAYMENT_CURRENCY")); code != "" {
+		currency = strings.ToUpper(code)
+	}
+
+	return &PaymentService{
+		provider:    provider,
+		paymentRepo: paymentRepo,
+		ledgerRepo:  ledgerRepo,
+		uow:         uow,
+		feeRate:     feeRate,
+		currency:    currency,
+	}
+}
+
+// Enabled fails with ErrPaymentsDisabled when no payment provider is set up
+func (s *PaymentService) Enabled() error {
+	if s.provider == nil {
+		return ErrPaymentsDisabled
+	}
+	return nil
+}
+
+// Charge authorizes an order's price on the buyer's payment source and captures it. The returned payment
+// is not saved yet: Hold records it together with the order's status. Free products need no payment and
+// return nil.
+func (s *PaymentService) Charge(ctx context.Context, order *models.Order, source string) (*models.Payment, error) {
+	amount := payments.FromFloat(order.Price)
+	if amount <= 0 {
+		return nil, nil
+	}
+	if err := s.Enabled(); err != nil {
+		return nil, err
+	}
+
+	authorization, err := s.provider.Authorize(ctx, payments.AuthorizeRequest{
+		Amount:    amount,
+		Currency:  s.currency,
+		Source:    source,
+		Reference: order.ID.String(),
+	})
+	if err != nil {
+		if errors.Is(err, payments.ErrDeclined) {
+			return nil, fmt.Errorf("%w: %v", ErrPaymentDeclined, err)
+		}
+		return nil, fmt.Errorf("failed to authorize payment: %w", err)
+	}
+	capture, err := s.provider.Capture(ctx, authorization.ID, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture payment: %w", err)
+	}
+
+	now := time.Now().UTC()
+	return &models.Payment{
+		ID:              uuid.New(),
+		OrderID:         order.ID,
+		BuyerID:         order.BuyerID,
+		SellerID:        order.SellerID,
+		Provider:        s.provider.Name(),
+		AuthorizationID: authorization.ID,
+		CaptureID:       capture.ID,
+		Amount:          int64(capture.Amount),
+		Fee:             int64(payments.Fee(capture.Amount, s.feeRate)),
+		Currency:        s.currency,
+		Status:          models.PaymentHeld,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}, nil
+}
+
+// Hold saves a captured payment and moves its money into escrow
+func (s *PaymentService) Hold(repos *repository.RepositoryFactory, payment *models.Payment) error {
+	if payment == nil {
+		return nil
+	}
+	if err := repos.GetPaymentRepository().Create(payment); err != nil {
+		return err
+	}
+
+	memo := fmt.Sprintf("Payment for order %s held in escrow", payment.OrderID)
+	return repos.GetLedgerRepository().Post(payment.ID, []models.LedgerEntry{
+		{Account: models.AccountProvider, Debit: payment.Amount, Memo: memo},
+		{Account: models.AccountEscrow, Credit: payment.Amount, Memo: memo},
+	})
+}
+
+// Void gives back the money of a payment that was captured but could not be saved, e.g. because the
+// order changed in the meantime. Failures are logged for a moderator to settle by hand.
+func (s *PaymentService) Void(ctx context.Context, payment *models.Payment) {
+	if payment == nil || s.provider == nil {
+		return
+	}
+	if _, err := s.provider.Refund(ctx, payment.CaptureID, payments.Amount(payment.Amount)); err != nil {
+		log.Printf("Failed to void payment %s of order %s: %v", payment.CaptureID, payment.OrderID, err)
+	}
+}
+
+// Release pays an order's escrowed money out to the seller, keeping the platform fee
+func (s *PaymentService) Release(repos *repository.RepositoryFactory, orderID uuid.UUID) error {
+	payment, err := repos.GetPaymentRepository().GetByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+	if payment == nil {
+		return nil // Free products, and orders paid before payments went through the platform
+	}
+
+	released, err := repos.GetPaymentRepository().UpdateStatus(payment.ID, models.PaymentHeld, models.PaymentReleased, nil)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return fmt.Errorf("payment %s is no longer held in escrow", payment.ID)
+	}
+
+	memo := fmt.Sprintf("Payment for order %s released to the seller", orderID)
+	entries := []models.LedgerEntry{{Account: models.AccountEscrow, Debit: payment.Amount, Memo: memo}}
+	if payout := payment.Amount - payment.Fee; payout > 0 {
+		entries = append(entries, models.LedgerEntry{Account: models.AccountSellerPayable, UserID: &payment.SellerID, Credit: payout, Memo: memo})
+	}
+	if payment.Fee > 0 {
+		entries = append(entries, models.LedgerEntry{Account: models.AccountPlatformFees, Credit: payment.Fee, Memo: memo})
+	}
+	return repos.GetLedgerRepository().Post(payment.ID, entries)
+}
+
+// Refund pays an order's escrowed money back to the buyer at the provider. The returned payment carries
+// the refund; RecordRefund saves it together with the order's status. Orders without a payment, or whose
+// payment was refunded already, return nil.
+func (s *PaymentService) Refund(ctx context.Context, orderID uuid.UUID) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetByOrderID(orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch payment: %w", err)
+	}
+	if payment == nil {
+		return nil, nil
+	}
+	if payment.Status == models.PaymentRefunded {
+		// Refunded at the provider already, e.g. from its dashboard and recorded by the webhook
+		return nil, nil
+	}
+	if payment.Status != models.PaymentHeld {
+		return nil, fmt.Errorf("%w: the payment was already %s", ErrInvalidTransition, payment.Status)
+	}
+	if err := s.Enabled(); err != nil {
+		return nil, err
+	}
+
+	refund, err := s.provider.Refund(ctx, payment.CaptureID, payments.Amount(payment.Amount))
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund payment: %w", err)
+	}
+	payment.RefundID = refund.ID
+	return payment, nil
+}
+
+// RecordRefund marks a payment as refunded and moves its money out of escrow
+func (s *PaymentService) RecordRefund(repos *repository.RepositoryFactory, payment *models.Payment) error {
+	if payment == nil {
+		return nil
+	}
+
+	refunded, err := repos.GetPaymentRepository().UpdateStatus(payment.ID, models.PaymentHeld, models.PaymentRefunded, map[string]interface{}{"refund_id": payment.RefundID})
+	if err != nil {
+		return err
+	}
+	if !refunded {
+		return fmt.Errorf("payment %s is no longer held in escrow", payment.ID)
+	}
+
+	memo := fmt.Sprintf("Payment for order %s refunded to the buyer", payment.OrderID)
+	return repos.GetLedgerRepository().Post(payment.ID, []models.LedgerEntry{
+		{Account: models.AccountEscrow, Debit: payment.Amount, Memo: memo},
+		{Account: models.AccountProvider, Credit: payment.Amount, Memo: memo},
+	})
+}
+
+// ForOrder retrieves an order's payment with the ledger entries of its movements
+func (s *PaymentService) ForOrder(orderID uuid.UUID) (*models.Payment, []models.LedgerEntry, error) {
+	payment, err := s.paymentRepo.GetByOrderID(orderID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch payment: %w", err)
+	}
+	if payment == nil {
+		return nil, nil, ErrPaymentNotFound
+	}
+
+	entries, err := s.ledgerRepo.ListByPaymentID(payment.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch ledger entries: %w", err)
+	}
+	return payment, entries, nil
+}
+
+// Balance returns what the platform owes a seller from released payments, in minor units, and the
+// currency it is in
+func (s *PaymentService) Balance(sellerID uuid.UUID) (int64, string, error) {
+	balance, err := s.ledgerRepo.Balance(models.AccountSellerPayable, &sellerID)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to fetch balance: %w", err)
+	}
+	return balance, s.currency, nil
+}
+
+// HandleWebhook processes a notification from the payment provider. Refunds made at the provider
+// are recorded in the ledger; the order they belong to is left for a moderator to settle. Events
+// about payments we do not know are ignored.
+func (s *PaymentService) HandleWebhook(payload []byte, signature string) error {
+	if err := s.Enabled(); err != nil {
+		return err
+	}
+	event, err := s.provider.VerifyWebhook(payload, signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+
+	payment, err := s.paymentRepo.GetByCaptureID(event.PaymentID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch payment: %w", err)
+	}
+	if payment == nil {
+		log.Printf("Payment webhook %s: unknown payment %s", event.ID, event.PaymentID)
+		return nil
+	}
+
+	switch event.Type {
+	case payments.EventRefunded:
+		if payment.Status != models.PaymentHeld {
+			return nil // Refunded by us, or already recorded
+		}
+		if event.Amount != payments.Amount(payment.Amount) {
+			log.Printf("Payment webhook %s: partial refund of %d on payment %s needs to be settled by hand", event.ID, event.Amount, payment.ID)
+			return nil
+		}
+		payment.RefundID = event.ID
+		if err := s.uow.Do(func(repos *repository.RepositoryFactory) error {
+			return s.RecordRefund(repos, payment)
+		}); err != nil {
+			return fmt.Errorf("failed to record refund: %w", err)
+		}
+		log.Printf("Payment webhook %s: payment %s was refunded at the provider, order %s needs a moderator", event.ID, payment.ID, payment.OrderID)
+	case payments.EventFailed:
+		log.Printf("Payment webhook %s: payment %s of order %s failed at the provider", event.ID, payment.ID, payment.OrderID)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"backend/models"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Actor is the authenticated user a service call is made on behalf of
+type Actor struct {
+	ID   uuid.UUID
+	Role models.Role
+}
+
+// Permission names an action that is subject to an ownership policy
+type Permission string
+
+const (
+	PermDeleteComment          Permission = "comment:delete"
+	PermDeleteRating           Permission = "rating:delete"
+	PermAddTransaction         Permission = "product:add_transaction"
+	PermManageAPIKey           Permission = "api_key:manage"
+	PermEditProduct            Permission = "product:edit"
+	PermDeleteTransactionMedia Permission = "media:delete_transaction_image"
+	PermSellOrder              Permission = "order:sell"
+	PermBuyOrder               Permission = "order:buy"
+	PermCancelOrder            Permission = "order:cancel"
+	PermViewOrder              Permission = "order:view"
+	PermAmendTransaction       Permission = "transaction:amend"
+	PermOverrideStatus         Permission = "product:override_status"
+	PermManageRevitalization   Permission = "revitalization:manage"
+	PermWorkRevitalization     Permission = "revitalization:work"
+)
+
+// policy describes who may perform an action on a resource: its owner, and/or anyone holding one of the roles
+type policy struct {
+	owner       bool
+	roles       []models.Role
+	description string // Explains the rule in the 403 response
+}
+
+// policies is the single source of truth for resource ownership rules
+var policies = map[Permission]policy{
+	PermDeleteComment: {
+		owner:       true,
+		roles:       []models.Role{models.RoleModerator, models.RoleAdmin},
+		description: "only the comment author or a moderator may delete this comment",
+	},
+	PermDeleteRating: {
+		owner:       true,
+		roles:       []models.Role{models.RoleModerator, models.RoleAdmin},
+		description: "only the rating author or a moderator may delete this rating",
+	},
+	PermAddTransaction: {
+		owner:       true,
+		description: "only the current owner may add transactions to this product",
+	},
+	PermEditProduct: {
+		owner:       true,
+		roles:       []models.Role{models.RoleModerator, models.RoleAdmin},
+		description: "only the owner or a moderator may change this product",
+	},
+	PermDeleteTransactionMedia: {
+		owner:       true,
+		roles:       []models.Role{models.RoleModerator, models.RoleAdmin},
+		description: "only whoever uploaded an image of a transaction or a moderator may delete it",
+	},
+	PermSellOrder: {
+		owner:       true,
+		description: "only the seller may take this step",
+	},
+	PermBuyOrder: {
+		owner:       true,
+		description: "only the buyer may take this step",
+	},
+	PermCancelOrder: {
+		owner:       true,
+		roles:       []models.Role{models.RoleModerator, models.RoleAdmin},
+		description: "only the buyer, the seller or a moderator may cancel this order",
+	},
+	PermViewOrder: {
+		owner:       true,
+		roles:       []models.Role{models.RoleModerator, models.RoleAdmin},
+		description: "only the buyer, the seller or a moderator may see this order",
+	},
+	PermAmendTransaction: {
+		owner:       true,
+		roles:       []models.Role{models.RoleModerator, models.RoleAdmin},
+		description: "only the author of the transaction or a moderator may correct it",
+	},
+	PermManageRevitalization: {
+		owner:       true,
+		roles:       []models.Role{models.RoleModerator, models.RoleAdmin},
+		description: "only the owner of the product or a moderator may manage this revitalization request",
+	},
+	PermWorkRevitalization: {
+		owner:       true,
+		description: "only the workshop whose bid was accepted may do this",
+	},
+	PermOverrideStatus: {
+		roles:       []models.Role{models.RoleModerator, models.RoleAdmin},
+		description: "only a moderator may override the status of a product",
+	},
+	PermManageAPIKey: {
+		owner:       true,
+		roles:       []models.Role{models.RoleAdmin},
+		description: "only the key owner or an admin may manage this API key",
+	},
+}
+
+// Authorize checks whether the actor may perform perm on a resource owned by ownerID.
+// It returns an error wrapping ErrForbidden when the policy denies the action.
+func Authorize(actor Actor, perm Permission, ownerID uuid.UUID) error {
+	p, ok := policies[perm]
+	if !ok {
+		// Unknown permissions are denied rather than silently allowed
+		return fmt.Errorf("%w: no policy defined for %s", ErrForbidden, perm)
+	}
+
+	if p.owner && actor.ID != uuid.Nil && actor.ID == ownerID {
+		return nil
+	}
+	for _, role := range p.roles {
+		if actor.Role == role {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrForbidden, p.description)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"backend/models"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// expectedPolicies spells out who may perform each permission, independently of the policies map
+var expectedPolicies = map[Permission]struct {
+	owner, moderator, admin bool
+}{
+	PermDeleteComment:          {owner: true, moderator: true, admin: true},
+	PermDeleteRating:           {owner: true, moderator: true, admin: true},
+	PermAddTransaction:         {owner: true},
+	PermEditProduct:            {owner: true, moderator: true, admin: true},
+	PermDeleteTransactionMedia: {owner: true, moderator: true, admin: true},
+	PermManageAPIKey:           {owner: true, admin: true},
+	PermSellOrder:              {owner: true},
+	PermBuyOrder:               {owner: true},
+	PermCancelOrder:            {owner: true, moderator: true, admin: true},
+	PermViewOrder:              {owner: true, moderator: true, admin: true},
+	PermAmendTransaction:       {owner: true, moderator: true, admin: true},
+	PermOverrideStatus:         {moderator: true, admin: true},
+	PermManageRevitalization:   {owner: true, moderator: true, admin: true},
+	PermWorkRevitalization:     {owner: true},
+}
+
+func TestPoliciesAreCovered(t *testing.T) {
+	for perm := range policies {
+		if _, ok := expectedPolicies[perm]; !ok {
+			t.Errorf("policy %s has no expectation in this test", perm)
+		}
+	}
+	for perm := range expectedPolicies {
+		if _, ok := policies[perm]; !ok {
+			t.Errorf("expected a policy for %s", perm)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ownerID := uuid.New()
+	actors := []struct {
+		name  string
+		actor Actor
+		allow func(owner, moderator, admin bool) bool
+	}{
+		{"owner", Actor{ID: ownerID, Role: models.RoleUser}, func(owner, _, _ bool) bool { return owner }},
+		{"other user", Actor{ID: uuid.New(), Role: models.RoleUser}, func(_, _, _ bool) bool { return false }},
+		{"workshop", Actor{ID: uuid.New(), Role: models.RoleWorkshop}, func(_, _, _ bool) bool { return false }},
+		{"moderator", Actor{ID: uuid.New(), Role: models.RoleModerator}, func(_, moderator, _ bool) bool { return moderator }},
+		{"admin", Actor{ID: uuid.New(), Role: models.RoleAdmin}, func(_, _, admin bool) bool { return admin }},
+	}
+
+	for perm, want := range expectedPolicies {
+		for _, a := range actors {
+			t.Run(string(perm)+"/"+a.name, func(t *testing.T) {
+				err := Authorize(a.actor, perm, ownerID)
+				if a.allow(want.owner, want.moderator, want.admin) {
+					if err != nil {
+						t.Fatalf("expected %s to be allowed, got %v", a.name, err)
+					}
+					return
+				}
+				if !errors.Is(err, ErrForbidden) {
+					t.Fatalf("expected ErrForbidden for %s, got %v", a.name, err)
+				}
+			})
+		}
+	}
+}
+
+func TestAuthorizeDeniesAnonymousOwner(t *testing.T) {
+	// A resource without an owner must not be granted to an actor without an ID
+	if err := Authorize(Actor{}, PermEditProduct, uuid.Nil); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestAuthorizeDeniesUnknownPermission(t *testing.T) {
+	ownerID := uuid.New()
+	if err := Authorize(Actor{ID: ownerID, Role: models.RoleAdmin}, Permission("unknown:perm"), ownerID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"backend/models"
+	"backend/repository"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidVisibility = errors.New("invalid profile visibility")
+
+// PrivacyService manages users' privacy settings and decides what of a profile a viewer may see.
+// A nil viewer is a visitor who is not logged in.
+type PrivacyService struct {
+	privacyRepo *repository.PrivacyRepository
+}
+
+// NewPrivacyService creates a new instance of PrivacyService
+func NewPrivacyService(privacyRepo *repository.PrivacyRepository) *PrivacyService {
+	return &PrivacyService{privacyRepo: privacyRepo}
+}
+
+// Get returns the privacy settings of a user, or the defaults when they never changed them
+func (s *PrivacyService) Get(userID string) (*models.UserPrivacy, error) {
+	privacy, err := s.privacyRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch privacy settings: %w", err)
+	}
+	if privacy == nil {
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			return nil, ErrUserNotFound
+		}
+		return models.DefaultPrivacy(id), nil
+	}
+	return privacy, nil
+}
+
+// Update changes the privacy settings of a user; fields missing from the request are kept
+func (s *PrivacyService) Update(userID string, req *models.UpdatePrivacy) (*models.UserPrivacy, error) {
+	privacy, err := s.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Visibility != nil {
+		if !req.Visibility.IsValid() {
+			return nil, ErrInvalidVisibility
+		}
+		privacy.Visibility = *req.Visibility
+	}
+	if req.HideEmail != nil {
+		privacy.HideEmail = *req.HideEmail
+	}
+	if req.HideActivity != nil {
+		privacy.HideActivity = *req.HideActivity
+	}
+	privacy.UpdatedAt = time.Now().UTC()
+
+	if err := s.privacyRepo.Save(privacy); err != nil {
+		return nil, fmt.Errorf("failed to save privacy settings: %w", err)
+	}
+	return privacy, nil
+}
+
+// GetMany returns the privacy settings of several users keyed by user ID, with defaults filled in
+func (s *PrivacyService) GetMany(userIDs []uuid.UUID) (map[uuid.UUID]*models.UserPrivacy, error) {
+	stored, err := s.privacyRepo.GetByUserIDs(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch privacy settings: %w", err)
+	}
+
+	settings := make(map[uuid.UUID]*models.UserPrivacy, len(userIDs))
+	for _, id := range userIDs {
+		if privacy, ok := stored[id]; ok {
+			settings[id] = &privacy
+		} else {
+			settings[id] = models.DefaultPrivacy(id)
+		}
+	}
+	return settings, nil
+}
+
+// CanViewActivity reports whether the viewer may see which products the user rated
+func (s *PrivacyService) CanViewActivity(viewer *Actor, userID string) (bool, error) {
+	privacy, err := s.Get(userID)
+	if err != nil {
+		return false, err
+	}
+	if isSelfOrStaff(viewer, privacy.UserID) {
+		return true, nil
+	}
+	return !privacy.HideActivity && canViewProfile(viewer, privacy), nil
+}
+
+// isSelfOrStaff reports whether the viewer is the user themselves or a moderator or admin, who see
+// profiles regardless of the user's settings
+func isSelfOrStaff(viewer *Actor, userID uuid.UUID) bool {
+	if viewer == nil {
+		return false
+	}
+	return viewer.ID == userID || viewer.Role == models.RoleModerator || viewer.Role == models.RoleAdmin
+}
+
+// canViewProfile reports whether the profile visibility admits the viewer
+func canViewProfile(viewer *Actor, privacy *models.UserPrivacy) bool {
+	if isSelfOrStaff(viewer, privacy.UserID) {
+		return true
+	}
+	switch privacy.Visibility {
+	case models.VisibilityPrivate:
+		return false
+	case models.VisibilityMembers:
+		return viewer != nil
+	default:
+		return true
+	}
+}
+
+// applyPrivacy strips what the viewer may not see from the user and reports whether the profile is
+// visible at all. Hidden profiles are reduced to their ID, so content they authored still renders.
+func applyPrivacy(viewer *Actor, user *models.User, privacy *models.UserPrivacy) bool {
+	user.Password = ""
+	if viewer != nil && viewer.ID == user.ID {
+		return true
+	}
+
+	if !canViewProfile(viewer, privacy) {
+		*user = models.User{ID: user.ID, Name: models.PrivateUserName}
+		return false
+	}
+
+	if privacy.HideEmail && !isSelfOrStaff(viewer, user.ID) {
+		user.Email = ""
+	} else {
+		user.Email = ObfuscateEmail(user.Email)
+	}
+	return true
+}
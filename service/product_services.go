@@ -2,11 +2,15 @@ package service
 
 import (
 	"backend/models"
+	"backend/pagination"
 	"backend/repository"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,28 +20,62 @@ import (
 // ProductService handles business logic for products
 type ProductService struct {
 	productRepo *repository.ProductRepository
+	searcher    repository.ProductSearcher
+	categories  *CategoryService
+	media       *MediaService
+	jobs        *repository.RevitalizationRepository
+	uow         *repository.UnitOfWork
 }
 
 // NewProductService creates a new instance of ProductService
-func NewProductService(productRepo *repository.ProductRepository) *ProductService {
-	return &ProductService{productRepo: productRepo}
+func NewProductService(productRepo *repository.ProductRepository, searcher repository.ProductSearcher, categories *CategoryService, media *MediaService, jobs *repository.RevitalizationRepository, uow *repository.UnitOfWork) *ProductService {
+	return &ProductService{productRepo: productRepo, searcher: searcher, categories: categories, media: media, jobs: jobs, uow: uow}
 }
 
-// Create a new product
-func (s *ProductService) Create(product *models.ProductRequest, userID uuid.UUID) (*models.Product, error) {
+// Create a new product together with its "submitted" transaction and the images sent with it, in one
+// database transaction. The category and subcategory must come from the taxonomy and are stored as slugs.
+func (s *ProductService) Create(product *models.ProductRequest, userID uuid.UUID) (*models.Product, *models.Transaction, error) {
+	category, subCategory, err := s.categories.Resolve(product.Category, product.SubCategory)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	p := &models.Product{ // Correctly initialize the Product struct
 		ID:          uuid.New(),
 		Name:        product.Name,
 		Description: product.Description,
 		Price:       product.Price,
-		Category:    product.Category,
-		Status:      models.StatusAvailable,
-		SubCategory: product.SubCategory,
+		Category:    category,
+		Status:      InitialStatus(),
+		SubCategory: subCategory,
 		CreatedAt:   time.Now().UTC(),
 		UserID:      userID,
 	}
 
-	return p, s.productRepo.Create(p)
+	entry := newProductEntry(Actor{ID: userID}, p, models.Submitted, p.Description)
+	media, err := s.media.upload(p.ID, &entry.ID, userID, transactionUploads(product.ImageData, product.Images))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(media) > 0 {
+		entry.ImageURL = path.Base(media[0].ImageKey)
+	}
+
+	err = s.uow.Do(func(repos *repository.RepositoryFactory) error {
+		if err := repos.GetProductRepository().Create(p); err != nil {
+			return err
+		}
+		if err := repos.GetTransactionRepository().Create(entry); err != nil {
+			return err
+		}
+		return repos.GetMediaRepository().Create(media)
+	})
+	if err != nil {
+		s.media.discard(media)
+		return nil, nil, fmt.Errorf("failed to create product: %w", err)
+	}
+	entry.Images = media
+	return p, entry, nil
 }
 
 // Update an existing product
@@ -51,9 +89,285 @@ func (service *ProductService) Update(product *models.Product) error {
 	return nil
 }
 
-// Delete a product by ID
-func (s *ProductService) Delete(id uuid.UUID) error {
-	return s.productRepo.Delete(id)
+var (
+	ErrProductNotEditable = errors.New("product can no longer be changed")
+	ErrNoChanges          = errors.New("no changes given")
+)
+
+// Edit applies a partial update to a listing on behalf of its owner or a moderator. The change is
+// recorded as an "updated" transaction on the product, with the new image if one was given.
+func (s *ProductService) Edit(actor Actor, productID uuid.UUID, req *models.UpdateProduct) (*models.Product, *models.Transaction, error) {
+	uploads := transactionUploads(req.ImageData, req.Images)
+	product, status, err := s.getForAction(actor, productID, models.Updated, len(uploads) > 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := map[string]interface{}{}
+	var changed []string
+	if req.Name != nil && *req.Name != product.Name {
+		changed = append(changed, fmt.Sprintf("name: %q -> %q", product.Name, *req.Name))
+		changes["name"], product.Name = *req.Name, *req.Name
+	}
+	if req.Description != nil && *req.Description != product.Description {
+		changed = append(changed, "description")
+		changes["description"], product.Description = *req.Description, *req.Description
+	}
+	if req.Price != nil && *req.Price != product.Price {
+		changed = append(changed, fmt.Sprintf("price: %.2f -> %.2f", product.Price, *req.Price))
+		changes["price"], product.Price = *req.Price, *req.Price
+	}
+	if req.Category != nil || req.SubCategory != nil {
+		category, subCategory := product.Category, product.SubCategory
+		if req.Category != nil {
+			category = *req.Category
+		}
+		if req.SubCategory != nil {
+			subCategory = *req.SubCategory
+		}
+		category, subCategory, err := s.categories.Resolve(category, subCategory)
+		if err != nil {
+			return nil, nil, err
+		}
+		if category != product.Category {
+			changed = append(changed, fmt.Sprintf("category: %q -> %q", product.Category, category))
+			changes["category"], product.Category = category, category
+		}
+		if subCategory != product.SubCategory {
+			changed = append(changed, fmt.Sprintf("sub_category: %q -> %q", product.SubCategory, subCategory))
+			changes["sub_category"], product.SubCategory = subCategory, subCategory
+		}
+	}
+	if len(uploads) == 1 {
+		changed = append(changed, "image")
+	} else if len(uploads) > 1 {
+		changed = append(changed, fmt.Sprintf("%d images", len(uploads)))
+	}
+	if len(changed) == 0 {
+		return nil, nil, ErrNoChanges
+	}
+
+	entry := newProductEntry(actor, product, models.Updated, "Updated "+strings.Join(changed, ", "))
+	media, err := s.media.upload(product.ID, &entry.ID, actor.ID, uploads)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(media) > 0 {
+		entry.ImageURL = path.Base(media[0].ImageKey)
+	}
+
+	if err := s.record(product, status, changes, entry, media); err != nil {
+		return nil, nil, fmt.Errorf("failed to update product: %w", err)
+	}
+	entry.Images = media
+	return product, entry, nil
+}
+
+// Withdraw pulls a listing down on behalf of its owner or a moderator. The product is kept with the
+// "withdrawn" status, so its history survives, and a "withdrawn" transaction records who did it and why.
+// While a workshop holds a revitalization job on the product, the job must be cancelled first.
+func (s *ProductService) Withdraw(actor Actor, productID uuid.UUID, reason string) (*models.Transaction, error) {
+	product, status, err := s.getForAction(actor, productID, models.Withdrawn, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	description := "Listing withdrawn"
+	if reason = strings.TrimSpace(reason); reason != "" {
+		description += ": " + reason
+	}
+	entry := newProductEntry(actor, product, models.Withdrawn, description)
+
+	noJob := func(repos *repository.RepositoryFactory) error {
+		return checkNoJob(repos, product.ID)
+	}
+	if err := s.recordWith(product, status, nil, entry, nil, noJob); err != nil {
+		return nil, fmt.Errorf("failed to withdraw product: %w", err)
+	}
+	return entry, nil
+}
+
+// AddTransaction records a lifecycle step of a product, such as revitalized or listed again, and moves
+// the product to the status the lifecycle gives for it. A price, when given, becomes the product's new
+// price. Edits, withdrawals and sales have their own methods. While a workshop holds a revitalization
+// job on the product, only that workshop may record it revitalized, which delivers the job.
+func (s *ProductService) AddTransaction(actor Actor, productID uuid.UUID, req *models.AddTransactionRequest) (*models.Product, *models.Transaction, error) {
+	if via := lifecycle[req.Action].via; via != "" {
+		return nil, nil, fmt.Errorf("%w: %q is recorded by %s", ErrInvalidAction, req.Action, via)
+	}
+
+	var job *models.RevitalizationRequest
+	if req.Action == models.Revitalized {
+		var err error
+		if job, err = s.jobs.GetByProductID(productID, models.JobRevitalizationStatuses); err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch revitalization job: %w", err)
+		}
+	}
+
+	uploads := transactionUploads(req.ImageData, req.Images)
+	product, status, err := s.getForAction(actor, productID, req.Action, len(uploads) > 0, job)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job != nil && job.OwnerID != product.UserID {
+		job = nil // The product changed hands since the bid was accepted
+	}
+
+	entry := newProductEntry(actor, product, req.Action, req.Description)
+	media, err := s.media.upload(product.ID, &entry.ID, actor.ID, uploads)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(media) > 0 {
+		entry.ImageURL = path.Base(media[0].ImageKey)
+	}
+
+	changes := map[string]interface{}{}
+	if req.Price > 0 {
+		changes["price"], product.Price = req.Price, req.Price
+	}
+	var deliver func(repos *repository.RepositoryFactory) error
+	if job != nil {
+		deliver = func(repos *repository.RepositoryFactory) error {
+			delivered, err := repos.GetRevitalizationRepository().UpdateStatus(job.ID, models.JobRevitalizationStatuses, models.RevitalizationDelivered, map[string]interface{}{
+				"transaction_id": entry.ID,
+				"delivered_at":   entry.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			if !delivered {
+				return fmt.Errorf("%w: the revitalization job was cancelled or delivered in the meantime", ErrInvalidTransition)
+			}
+			return nil
+		}
+	}
+	if err := s.recordWith(product, status, changes, entry, media, deliver); err != nil {
+		return nil, nil, fmt.Errorf("failed to record transaction: %w", err)
+	}
+	product.Status = status
+	entry.Images = media
+	return product, entry, nil
+}
+
+// record moves a product to status and stores changes to it, the transaction entry describing them and
+// the images uploaded with them in one database transaction, so the history never misses a change. The
+// product must still be in the status the action was checked against; if a concurrent request moved it
+// on, nothing is stored and the error wraps ErrInvalidTransition. When it fails, the uploaded images are
+// removed from S3 again.
+func (s *ProductService) record(product *models.Product, status models.ProductStatus, changes map[string]interface{}, entry *models.Transaction, media []models.Media) error {
+	return s.recordWith(product, status, changes, entry, media, nil)
+}
+
+// recordWith is record with a further write, when given, in the same database transaction
+func (s *ProductService) recordWith(product *models.Product, status models.ProductStatus, changes map[string]interface{}, entry *models.Transaction, media []models.Media, also func(repos *repository.RepositoryFactory) error) error {
+	err := s.uow.Do(func(repos *repository.RepositoryFactory) error {
+		moved, err := repos.GetProductRepository().SetStatusIf(product.ID, product.Status, status, changes)
+		if err != nil {
+			return err
+		}
+		if !moved {
+			return fmt.Errorf("%w: the product is no longer %q", ErrInvalidTransition, product.Status)
+		}
+		if err := repos.GetTransactionRepository().Create(entry); err != nil {
+			return err
+		}
+		if err := repos.GetMediaRepository().Create(media); err != nil {
+			return err
+		}
+		if also != nil {
+			return also(repos)
+		}
+		return nil
+	})
+	if err != nil {
+		s.media.discard(media)
+	}
+	return err
+}
+
+// checkNoOrder fails while an open order holds the product, so the product's status cannot be changed
+// from under the order. It runs in the unit of work that changes the product.
+func checkNoOrder(repos *repository.RepositoryFactory, productID uuid.UUID) error {
+	order, err := repos.GetOrderRepository().GetActiveByProductID(productID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch order: %w", err)
+	}
+	if order != nil {
+		return fmt.Errorf("%w: order %s on the product is %s; cancel it first", ErrInvalidTransition, order.ID, order.Status)
+	}
+	return nil
+}
+
+// getForAction loads a product the actor wants to take an action on and checks the action against the
+// lifecycle. It returns the product and the status the action leads to. A revitalization job the owner
+// of the product handed out, when given, makes its workshop the one who may take the action.
+func (s *ProductService) getForAction(actor Actor, productID uuid.UUID, action models.TransactionAction, hasImage bool, job *models.RevitalizationRequest) (*models.Product, models.ProductStatus, error) {
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil || product == nil {
+		return nil, "", ErrProductNotFound
+	}
+
+	var status models.ProductStatus
+	if job != nil && job.WorkshopID != nil && job.OwnerID == product.UserID {
+		status, err = transitionAs(actor, product, action, hasImage, PermWorkRevitalization, *job.WorkshopID)
+	} else {
+		status, err = Transition(actor, product, action, hasImage)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return product, status, nil
+}
+
+// newProductEntry builds the transaction entry recording a change the actor made to a product
+func newProductEntry(actor Actor, product *models.Product, action models.TransactionAction, description string) *models.Transaction {
+	return &models.Transaction{
+		ID:          uuid.New(),
+		ItemID:      product.ID,
+		UserID:      actor.ID,
+		Description: description,
+		Action:      action,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+var ErrInvalidSearch = errors.New("invalid search")
+
+// Search finds a page of products matching a free-text query and filters. Withdrawn listings are never
+// returned. Results are ranked rather than ordered by creation time, so their cursors hold an offset.
+func (s *ProductService) Search(query models.ProductSearchQuery, params pagination.Params) (*models.ProductSearchResult, pagination.Page, error) {
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Sort == "" {
+		query.Sort = models.SortRelevance
+	}
+	if !query.Sort.IsValid() {
+		return nil, pagination.Page{}, fmt.Errorf("%w: unknown sort %q", ErrInvalidSearch, query.Sort)
+	}
+	if query.Status != "" && (!query.Status.IsValid() || query.Status == models.StatusWithdrawn) {
+		return nil, pagination.Page{}, fmt.Errorf("%w: unknown status %q", ErrInvalidSearch, query.Status)
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return nil, pagination.Page{}, fmt.Errorf("%w: min_price is greater than max_price", ErrInvalidSearch)
+	}
+	if query.CreatedFrom != nil && query.CreatedTo != nil && query.CreatedFrom.After(*query.CreatedTo) {
+		return nil, pagination.Page{}, fmt.Errorf("%w: created_from is after created_to", ErrInvalidSearch)
+	}
+
+	query.Limit = params.Limit + 1
+	query.Offset = params.Skip()
+	result, err := s.searcher.Search(query)
+	if err != nil {
+		return nil, pagination.Page{}, fmt.Errorf("failed to search products: %w", err)
+	}
+
+	var total *int64
+	if params.WithTotal {
+		total = &result.Total
+	}
+	var page pagination.Page
+	result.Products, page = pagination.FinishOffset(result.Products, params, total)
+	return result, page, nil
 }
 
 // GetByID retrieves a product by its ID
@@ -209,44 +523,45 @@ func (s *ProductService) GetRandomProducts() ([]models.Product, error) {
 	return s.productRepo.GetRandomProducts()
 }
 
-// GetProductsByUserID retrieves products for a specific user by their UUID with pagination
-func (s *ProductService) GetProductsByUserID(userID uuid.UUID, count, page int) ([]models.Product, error) {
-	// Calculate offset based on page and count
-	offset := (page - 1) * count
+// GetProductsByUserID retrieves a page of a user's products, newest first
+func (s *ProductService) GetProductsByUserID(userID uuid.UUID, params pagination.Params) ([]models.Product, pagination.Page, error) {
+	return s.productRepo.GetProductsByUserID(userID, params)
+}
 
-	// Call the repository method with pagination parameters
-	products, err := s.productRepo.GetProductsByUserID(userID, count, offset)
+// UpdateStatus sets the status of a product. It is a moderator override for fixing bad data and does
+// not follow the lifecycle, but it is still recorded as an "overridden" transaction in the product's
+// history, so the provenance chain shows who changed the status and why. It is refused while an open
+// order holds the product; that order has to be cancelled first.
+func (s *ProductService) UpdateStatus(actor Actor, productID uuid.UUID, status models.ProductStatus, reason string) (*models.Transaction, error) {
+	if !status.IsValid() {
+		return nil, ErrInvalidStatus
+	}
+	product, _, err := s.getForAction(actor, productID, models.Overridden, false, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	// Business logic could be added here, e.g., filtering hidden products.
-	return products, nil
-}
-
-// UpdateStatus updates the status of a product.
-func (s *ProductService) UpdateStatus(productID uuid.UUID, status models.ProductStatus) error {
-	product, err := s.productRepo.GetByID(productID)
-	if err != nil {
-		return ErrProductNotFound
+	description := fmt.Sprintf("Status overridden by a moderator: %q -> %q", product.Status, status)
+	if reason = strings.TrimSpace(reason); reason != "" {
+		description += ": " + reason
 	}
+	entry := newProductEntry(actor, product, models.Overridden, description)
 
-	product.Status = status
-	return s.productRepo.Update(product)
+	noOrder := func(repos *repository.RepositoryFactory) error {
+		return checkNoOrder(repos, product.ID)
+	}
+	if err := s.recordWith(product, status, nil, entry, nil, noOrder); err != nil {
+		return nil, fmt.Errorf("failed to update status: %w", err)
+	}
+	return entry, nil
 }
 
-// GetRestoredProducts retrieves products with the status "restored"// GetProductsByStatusPaginated fetches products by the specified status with pagination
-func (s *ProductService) GetProductsByStatusPaginated(status string, limit int, offset int) ([]models.Product, error) {
-	// Call repository method to get products by status with pagination
-	return s.productRepo.GetByStatusPaginated(status, limit, offset)
+// GetProductsByStatusPaginated fetches a page of the products with the specified status, newest first
+func (s *ProductService) GetProductsByStatusPaginated(status string, params pagination.Params) ([]models.Product, pagination.Page, error) {
+	return s.productRepo.GetByStatusPaginated(status, params)
 }
 
-func (s *ProductService) GetRandomProductsPaginated(count int, offset int) ([]models.Product, error) {
-	// Call the repository function to get random products with pagination
-	products, err := s.productRepo.GetRandomProductsPaginated(count, offset)
-	if err != nil {
-		return nil, err
-	}
-
-	return products, nil
+// GetRandomProductsPaginated fetches a page of the products still listed, newest first
+func (s *ProductService) GetRandomProductsPaginated(params pagination.Params) ([]models.Product, pagination.Page, error) {
+	return s.productRepo.GetRandomProductsPaginated(params)
 }
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"backend/models"
+	"backend/provenance"
+	"backend/repository"
+	"backend/token"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+const (
+	// provenancePurpose is the purpose claim of provenance certificates, which keeps them from being
+	// accepted as any other kind of token
+	provenancePurpose = "provenance"
+	// certificateLifetime is how long a provenance certificate is valid. A certificate is a snapshot;
+	// entries added later are covered by a new one.
+	certificateLifetime = 5 * 365 * 24 * time.Hour
+)
+
+var ErrProvenanceBroken = errors.New("provenance chain does not verify")
+
+// ProvenanceService exposes the hash-chained history of products and issues signed certificates of it
+type ProvenanceService struct {
+	transactionRepo *repository.TransactionRepository
+	amendmentRepo   *repository.AmendmentRepository
+	productRepo     *repository.ProductRepository
+	keys            *token.KeySet // Signs certificates; see token.LoadProvenanceKeySetFromEnv
+}
+
+// NewProvenanceService creates a new instance of ProvenanceService
+func NewProvenanceService(transactionRepo *repository.TransactionRepository, amendmentRepo *repository.AmendmentRepository, productRepo *repository.ProductRepository, keys *token.KeySet) *ProvenanceService {
+	return &ProvenanceService{transactionRepo: transactionRepo, amendmentRepo: amendmentRepo, productRepo: productRepo, keys: keys}
+}
+
+// Get retrieves a product's provenance chain and verifies it, together with the amendments of its
+// transactions
+func (s *ProvenanceService) Get(productID uuid.UUID) (*models.Provenance, error) {
+	_, result, err := s.load(productID)
+	return result, err
+}
+
+// Certificate signs a snapshot of a product's provenance chain with the active provenance key. Buyers
+// can verify it offline: the signature against the key's JWK, and the chain in its claims by recomputing
+// the hashes. Only chains that verify are certified. The provenance keys are not rotated with the token
+// keys, so certificates keep verifying for their whole lifetime.
+func (s *ProvenanceService) Certificate(productID uuid.UUID) (*models.ProvenanceCertificate, error) {
+	product, result, err := s.load(productID)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Verification.Valid {
+		return nil, fmt.Errorf("%w: %d problems found", ErrProvenanceBroken, len(result.Verification.Problems))
+	}
+
+	certificate, err := s.keys.Sign(product.UserID.String(), provenancePurpose, certificateLifetime, jwt.MapClaims{
+		"product_id":   product.ID.String(),
+		"product_name": product.Name,
+		"hash_version": result.HashVersion,
+		"chain":        result.Chain,
+		"head":         result.Verification.Head,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+
+	return &models.ProvenanceCertificate{
+		Certificate: certificate,
+		KeyID:       s.keys.Active().ID,
+		JWKSURL:     "/.well-known/jwks.json",
+		Head:        result.Verification.Head,
+	}, nil
+}
+
+func (s *ProvenanceService) load(productID uuid.UUID) (*models.Product, *models.Provenance, error) {
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil || product == nil {
+		return nil, nil, ErrProductNotFound
+	}
+
+	chain, err := s.transactionRepo.GetChain(productID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	ids := make([]uuid.UUID, len(chain))
+	for i := range chain {
+		ids[i] = chain[i].ID
+	}
+	amendments, err := s.amendmentRepo.ListByTransactionIDs(ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch amendments: %w", err)
+	}
+
+	verification := provenance.Verify(chain)
+	provenance.VerifyAmendments(&verification, chain, amendments)
+	return product, &models.Provenance{
+		ProductID:    product.ID,
+		HashVersion:  provenance.Version,
+		Chain:        chain,
+		Verification: verification,
+	}, nil
+}
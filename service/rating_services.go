@@ -3,6 +3,7 @@ package service
 
 import (
 	"backend/models"
+	"backend/pagination"
 	"backend/repository"
 	"errors"
 	"log"
@@ -73,8 +74,22 @@ func (service *RatingService) Create(addRating *models.AddRating, userID string)
 	return rating, nil
 }
 
-// Delete removes a rating by its ID
-func (service *RatingService) Delete(id uuid.UUID) error {
+// Delete removes a rating by its ID, if the actor is allowed to
+func (service *RatingService) Delete(id uuid.UUID, actor Actor) error {
+	rating, err := service.ratingRepo.GetByID(id)
+	if err != nil {
+		log.Printf("Error finding rating with ID %s: %v", id, err)
+		return errors.New("failed to find rating")
+	}
+	if rating == nil {
+		return ErrRatingNotFound
+	}
+
+	// Only the author or a moderator may delete a rating
+	if err := Authorize(actor, PermDeleteRating, rating.UserID); err != nil {
+		return err
+	}
+
 	if err := service.ratingRepo.Delete(id); err != nil {
 		log.Printf("Error deleting rating with ID %s: %v", id, err)
 		return errors.New("failed to delete rating")
@@ -82,14 +97,24 @@ func (service *RatingService) Delete(id uuid.UUID) error {
 	return nil
 }
 
-// GetRatedProductsByUserId retrieves all rated products by a user's ID
-func (service *RatingService) GetRatedProductsByUserId(userID uuid.UUID) ([]models.Rating, error) {
-	ratings, err := service.ratingRepo.GetRatedProductsByUserId(userID)
+// Export retrieves a page of all ratings, e.g. for training the recommender
+func (service *RatingService) Export(params pagination.Params) ([]models.Rating, pagination.Page, error) {
+	ratings, page, err := service.ratingRepo.List(params)
+	if err != nil {
+		log.Printf("Error exporting ratings: %v", err)
+		return nil, page, errors.New("failed to export ratings")
+	}
+	return ratings, page, nil
+}
+
+// GetRatedProductsByUserId retrieves a page of the ratings a user gave, newest first
+func (service *RatingService) GetRatedProductsByUserId(userID uuid.UUID, params pagination.Params) ([]models.Rating, pagination.Page, error) {
+	ratings, page, err := service.ratingRepo.ListByUserID(userID, params)
 	if err != nil {
 		log.Printf("Error retrieving ratings for user ID %s: %v", userID, err)
-		return nil, errors.New("failed to retrieve user ratings")
+		return nil, page, errors.New("failed to retrieve user ratings")
 	}
-	return ratings, nil
+	return ratings, page, nil
 }
 
 // GetPuanByUserIdItemId retrieves the score (puan) for a specific user and item
@@ -112,8 +137,3 @@ func (service *RatingService) GetAverageRatingByProductId(productID uuid.UUID) (
 	}
 	return average, count, nil
 }
-
-func (service *RatingService) GetRatedProductIDsByUserID(userID string) ([]string, error) {
-	// Delegate to repository to get product IDs
-	return service.ratingRepo.GetRatedItemsByUserID(userID)
-}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"backend/models"
+	"backend/pagination"
+	"backend/repository"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRevitalizationNotFound = errors.New("revitalization request not found")
+	ErrInvalidRevitalization  = errors.New("invalid revitalization request")
+	ErrInvalidBid             = errors.New("invalid bid")
+)
+
+// RevitalizationService lets owners hand the revitalization of a product to a workshop: the owner
+// publishes a request, workshops bid on it, and the owner accepts one bid. The winning workshop starts
+// the job and delivers it by posting the product's revitalized transaction through ProductService.
+type RevitalizationService struct {
+	revitalizationRepo *repository.RevitalizationRepository
+	productRepo        *repository.ProductRepository
+	transactionRepo    *repository.TransactionRepository
+	uow                *repository.UnitOfWork
+}
+
+// NewRevitalizationService creates a new instance of RevitalizationService
+func NewRevitalizationService(revitalizationRepo *repository.RevitalizationRepository, productRepo *repository.ProductRepository, transactionRepo *repository.TransactionRepository, uow *repository.UnitOfWork) *RevitalizationService {
+	return &RevitalizationService{revitalizationRepo: revitalizationRepo, productRepo: productRepo, transactionRepo: transactionRepo, uow: uow}
+}
+
+// Publish opens a revitalization request on a product for its owner. The product must be in a status the
+// lifecycle allows revitalizing from, and may only have one request going at a time.
+func (s *RevitalizationService) Publish(actor Actor, req *models.PublishRevitalization) (*models.RevitalizationRequest, error) {
+	product, err := s.productRepo.GetByID(req.ProductID)
+	if err != nil || product == nil {
+		return nil, ErrProductNotFound
+	}
+	if err := Authorize(actor, PermManageRevitalization, product.UserID); err != nil {
+		return nil, err
+	}
+	if err := revitalizable(product); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.revitalizationRepo.GetByProductID(product.ID, models.ActiveRevitalizationStatuses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revitalization requests: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%w: the product already has a revitalization request that is %s", ErrInvalidRevitalization, existing.Status)
+	}
+
+	now := time.Now().UTC()
+	request := &models.RevitalizationRequest{
+		ID:          uuid.New(),
+		ProductID:   product.ID,
+		OwnerID:     product.UserID,
+		Description: req.Description,
+		Status:      models.RevitalizationOpen,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.revitalizationRepo.Create(request); err != nil {
+		return nil, fmt.Errorf("failed to publish revitalization request: %w", err)
+	}
+	return request, nil
+}
+
+// List retrieves a page of the requests in a status, newest first; open requests when none is given
+func (s *RevitalizationService) List(status models.RevitalizationStatus, params pagination.Params) ([]models.RevitalizationRequest, pagination.Page, error) {
+	if status == "" {
+		status = models.RevitalizationOpen
+	}
+	if !status.IsValid() {
+		return nil, pagination.Page{}, fmt.Errorf("%w: unknown status %q", ErrInvalidRevitalization, status)
+	}
+	return s.revitalizationRepo.List(status, params)
+}
+
+// Get retrieves a request with the bids the viewer may see: all of them for the owner and moderators,
+// their own for a workshop and none for visitors
+func (s *RevitalizationService) Get(viewer *Actor, id uuid.UUID) (*models.RevitalizationRequest, error) {
+	request, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if viewer == nil {
+		return request, nil
+	}
+
+	var workshopID *uuid.UUID
+	if err := Authorize(*viewer, PermManageRevitalization, request.OwnerID); err != nil {
+		workshopID = &viewer.ID
+	}
+	if request.Bids, err = s.revitalizationRepo.ListBids(request.ID, workshopID); err != nil {
+		return nil, fmt.Errorf("failed to fetch bids: %w", err)
+	}
+	return request, nil
+}
+
+// Bid places the actor's bid on an open request. Only users an admin made workshops may bid. Each workshop
+// bids once per request, and its portfolio may only point at revitalized transactions it posted itself.
+func (s *RevitalizationService) Bid(actor Actor, id uuid.UUID, req *models.PlaceBid) (*models.RevitalizationBid, error) {
+	if actor.Role != models.RoleWorkshop {
+		return nil, fmt.Errorf("%w: only verified workshops may bid on revitalization requests", ErrForbidden)
+	}
+	request, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != models.RevitalizationOpen {
+		return nil, fmt.Errorf("%w: the request is %s and takes no more bids", ErrInvalidTransition, request.Status)
+	}
+	if request.OwnerID == actor.ID {
+		return nil, fmt.Errorf("%w: you cannot bid on your own request", ErrInvalidBid)
+	}
+
+	portfolio, err := s.portfolio(actor, req.Portfolio)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	bid := &models.RevitalizationBid{
+		ID:            uuid.New(),
+		RequestID:     request.ID,
+		WorkshopID:    actor.ID,
+		Price:         req.Price,
+		EstimatedDays: req.EstimatedDays,
+		Message:       req.Message,
+		Portfolio:     portfolio,
+		Status:        models.BidPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.revitalizationRepo.CreateBid(bid); err != nil {
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return nil, fmt.Errorf("%w: you already bid on this request", ErrInvalidBid)
+		}
+		return nil, fmt.Errorf("failed to place bid: %w", err)
+	}
+	return bid, nil
+}
+
+// Accept accepts a bid on an open request for the product's owner, which hands the job to the bid's
+// workshop. All other bids are rejected.
+func (s *RevitalizationService) Accept(actor Actor, id, bidID uuid.UUID) (*models.RevitalizationRequest, error) {
+	request, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := Authorize(actor, PermManageRevitalization, request.OwnerID); err != nil {
+		return nil, err
+	}
+	bid, err := s.revitalizationRepo.GetBid(request.ID, bidID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bid: %w", err)
+	}
+	if bid == nil {
+		return nil, fmt.Errorf("%w: bid %s not found on this request", ErrInvalidBid, bidID)
+	}
+
+	// The product may have been sold or withdrawn since the request was published
+	product, err := s.productRepo.GetByID(request.ProductID)
+	if err != nil || product == nil {
+		return nil, ErrProductNotFound
+	}
+	if product.UserID != request.OwnerID {
+		return nil, fmt.Errorf("%w: the product changed hands since the request was published", ErrInvalidTransition)
+	}
+	if err := revitalizable(product); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	err = s.uow.Do(func(repos *repository.RepositoryFactory) error {
+		// Holding the product's row keeps it from being withdrawn or ordered while the job is handed out
+		unchanged, err := repos.GetProductRepository().SetStatusIf(product.ID, product.Status, product.Status, nil)
+		if err != nil {
+			return err
+		}
+		if !unchanged {
+			return fmt.Errorf("%w: the product was changed in the meantime", ErrInvalidTransition)
+		}
+
+		accepted, err := repos.GetRevitalizationRepository().UpdateStatus(request.ID,
+			[]models.RevitalizationStatus{models.RevitalizationOpen}, models.RevitalizationAccepted,
+			map[string]interface{}{"accepted_bid_id": bid.ID, "workshop_id": bid.WorkshopID, "accepted_at": now})
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return fmt.Errorf("%w: the request is no longer open", ErrInvalidTransition)
+		}
+		return repos.GetRevitalizationRepository().ResolveBids(request.ID, &bid.ID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept bid: %w", err)
+	}
+	return s.Get(&actor, request.ID)
+}
+
+// Start marks an accepted job as in progress for the workshop that holds it
+func (s *RevitalizationService) Start(actor Actor, id uuid.UUID) (*models.RevitalizationRequest, error) {
+	request, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.WorkshopID == nil {
+		return nil, fmt.Errorf("%w: no bid was accepted on the request yet", ErrInvalidTransition)
+	}
+	if err := Authorize(actor, PermWorkRevitalization, *request.WorkshopID); err != nil {
+		return nil, err
+	}
+
+	started, err := s.revitalizationRepo.UpdateStatus(request.ID,
+		[]models.RevitalizationStatus{models.RevitalizationAccepted}, models.RevitalizationInProgress,
+		map[string]interface{}{"started_at": time.Now().UTC()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start job: %w", err)
+	}
+	if !started {
+		return nil, fmt.Errorf("%w: only accepted jobs can be started, this one is %s", ErrInvalidTransition, request.Status)
+	}
+	return s.Get(&actor, request.ID)
+}
+
+// Cancel calls a request off for the product's owner or a moderator. Owners may cancel until the
+// workshop starts the job; after that only a moderator can.
+func (s *RevitalizationService) Cancel(actor Actor, id uuid.UUID, reason string) (*models.RevitalizationRequest, error) {
+	request, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := Authorize(actor, PermManageRevitalization, request.OwnerID); err != nil {
+		return nil, err
+	}
+
+	from := []models.RevitalizationStatus{models.RevitalizationOpen, models.RevitalizationAccepted}
+	if isModerator(actor) {
+		from = models.ActiveRevitalizationStatuses
+	}
+	err = s.uow.Do(func(repos *repository.RepositoryFactory) error {
+		cancelled, err := repos.GetRevitalizationRepository().UpdateStatus(request.ID, from, models.RevitalizationCancelled,
+			map[string]interface{}{"reason": reason})
+		if err != nil {
+			return err
+		}
+		if !cancelled {
+			return fmt.Errorf("%w: a request that is %s cannot be cancelled", ErrInvalidTransition, request.Status)
+		}
+		return repos.GetRevitalizationRepository().ResolveBids(request.ID, nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel revitalization request: %w", err)
+	}
+	return s.Get(&actor, request.ID)
+}
+
+func (s *RevitalizationService) get(id uuid.UUID) (*models.RevitalizationRequest, error) {
+	request, err := s.revitalizationRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revitalization request: %w", err)
+	}
+	if request == nil {
+		return nil, ErrRevitalizationNotFound
+	}
+	return request, nil
+}
+
+// portfolio checks that every transaction of a bid's portfolio is a revitalized transaction the
+// workshop posted, and drops duplicates
+func (s *RevitalizationService) portfolio(actor Actor, ids []uuid.UUID) (models.TransactionIDs, error) {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	var portfolio models.TransactionIDs
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		transaction, err := s.transactionRepo.GetByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch transaction: %w", err)
+		}
+		if transaction == nil || transaction.UserID != actor.ID || transaction.Action != models.Revitalized {
+			return nil, fmt.Errorf("%w: %s is not a revitalized transaction of yours", ErrInvalidBid, id)
+		}
+		portfolio = append(portfolio, id)
+	}
+	return portfolio, nil
+}
+
+// checkNoJob fails while a workshop holds a revitalization job on the product, which must not be withdrawn
+// or sold from under it. The job has to be cancelled first. It runs in the unit of work that changes the
+// product.
+func checkNoJob(repos *repository.RepositoryFactory, productID uuid.UUID) error {
+	job, err := repos.GetRevitalizationRepository().GetByProductID(productID, models.JobRevitalizationStatuses)
+	if err != nil {
+		return fmt.Errorf("failed to fetch revitalization job: %w", err)
+	}
+	if job != nil {
+		return fmt.Errorf("%w: a workshop holds a revitalization job on the product that is %s; cancel it first", ErrInvalidTransition, job.Status)
+	}
+	return nil
+}
+
+// revitalizable checks that the lifecycle allows revitalizing the product in its current status
+func revitalizable(product *models.Product) error {
+	if from := lifecycle[models.Revitalized].from; !containsStatus(from, product.Status) {
+		return fmt.Errorf("%w: a product that is %q cannot be revitalized; allowed when it is %s",
+			ErrInvalidTransition, product.Status, joinStatuses(from))
+	}
+	return nil
+}
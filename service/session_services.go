@@ -0,0 +1,204 @@
+package service
+
+import (
+	"backend/models"
+	"backend/repository"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+const (
+	// AccessTokenTTL is how long an auth JWT stays valid; clients refresh it with their refresh token
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a session can stay idle before the user has to log in again;
+	// every refresh pushes the expiry out by this much
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// SessionMaxLifetime caps how long a session can be kept alive by refreshing it
+	SessionMaxLifetime = 90 * 24 * time.Hour
+)
+
+// SessionService handles login sessions, refresh token rotation and revocation
+type SessionService struct {
+	sessionRepo *repository.SessionRepository
+	userRepo    *repository.UserRepository
+}
+
+// NewSessionService creates a new instance of SessionService
+func NewSessionService(sessionRepo *repository.SessionRepository, userRepo *repository.UserRepository) *SessionService {
+	return &SessionService{sessionRepo: sessionRepo, userRepo: userRepo}
+}
+
+// Issue opens a new session for the user and returns its first access/refresh token pair
+func (s *SessionService) Issue(user *models.User, client models.ClientInfo) (*models.AuthTokens, error) {
+	if user.DeletedAt != nil {
+		return nil, ErrAccountDeleted
+	}
+
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	now := time.Now().UTC()
+	session := &models.Session{
+		ID:               uuid.New(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		UserAgent:        truncate(client.UserAgent, 255),
+		IPAddress:        client.IPAddress,
+		CreatedAt:        now,
+		LastRefreshedAt:  now,
+		ExpiresAt:        now.Add(RefreshTokenTTL),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.tokensFor(session, user.Role, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented token is rotated out;
+// presenting it again is treated as theft and revokes the whole session.
+func (s *SessionService) Refresh(refreshToken string) (*models.AuthTokens, error) {
+	hash := hashRefreshToken(refreshToken)
+
+	session, err := s.sessionRepo.GetByRefreshTokenHash(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up session: %w", err)
+	}
+	if session == nil {
+		// A rotated-out token coming back means someone else holds a copy of it
+		replayed, err := s.sessionRepo.GetByPreviousTokenHash(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up session: %w", err)
+		}
+		if replayed != nil {
+			log.Printf("Refresh token reuse detected for session %s, revoking it", replayed.ID)
+			if err := s.sessionRepo.Revoke(replayed.ID.String()); err != nil {
+				return nil, fmt.Errorf("failed to revoke session: %w", err)
+			}
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	// Re-read the user so role changes are picked up on the next refresh
+	user, err := s.userRepo.GetByID(session.UserID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	newRefreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	expiresAt := slidingExpiry(session.CreatedAt, time.Now().UTC())
+	rotated, err := s.sessionRepo.Rotate(session.ID.String(), hash, hashRefreshToken(newRefreshToken), expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if !rotated {
+		// Lost the race against a concurrent refresh or logout
+		return nil, ErrInvalidRefreshToken
+	}
+	session.ExpiresAt = expiresAt
+
+	return s.tokensFor(session, user.Role, newRefreshToken)
+}
+
+// Revoke ends a single session. Users can only revoke their own sessions.
+func (s *SessionService) Revoke(userID, sessionID string) error {
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to look up session: %w", err)
+	}
+	if session == nil || session.UserID.String() != userID {
+		return ErrSessionNotFound
+	}
+	return s.sessionRepo.Revoke(sessionID)
+}
+
+// RevokeAll ends every session of the user, logging them out on all devices
+func (s *SessionService) RevokeAll(userID string) error {
+	return s.sessionRepo.RevokeAllByUserID(userID)
+}
+
+// IsSessionActive reports whether access tokens minted for the session should still be accepted
+func (s *SessionService) IsSessionActive(sessionID string) (bool, error) {
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		return false, err
+	}
+	if session == nil || session.RevokedAt != nil {
+		return false, nil
+	}
+	return time.Now().Before(session.ExpiresAt), nil
+}
+
+// tokensFor mints an access token bound to the session and pairs it with the given refresh token
+func (s *SessionService) tokensFor(session *models.Session, role models.Role, refreshToken string) (*models.AuthTokens, error) {
+	if role == "" {
+		role = models.RoleUser
+	}
+	accessToken, err := GenerateJWTWithClaims(session.UserID.String(), "auth", AccessTokenTTL, jwt.MapClaims{
+		"sid":  session.ID.String(),
+		"jti":  uuid.New().String(),
+		"role": string(role),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+	}
+
+	return &models.AuthTokens{
+		AccessToken:      accessToken,
+		ExpiresAt:        time.Now().Add(AccessTokenTTL),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// slidingExpiry returns when a session refreshed at now expires: RefreshTokenTTL from now, but never
+// later than SessionMaxLifetime after it was opened
+func slidingExpiry(createdAt, now time.Time) time.Time {
+	expiresAt := now.Add(RefreshTokenTTL)
+	if limit := createdAt.Add(SessionMaxLifetime); expiresAt.After(limit) {
+		return limit
+	}
+	return expiresAt
+}
+
+// generateRefreshToken returns a random, URL-safe opaque token
+func generateRefreshToken() (string, error) {
+	return randomURLSafe(32)
+}
+
+// hashRefreshToken hashes a refresh token for storage; only the hash ever reaches the database
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncate cuts s down to at most n bytes so it fits its column
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingExpiry(t *testing.T) {
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// A refresh pushes the expiry out by the idle window
+	now := createdAt.Add(10 * 24 * time.Hour)
+	if got, want := slidingExpiry(createdAt, now), now.Add(RefreshTokenTTL); !got.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// but never past the session's maximum lifetime
+	now = createdAt.Add(SessionMaxLifetime - 24*time.Hour)
+	if got, want := slidingExpiry(createdAt, now), createdAt.Add(SessionMaxLifetime); !got.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"backend/models"
+	"backend/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// oauthStateTTL is how long a user has to complete a login at the provider
+const oauthStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownProvider          = errors.New("unknown login provider")
+	ErrInvalidOAuthState        = errors.New("invalid or expired login state")
+	ErrProviderEmailNotVerified = errors.New("provider did not return a verified email")
+)
+
+// SocialLoginService handles logging in through external OAuth2/OpenID Connect providers
+type SocialLoginService struct {
+	providers      map[string]OIDCProvider
+	identityRepo   *repository.IdentityRepository
+	userRepo       *repository.UserRepository
+	sessionService *SessionService
+	mfaService     *MFAService
+}
+
+// NewSocialLoginService creates a new instance of SocialLoginService
+func NewSocialLoginService(providers map[string]OIDCProvider, identityRepo *repository.IdentityRepository, userRepo *repository.UserRepository, sessionService *SessionService, mfaService *MFAService) *SocialLoginService {
+	return &SocialLoginService{
+		providers:      providers,
+		identityRepo:   identityRepo,
+		userRepo:       userRepo,
+		sessionService: sessionService,
+		mfaService:     mfaService,
+	}
+}
+
+// Providers lists the names of the configured providers
+func (s *SocialLoginService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start begins a login at the provider and returns the URL to send the user to
+func (s *SocialLoginService) Start(ctx context.Context, providerName string) (string, error) {
+	provider, ok := s.providers[strings.ToLower(providerName)]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := randomURLSafe(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomURLSafe(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomURLSafe(64)
+	if err != nil {
+		return "", err
+	}
+
+	// The verifier stays on the server; only its S256 challenge goes to the provider
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, pkceChallenge(verifier))
+	if err != nil {
+		return "", fmt.Errorf("failed to build authorization URL: %w", err)
+	}
+
+	pending := &models.OAuthState{
+		State:        state,
+		Provider:     provider.Name(),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().UTC().Add(oauthStateTTL),
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := s.identityRepo.CreateState(pending); err != nil {
+		return "", fmt.Errorf("failed to store login state: %w", err)
+	}
+
+	return authURL, nil
+}
+
+// Callback completes a login: it redeems the code, links the external identity to a user
+// and continues like a password login, including the second factor if the user has one
+func (s *SocialLoginService) Callback(ctx context.Context, providerName, code, state string, client models.ClientInfo) (*models.LoginResult, *models.User, error) {
+	provider, ok := s.providers[strings.ToLower(providerName)]
+	if !ok {
+		return nil, nil, ErrUnknownProvider
+	}
+
+	pending, err := s.identityRepo.ConsumeState(state)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up login state: %w", err)
+	}
+	if pending == nil || pending.Provider != provider.Name() || time.Now().After(pending.ExpiresAt) {
+		return nil, nil, ErrInvalidOAuthState
+	}
+
+	identity, err := provider.Exchange(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.resolveUser(identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := beginLogin(s.mfaService, s.sessionService, user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user.Password = ""
+	return result, user, nil
+}
+
+// resolveUser finds the user an external identity belongs to, linking it by verified email
+// or creating a new account on first login
+func (s *SocialLoginService) resolveUser(identity *models.ExternalIdentity) (*models.User, error) {
+	// Returning user: the identity is already linked
+	linked, err := s.identityRepo.GetByProviderSubject(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		user, err := s.userRepo.GetByID(linked.UserID.String())
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrUserNotFound
+		}
+		return user, nil
+	}
+
+	// Only a verified email is trusted to link or create an account
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrProviderEmailNotVerified
+	}
+
+	user, err := s.userRepo.GetByEmail(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		user, err = s.createUserFor(identity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	link := &models.UserIdentity{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.identityRepo.Create(link); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+	log.Printf("Linked %s identity %s to user %s", identity.Provider, identity.Subject, user.ID)
+
+	return user, nil
+}
+
+// createUserFor creates an account for a first-time social login. The password is random,
+// so the account can only be used through the provider until the user resets it.
+func (s *SocialLoginService) createUserFor(identity *models.ExternalIdentity) (*models.User, error) {
+	randomPassword, err := randomURLSafe(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := HashPassword(randomPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	name := identity.Name
+	if name == "" {
+		name = strings.Split(identity.Email, "@")[0]
+	}
+
+	user := &models.User{
+		ID:        uuid.New(),
+		Name:      name,
+		Email:     identity.Email,
+		Password:  hashedPassword,
+		Verified:  true, // The provider already verified the email
+		CreatedAt: time.Now().UTC(),
+		Role:      models.RoleUser,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+// pkceChallenge derives the S256 code challenge of a PKCE code verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomURLSafe returns n random bytes encoded as unpadded base64url
+func randomURLSafe(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
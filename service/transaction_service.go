@@ -2,10 +2,13 @@ package service
 
 import (
 	"backend/models"
+	"backend/pagination"
+	"backend/provenance"
 	"backend/repository"
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -19,11 +22,21 @@ import (
 // TransactionService handles business logic for transactions
 type TransactionService struct {
 	transactionRepo *repository.TransactionRepository
+	amendmentRepo   *repository.AmendmentRepository
+	productRepo     *repository.ProductRepository
+	media           *MediaService
+	uow             *repository.UnitOfWork
 }
 
 // NewTransactionService creates a new instance of TransactionService
-func NewTransactionService(transactionRepo *repository.TransactionRepository) *TransactionService {
-	return &TransactionService{transactionRepo: transactionRepo}
+func NewTransactionService(transactionRepo *repository.TransactionRepository, amendmentRepo *repository.AmendmentRepository, productRepo *repository.ProductRepository, media *MediaService, uow *repository.UnitOfWork) *TransactionService {
+	return &TransactionService{
+		transactionRepo: transactionRepo,
+		amendmentRepo:   amendmentRepo,
+		productRepo:     productRepo,
+		media:           media,
+		uow:             uow,
+	}
 }
 
 func (service *TransactionService) handleTransactionImage(transaction *models.Transaction) error {
@@ -47,102 +60,275 @@ func (service *TransactionService) handleTransactionImage(transaction *models.Tr
 	return nil
 }
 
-// GetByUserID retrieves transactions for a specific user
+// GetByProductID retrieves the transactions of a product, each with the images uploaded with it
 func (s *TransactionService) GetByProductID(itemID uuid.UUID) ([]models.Transaction, error) {
+	transactions, _, err := s.GetWithGallery(itemID)
+	return transactions, err
+}
+
+// GetWithGallery retrieves the transactions of a product together with its gallery
+func (s *TransactionService) GetWithGallery(itemID uuid.UUID) ([]models.Transaction, []models.Media, error) {
 	// Retrieve transactions for the specific item ID
 	transactions, err := s.transactionRepo.GetByProductID(itemID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch transactions: %v", err)
+		return nil, nil, fmt.Errorf("failed to fetch transactions: %v", err)
+	}
+	if err := s.applyAmendments(transactions); err != nil {
+		return nil, nil, err
 	}
 
 	// Handle the image URL for each transaction
 	for i := range transactions {
 		err := s.handleTransactionImage(&transactions[i])
 		if err != nil {
-			return nil, fmt.Errorf("failed to handle image URL for transaction: %v", err)
+			return nil, nil, fmt.Errorf("failed to handle image URL for transaction: %v", err)
 		}
 	}
 
-	return transactions, nil
+	gallery, err := s.media.Gallery(itemID)
+	if err != nil {
+		return nil, nil, err
+	}
+	attachImages(transactions, gallery)
+
+	return transactions, gallery, nil
 }
 
-// AddTransaction adds a transaction to a product
-func (s *TransactionService) AddTransaction(req *models.TransactionRequest) (*models.Transaction, error) {
-	// Log the start of the AddTransaction process
-	log.Printf("Adding transaction for ItemID: %s, UserID: %s", req.ItemID, req.UserID)
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrInvalidFilter       = errors.New("invalid filter")
+	ErrInvalidAmendment    = errors.New("invalid amendment")
+)
 
-	// Create a new Transaction object
-	transaction := models.Transaction{
-		ID:          uuid.New(),       // Generate a new UUID for the transaction
-		ItemID:      req.ItemID,       // Use the ItemID from the request
-		UserID:      req.UserID,       // Use the UserID from the request
-		Description: req.Description,  // Use the Description from the request
-		Action:      req.Action,       // Use the Action from the request (TransactionAction type)
-		CreatedAt:   time.Now().UTC(), // Set CreatedAt to the current UTC time
+// List retrieves a page of the transactions matching the filter, newest first
+func (s *TransactionService) List(filter models.TransactionFilter, params pagination.Params) ([]models.Transaction, pagination.Page, error) {
+	for _, action := range filter.Actions {
+		if !action.IsValid() {
+			return nil, pagination.Page{}, fmt.Errorf("%w: unknown action %q", ErrInvalidFilter, action)
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, pagination.Page{}, fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
 	}
 
-	// Log transaction creation
-	log.Printf("Created new transaction with ID: %s", transaction.ID)
-
-	// Handle the image URL for the transaction (upload image and set pre-signed URL if necessary)
-	err := s.handleTransactionPutImage(&transaction, req)
+	transactions, page, err := s.transactionRepo.List(filter, params)
 	if err != nil {
-		log.Printf("Error handling image for transaction ID %s: %v", transaction.ID, err)
-		return nil, fmt.Errorf("failed to handle image URL: %v", err)
+		return nil, pagination.Page{}, fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	if err := s.applyAmendments(transactions); err != nil {
+		return nil, pagination.Page{}, err
 	}
+	for i := range transactions {
+		if err := s.handleTransactionImage(&transactions[i]); err != nil {
+			return nil, pagination.Page{}, fmt.Errorf("failed to handle image URL for transaction: %v", err)
+		}
+	}
+	return transactions, page, nil
+}
 
-	// Save the transaction to the repository
-	err = s.transactionRepo.Create(&transaction)
+// Get retrieves a transaction with the images uploaded with it
+func (s *TransactionService) Get(id uuid.UUID) (*models.Transaction, error) {
+	transaction, err := s.transactionRepo.GetByID(id)
 	if err != nil {
-		log.Printf("Error saving transaction ID %s to the repository: %v", transaction.ID, err)
-		return nil, fmt.Errorf("failed to save transaction: %v", err)
+		return nil, fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	if transaction == nil {
+		return nil, ErrTransactionNotFound
+	}
+	transactions := []models.Transaction{*transaction}
+	if err := s.applyAmendments(transactions); err != nil {
+		return nil, err
+	}
+	if err := s.handleTransactionImage(&transactions[0]); err != nil {
+		return nil, fmt.Errorf("failed to handle image URL for transaction: %v", err)
 	}
 
-	// Log success
-	log.Printf("Successfully added transaction with ID: %s", transaction.ID)
-
-	// Return the transaction
-	return &transaction, nil
+	gallery, err := s.media.Gallery(transaction.ItemID)
+	if err != nil {
+		return nil, err
+	}
+	attachImages(transactions, gallery)
+	return &transactions[0], nil
 }
 
-// handleTransactionPutImage decodes and uploads an image if present in req.ImageData
-func (s *TransactionService) handleTransactionPutImage(transaction *models.Transaction, req *models.TransactionRequest) error {
-	// Log the image handling process
-	log.Printf("Handling image for transaction ID: %s", transaction.ID)
+// amendableActions are the transactions users post themselves. The entries recorded on their behalf,
+// such as reservations, sales and overrides, cannot be amended.
+var amendableActions = []models.TransactionAction{models.Submitted, models.Revitalized, models.SubmittedRevitalized}
+
+// Amend records a correction of a transaction its author posted. The transaction itself stays as it was
+// posted; the correction is appended to the product's provenance chain as an "amended" entry spelling out
+// the corrected values, and responses show the transaction's values with every amendment applied.
+//
+// A corrected price becomes the product's price only when the product's owner corrects the newest
+// transaction they posted on it while it is for sale. The change is then recorded as an "updated" entry
+// like any other edit; otherwise it only shows on the transaction.
+func (s *TransactionService) Amend(actor Actor, transactionID uuid.UUID, req models.AmendTransaction) (*models.Transaction, error) {
+	transaction, err := s.transactionRepo.GetByID(transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction: %w", err)
+	}
+	if transaction == nil {
+		return nil, ErrTransactionNotFound
+	}
+	if err := Authorize(actor, PermAmendTransaction, transaction.UserID); err != nil {
+		return nil, err
+	}
+	if !containsAction(amendableActions, transaction.Action) {
+		return nil, fmt.Errorf("%w: %q transactions are recorded by the platform and cannot be amended", ErrInvalidAmendment, transaction.Action)
+	}
+	if req.Description == nil && req.ImageData == "" && req.Price == nil {
+		return nil, fmt.Errorf("%w: correct the description, the image or the price", ErrInvalidAmendment)
+	}
+
+	amendment := models.TransactionAmendment{
+		ID:            uuid.New(),
+		TransactionID: transaction.ID,
+		AuthorID:      actor.ID,
+		Reason:        req.Reason,
+		Description:   req.Description,
+		Price:         req.Price,
+		CreatedAt:     time.Now().UTC(),
+	}
 
-	// Check if ImageData contains base64-encoded image data
+	var imageKey string
 	if req.ImageData != "" {
-		// Log that image data is provided
-		log.Printf("Image data found for transaction ID: %s", transaction.ID)
-
-		// Decode the base64-encoded image data
 		imageData, err := base64.StdEncoding.DecodeString(req.ImageData)
 		if err != nil {
-			log.Printf("Error decoding base64 image data for transaction ID %s: %v", transaction.ID, err)
-			return fmt.Errorf("failed to decode image data: %v", err)
+			return nil, fmt.Errorf("%w: image is not valid base64: %v", ErrInvalidMedia, err)
 		}
+		imageURL := amendment.ID.String() + ".jpg"
+		imageKey = "images/" + imageURL
+		if _, err := PutImage(imageKey, imageData); err != nil {
+			return nil, fmt.Errorf("failed to upload image: %w", err)
+		}
+		amendment.ImageURL = &imageURL
+	}
 
-		// Generate a unique key for the image based on the transaction ID
-		imageKey := fmt.Sprintf("%s.jpg", transaction.ID.String())
+	err = s.uow.Do(func(repos *repository.RepositoryFactory) error {
+		entry := provenance.AmendmentEntry(&amendment, transaction.ItemID)
+		if err := repos.GetTransactionRepository().Create(entry); err != nil {
+			return fmt.Errorf("failed to record amendment: %w", err)
+		}
+		amendment.EntryID = &entry.ID
+		if err := repos.GetAmendmentRepository().Create(&amendment); err != nil {
+			return fmt.Errorf("failed to save amendment: %w", err)
+		}
+		if req.Price == nil {
+			return nil
+		}
+		return applyPrice(repos, actor, transaction, *req.Price)
+	})
+	if err != nil {
+		if imageKey != "" {
+			if delErr := DeleteImage(imageKey); delErr != nil {
+				log.Printf("Transactions: failed to clean up image %s: %v", imageKey, delErr)
+			}
+		}
+		return nil, err
+	}
 
-		// Log image key generation
-		log.Printf("Generated image key for transaction ID %s: %s", transaction.ID, imageKey)
+	return s.Get(transaction.ID)
+}
 
-		// Upload the image using the S3 service's PutImage method and get a pre-signed URL
-		imageURL, err := PutImage("images/"+imageKey, imageData)
-		if err != nil {
-			log.Printf("Error uploading image for transaction ID %s: %v", transaction.ID, err)
-			return fmt.Errorf("failed to upload image: %v", err)
-		}
+// applyPrice makes the corrected price of a transaction the product's price, if the actor owns the product,
+// it is for sale and the transaction is the newest one posted on it. It runs in the unit of work that
+// records the amendment.
+func applyPrice(repos *repository.RepositoryFactory, actor Actor, transaction *models.Transaction, price float64) error {
+	product, err := repos.GetProductRepository().GetByID(transaction.ItemID)
+	if err != nil || product == nil {
+		return fmt.Errorf("failed to fetch product: %v", err)
+	}
+	if product.UserID != actor.ID || product.Price == price || !containsStatus(lifecycle[models.Updated].from, product.Status) {
+		return nil
+	}
+	latest, err := repos.GetTransactionRepository().GetLatestByActions(product.ID, amendableActions)
+	if err != nil {
+		return fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	if latest == nil || latest.ID != transaction.ID {
+		return nil
+	}
+
+	moved, err := repos.GetProductRepository().SetStatusIf(product.ID, product.Status, product.Status, map[string]interface{}{"price": price})
+	if err != nil {
+		return err
+	}
+	if !moved {
+		return fmt.Errorf("%w: the product is no longer %q", ErrInvalidTransition, product.Status)
+	}
+	description := fmt.Sprintf("Updated price: %.2f -> %.2f, correcting transaction %s", product.Price, price, transaction.ID)
+	return repos.GetTransactionRepository().Create(newProductEntry(actor, product, models.Updated, description))
+}
 
-		// Set the transaction's ImageURL to the pre-signed URL returned by PutImage
-		transaction.ImageURL = imageKey
-		log.Printf("Successfully uploaded image for transaction ID %s, URL: %s", transaction.ID, imageURL)
+// applyAmendments replaces the values of transactions with their corrected ones and attaches the
+// amendment history. The posted values are kept in Original for every amended transaction.
+func (s *TransactionService) applyAmendments(transactions []models.Transaction) error {
+	ids := make([]uuid.UUID, len(transactions))
+	for i := range transactions {
+		ids[i] = transactions[i].ID
+	}
+	amendments, err := s.amendmentRepo.ListByTransactionIDs(ids)
+	if err != nil {
+		return fmt.Errorf("failed to fetch amendments: %w", err)
 	}
+	if len(amendments) == 0 {
+		return nil
+	}
+
+	byTransaction := make(map[uuid.UUID][]models.TransactionAmendment)
+	for _, amendment := range amendments {
+		byTransaction[amendment.TransactionID] = append(byTransaction[amendment.TransactionID], amendment)
+	}
+
+	for i := range transactions {
+		t := &transactions[i]
+		history, ok := byTransaction[t.ID]
+		if !ok {
+			continue
+		}
+		original := &models.TransactionValues{Description: t.Description, ImageURL: t.ImageURL}
+		// Image keys of the effective values are presigned later with the rest of the transaction
+		for _, amendment := range history {
+			if amendment.Description != nil {
+				t.Description = *amendment.Description
+			}
+			if amendment.ImageURL != nil {
+				t.ImageURL = *amendment.ImageURL
+			}
+			if amendment.Price != nil {
+				t.Price = amendment.Price
+			}
+		}
 
+		if original.ImageURL != "" {
+			if original.ImageURL, err = GetImage("images/" + original.ImageURL); err != nil {
+				return fmt.Errorf("failed to retrieve image URL: %v", err)
+			}
+		}
+		for j := range history {
+			if history[j].ImageURL == nil {
+				continue
+			}
+			url, err := GetImage("images/" + *history[j].ImageURL)
+			if err != nil {
+				return fmt.Errorf("failed to retrieve image URL: %v", err)
+			}
+			history[j].ImageURL = &url
+		}
+		t.Original = original
+		t.Amendments = history
+	}
 	return nil
 }
 
+// transactionUploads lists the images sent with a transaction: the single legacy image first, then the gallery
+func transactionUploads(imageData string, images []models.MediaUpload) []models.MediaUpload {
+	if imageData == "" {
+		return images
+	}
+	return append([]models.MediaUpload{{ImageData: imageData}}, images...)
+}
+
 // FetchContentBasedRecommendations retrieves products based on content filtering (mock implementation)
 func (s *TransactionService) FetchContentBasedRecommendations(imageFilename string) ([]uuid.UUID, error) {
 	err := godotenv.Load()
@@ -2,12 +2,15 @@ package service
 
 import (
 	"backend/models"
+	"backend/pagination"
 	"backend/repository"
+	"backend/token"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -20,24 +23,47 @@ var (
 	ErrEmailNotVerified   = errors.New("email not verified")
 	ErrProductNotFound    = errors.New("product not found")
 	ErrUserNotFound       = errors.New("user not found")
+	ErrRatingNotFound     = errors.New("rating not found")
+	ErrCommentNotFound    = errors.New("comment not found")
 	// Validation errors
 	ErrInvalidInput       = errors.New("invalid input")
 	ErrEmailAlreadyExists = errors.New("email already exists")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrInvalidStatus      = errors.New("invalid product status")
 
 	// Token errors
-	ErrInvalidToken = errors.New("invalid token")
-	ErrTokenExpired = errors.New("token has expired")
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrTokenExpired        = errors.New("token has expired")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+
+	// Authorization errors
+	ErrForbidden = errors.New("forbidden")
 
 	// Internal errors
 	ErrInternal = errors.New("internal server error")
 )
 
 type UserService struct {
-	userRepo *repository.UserRepository
+	userRepo       *repository.UserRepository
+	sessionService *SessionService
+	mfaService     *MFAService
+	lockoutService *LockoutService
+	tokenService   *OneTimeTokenService
+	privacyService *PrivacyService
 }
 
-func NewUserService(userRepo *repository.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+func NewUserService(userRepo *repository.UserRepository, sessionService *SessionService, mfaService *MFAService, lockoutService *LockoutService, tokenService *OneTimeTokenService, privacyService *PrivacyService) *UserService {
+	return &UserService{
+		userRepo:       userRepo,
+		sessionService: sessionService,
+		mfaService:     mfaService,
+		lockoutService: lockoutService,
+		tokenService:   tokenService,
+		privacyService: privacyService,
+	}
 }
 
 // Handle image settings (pre-signed URL generation and image URL updates)
@@ -165,37 +191,99 @@ func (service *UserService) UpdateUser(userID string, req *models.UpdateUser) er
 	return nil
 }
 
-func (service *UserService) Authenticate(email, password string) (string, error) {
+// Authenticate checks the user's password. Users with two-factor authentication get an "mfa" token
+// to complete the login with CompleteMFALogin; everyone else gets a session right away.
+// Failed attempts are throttled per account and per IP.
+func (service *UserService) Authenticate(email, password string, client models.ClientInfo) (*models.LoginResult, error) {
+	if err := service.lockoutService.CheckLogin(models.AttemptLogin, email, client); err != nil {
+		return nil, err
+	}
+
 	// Retrieve user by email
 	user, err := service.userRepo.GetByEmail(email)
-	if err != nil || user == nil {
-		return "", ErrInvalidCredentials
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+	if user == nil || user.DeletedAt != nil {
+		// Spend the same time as a real password check so response times don't reveal unknown emails
+		CheckPasswordHash(password, dummyPasswordHash())
+		service.lockoutService.LoginFailed(models.AttemptLogin, email, nil, client, "unknown_email")
+		return nil, ErrInvalidCredentials
 	}
 
 	// Validate the password
 	if !CheckPasswordHash(password, user.Password) {
-		return "", ErrInvalidCredentials
+		service.lockoutService.LoginFailed(models.AttemptLogin, email, user, client, "invalid_password")
+		return nil, ErrInvalidCredentials
+	}
+
+	result, err := beginLogin(service.mfaService, service.sessionService, user, client)
+	if err != nil {
+		return nil, err
+	}
+	// With 2FA the failures are only cleared once the second factor is accepted too
+	if !result.MFARequired {
+		service.lockoutService.LoginSucceeded(models.AttemptLogin, user, client)
+	}
+	return result, nil
+}
+
+// CompleteMFALogin is the second login step: it checks the "mfa" token from Authenticate and the user's
+// TOTP or recovery code, then opens a session
+func (service *UserService) CompleteMFALogin(mfaToken, code, recoveryCode string, client models.ClientInfo) (*models.AuthTokens, *models.User, error) {
+	userID, err := service.ValidateToken(mfaToken, "mfa")
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	user, err := service.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, ErrUserNotFound
 	}
 
-	// Generate JWT token for authentication
-	token, err := GenerateJWT(user.ID.String(), "auth", 3*time.Hour)
+	// Wrong codes count against the same account lockout as wrong passwords
+	if err := service.lockoutService.CheckLogin(models.AttemptMFA, user.Email, client); err != nil {
+		return nil, nil, err
+	}
+	if err := service.mfaService.Verify(userID, code, recoveryCode); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			service.lockoutService.LoginFailed(models.AttemptMFA, user.Email, user, client, "invalid_code")
+		}
+		return nil, nil, err
+	}
+
+	// Open a new session and mint its access/refresh token pair
+	tokens, err := service.sessionService.Issue(user, client)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate JWT: %w", err)
+		return nil, nil, err
 	}
+	service.lockoutService.LoginSucceeded(models.AttemptMFA, user, client)
 
-	return token, nil
+	user.Password = ""
+	return tokens, user, nil
 }
 
-func (service *UserService) GetDemographicInformation(id string) (*models.User, error) {
+// GetDemographicInformation retrieves the public profile of a user as the viewer may see it (nil for visitors
+// who are not logged in). Profiles hidden from the viewer come back reduced to their ID. Closed accounts
+// have no profile.
+func (service *UserService) GetDemographicInformation(id string, viewer *Actor) (*models.User, error) {
 	// Fetch user by ID from the repository
 	user, err := service.userRepo.GetByID(id)
-	if err != nil {
+	if err != nil || user == nil || user.DeletedAt != nil {
 		return nil, ErrUserNotFound
 	}
 
-	// Obfuscate sensitive information
-	user.Password = ""                      // Clear password
-	user.Email = ObfuscateEmail(user.Email) // Optional email obfuscation
+	// Hide what the user's privacy settings don't share with the viewer
+	privacy, err := service.privacyService.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !applyPrivacy(viewer, user, privacy) {
+		return user, nil
+	}
 
 	// Handle image settings (generate pre-signed URL if image exists)
 	if err := service.handleImage(user); err != nil {
@@ -206,40 +294,87 @@ func (service *UserService) GetDemographicInformation(id string) (*models.User,
 	return user, nil
 }
 
-func (service *UserService) UpdateEmail(userID, newEmail string) error {
-	return service.userRepo.UpdateEmail(userID, newEmail)
+// GetProfiles retrieves the public profiles of several users at once, as GetDemographicInformation does
+// for one, keyed by user ID. Unknown users and closed accounts are left out.
+func (service *UserService) GetProfiles(ids []uuid.UUID, viewer *Actor) (map[uuid.UUID]models.User, error) {
+	found, err := service.userRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	settings, err := service.privacyService.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make(map[uuid.UUID]models.User, len(found))
+	for i := range found {
+		user := &found[i]
+		if user.DeletedAt != nil {
+			continue
+		}
+		if applyPrivacy(viewer, user, settings[user.ID]) {
+			if err := service.handleImage(user); err != nil {
+				return nil, fmt.Errorf("failed to handle image for user %s: %v", user.ID, err)
+			}
+		}
+		profiles[user.ID] = *user
+	}
+	return profiles, nil
 }
 
-func (service *UserService) SendPasswordResetEmail(email string) error {
+// SendPasswordResetEmail emails a reset link. It succeeds silently for unknown emails so the
+// response doesn't reveal whether an account exists.
+func (service *UserService) SendPasswordResetEmail(email string, client models.ClientInfo) error {
+	if err := service.lockoutService.ThrottleEmail(models.AttemptPasswordReset, email, client); err != nil {
+		return err
+	}
+
 	// Check if the user exists
 	user, err := service.userRepo.GetByEmail(email)
 	if err != nil {
-		return errors.New("user not found")
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+	if user == nil {
+		return nil
 	}
 
 	// Generate a password reset token
-	resetToken, err := GeneratePasswordResetToken(user.ID.String())
+	resetToken, err := GeneratePasswordResetToken(service.tokenService, user.ID)
 	if err != nil {
 		return errors.New("failed to generate password reset token")
 	}
 
 	// Create the reset link
-	resetLink := fmt.Sprintf("https://%s/verify-email?token=%s", os.Getenv("FE_PORT"), resetToken)
+	resetLink := fmt.Sprintf("https://%s/reset-password?token=%s", os.Getenv("FE_PORT"), resetToken)
 
-	SendResetEmail(email, resetLink)
+	// Send in the background so the response time is the same whether or not the account exists
+	go func() {
+		if err := SendResetEmail(email, resetLink); err != nil {
+			log.Printf("Failed to send password reset email: %v", err)
+		}
+	}()
 
 	return nil
 }
 
-func (service *UserService) SendEmailVerification(email string) error {
+// SendEmailVerification emails a verification link. Like SendPasswordResetEmail it succeeds silently
+// for unknown emails.
+func (service *UserService) SendEmailVerification(email string, client models.ClientInfo) error {
+	if err := service.lockoutService.ThrottleEmail(models.AttemptEmailVerification, email, client); err != nil {
+		return err
+	}
+
 	// Check if the user exists
 	user, err := service.userRepo.GetByEmail(email)
 	if err != nil {
-		return errors.New("user not found")
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+	if user == nil {
+		return nil
 	}
 
 	// Generate verification token
-	verificationToken, err := GenerateEmailVerificationToken(user.ID.String())
+	verificationToken, err := GenerateEmailVerificationToken(service.tokenService, user.ID)
 	if err != nil {
 		return errors.New("failed to generate verification token")
 	}
@@ -247,69 +382,75 @@ func (service *UserService) SendEmailVerification(email string) error {
 	// Create verification link
 	verificationLink := fmt.Sprintf("https://%s/verify-email?token=%s", os.Getenv("FE_PORT"), verificationToken)
 
-	SendVerifyEmail(email, verificationLink)
+	go func() {
+		if err := SendVerifyEmail(email, verificationLink); err != nil {
+			log.Printf("Failed to send verification email: %v", err)
+		}
+	}()
 
 	return nil
 }
 
+// UpdatePassword sets a new password. Outstanding reset links stop working once the password changed.
 func (service *UserService) UpdatePassword(userID, newPassword string) error {
 	hashedPassword, err := HashPassword(newPassword)
 	if err != nil {
 		return fmt.Errorf("failed to hash new password: %w", err)
 	}
-	return service.userRepo.UpdatePassword(userID, hashedPassword)
-}
-
-// ValidateToken checks if the reset token is a valid JWT and extracts the user ID
-func (service *UserService) ValidateToken(token string, expectedPurpose string) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET") // Fetch secret from environment variable
+	if err := service.userRepo.UpdatePassword(userID, hashedPassword); err != nil {
+		return err
+	}
 
-	// Parse the token
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(jwtSecret), nil
-	})
+	if err := service.tokenService.RevokeAll(userID, models.TokenPurposePasswordReset); err != nil {
+		return fmt.Errorf("failed to revoke reset tokens: %w", err)
+	}
+	return nil
+}
 
+// ResetPassword redeems a single-use reset token and sets the new password. All sessions are
+// revoked, since whoever had the old password may still be logged in.
+func (service *UserService) ResetPassword(token, newPassword string) error {
+	userID, err := service.tokenService.Redeem(token, models.TokenPurposePasswordReset)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse token: %w", err)
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
-	if !parsedToken.Valid {
-		return "", ErrInvalidToken
+	if err := service.UpdatePassword(userID, newPassword); err != nil {
+		return err
 	}
 
-	// Extract claims
-	if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok {
-		// Check purpose
-		if purpose, ok := claims["purpose"].(string); !ok || purpose != expectedPurpose {
-			return "", errors.New("token purpose does not match expected purpose")
-		}
+	return service.sessionService.RevokeAll(userID)
+}
 
-		// Check if the token is expired
-		if exp, ok := claims["exp"].(float64); ok {
-			expirationTime := time.Unix(int64(exp), 0) // Convert expiration to time.Time
-			if time.Now().After(expirationTime) {
-				return "", ErrTokenExpired // Return error if token is expired
-			}
-		} else {
-			return "", errors.New("expiration time not found in token claims")
-		}
+// ValidateToken checks if the reset token is a valid JWT and extracts the user ID
+func (service *UserService) ValidateToken(token string, expectedPurpose string) (string, error) {
+	claims, err := parseToken(token, expectedPurpose)
+	if err != nil {
+		return "", err
+	}
 
-		// Extract user ID
-		if userID, ok := claims["user_id"].(string); ok {
-			return userID, nil
-		}
-		return "", errors.New("user ID not found in token claims")
+	// Extract user ID
+	if userID, ok := claims["user_id"].(string); ok {
+		return userID, nil
 	}
+	return "", errors.New("user ID not found in token claims")
+}
 
-	return "", errors.New("invalid token claims")
+// parseToken verifies a JWT's signature, purpose and expiry and returns its claims
+func parseToken(tokenString string, expectedPurpose string) (jwt.MapClaims, error) {
+	claims, err := token.Parse(tokenString, expectedPurpose)
+	if err != nil {
+		if errors.Is(err, token.ErrExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	return claims, nil
 }
 
 func (service *UserService) VerifyEmail(token string) error {
 	// Validate the token and extract user ID
-	userID, err := service.ValidateToken(token, "email_verification")
+	userID, err := service.tokenService.Redeem(token, models.TokenPurposeEmailVerification)
 	if err != nil {
 		return errors.New("invalid or expired token")
 	}
@@ -322,36 +463,65 @@ func (service *UserService) VerifyEmail(token string) error {
 	return nil
 }
 
-// GetUsersByNamePrefix retrieves users whose names start with the given prefix (up to 10 users)
-func (s *UserService) GetUsersByNamePrefix(name string) ([]models.User, error) {
-	users, err := s.userRepo.FindByNamePrefix(name)
+// GetUsersByNamePrefix retrieves a page of the users whose names start with the given prefix.
+// Closed accounts and profiles hidden from the viewer are left out, so a page can hold fewer users
+// than the limit while more follow.
+func (s *UserService) GetUsersByNamePrefix(name string, viewer *Actor, params pagination.Params) ([]models.User, pagination.Page, error) {
+	found, page, err := s.userRepo.FindByNamePrefix(name, params)
 	if err != nil {
-		return nil, err
+		return nil, page, err
 	}
 
-	// Set the password to an empty string for each user
-	for i := range users {
-		users[i].Password = ""
+	ids := make([]uuid.UUID, len(found))
+	for i := range found {
+		ids[i] = found[i].ID
+	}
+	settings, err := s.privacyService.GetMany(ids)
+	if err != nil {
+		return nil, page, err
+	}
+
+	users := make([]models.User, 0, len(found))
+	for i := range found {
+		if found[i].DeletedAt != nil {
+			continue
+		}
+		if applyPrivacy(viewer, &found[i], settings[found[i].ID]) {
+			users = append(users, found[i])
+		}
 	}
 
 	// Handle image settings (generate pre-signed URL if image exists)
 	for i := range users {
 		if err := s.handleImage(&users[i]); err != nil {
-			return nil, fmt.Errorf("failed to handle image for user %s: %v", users[i].ID, err)
+			return nil, page, fmt.Errorf("failed to handle image for user %s: %v", users[i].ID, err)
 		}
 	}
 
-	return users, nil
+	return users, page, nil
 }
 
-func (s *UserService) GetByEmail(email string) (*models.User, error) {
+// GetByEmail looks up a profile by email address. Users who hide their email or whose profile is hidden
+// from the viewer can't be found this way, so the lookup doesn't confirm the address is registered.
+func (s *UserService) GetByEmail(email string, viewer *Actor) (*models.User, error) {
 	user, err := s.userRepo.GetByEmail(email)
 	if err != nil {
 		return nil, err
 	}
+	if user == nil || user.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
 
-	// Set the password to an empty string
-	user.Password = ""
+	privacy, err := s.privacyService.Get(user.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	if privacy.HideEmail && !isSelfOrStaff(viewer, user.ID) {
+		return nil, ErrUserNotFound
+	}
+	if !applyPrivacy(viewer, user, privacy) {
+		return nil, ErrUserNotFound
+	}
 
 	// Handle image settings (generate pre-signed URL if image exists)
 	if err := s.handleImage(user); err != nil {
@@ -360,6 +530,24 @@ func (s *UserService) GetByEmail(email string) (*models.User, error) {
 
 	return user, nil
 }
+
+// GetOwnProfile retrieves the full profile of the logged-in user
+func (s *UserService) GetOwnProfile(userID string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	user.Password = ""
+
+	if err := s.handleImage(user); err != nil {
+		return nil, fmt.Errorf("failed to handle image for user %s: %v", user.ID, err)
+	}
+	return user, nil
+}
+
 func (s *UserService) AddPremiumDays(userID string, days int) (*models.User, error) {
 	if days <= 0 {
 		return nil, errors.New("days must be a positive integer")
@@ -373,3 +561,69 @@ func (s *UserService) AddPremiumDays(userID string, days int) (*models.User, err
 
 	return updatedUser, nil
 }
+
+// ListUsers retrieves a page of users for the admin panel
+func (s *UserService) ListUsers(search string, params pagination.Params) ([]models.User, pagination.Page, error) {
+	users, page, err := s.userRepo.List(search, params)
+	if err != nil {
+		return nil, page, err
+	}
+
+	// Set the password to an empty string for each user
+	for i := range users {
+		users[i].Password = ""
+	}
+
+	return users, page, nil
+}
+
+// GetByIDForAdmin retrieves a user with their full email address, for the admin panel
+func (s *UserService) GetByIDForAdmin(id string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// UpdateRole changes the role of a user. Their sessions are revoked so tokens carrying the old role stop working.
+func (s *UserService) UpdateRole(userID string, role models.Role) error {
+	if !role.IsValid() {
+		return ErrInvalidRole
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.userRepo.UpdateRole(userID, role); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	return s.sessionService.RevokeAll(userID)
+}
+
+// PromoteBootstrapAdmins gives the admin role to the emails listed in ADMIN_EMAILS,
+// so a fresh deployment has an admin without editing the database by hand
+func (s *UserService) PromoteBootstrapAdmins() error {
+	var emails []string
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		return nil
+	}
+
+	return s.userRepo.PromoteByEmails(emails, models.RoleAdmin)
+}
@@ -1,8 +1,12 @@
 package service
 
 import (
+	"backend/models"
+	"backend/token"
 	"bytes"
 	"fmt"
+	"html"
+	"io"
 	"mime"
 	"net/smtp"
 	"os"
@@ -15,6 +19,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -48,18 +53,12 @@ func CheckPasswordHash(password, hash string) bool {
 
 // GenerateJWT generates a JWT token for the user with purpose and expiration
 func GenerateJWT(userID, purpose string, expiresIn time.Duration) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET") // Fetch secret from environment variable
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(expiresIn).Unix(), // Token valid for specified duration
-		"purpose": purpose,
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(jwtSecret)) // Use the secret from environment
-	if err != nil {
-		return "", err
-	}
-	return signedToken, nil
+	return GenerateJWTWithClaims(userID, purpose, expiresIn, nil)
+}
+
+// GenerateJWTWithClaims generates a JWT token like GenerateJWT, adding the given extra claims
+func GenerateJWTWithClaims(userID, purpose string, expiresIn time.Duration, extra jwt.MapClaims) (string, error) {
+	return token.Sign(userID, purpose, expiresIn, extra)
 }
 
 // ObfuscateEmail masks part of the email for privacy
@@ -68,14 +67,17 @@ func ObfuscateEmail(email string) string {
 	if len(parts) != 2 {
 		return email // Invalid email format
 	}
-	// Replace part of the email with asterisks
-	obfuscated := parts[0][:1] + strings.Repeat("*", len(parts[0])-2) + parts[0][len(parts[0])-1:] + "@" + parts[1]
-	return obfuscated
+	// Keep the first and last character of the local part; shorter ones are masked entirely
+	local := parts[0]
+	if len(local) <= 2 {
+		return strings.Repeat("*", len(local)) + "@" + parts[1]
+	}
+	return local[:1] + strings.Repeat("*", len(local)-2) + local[len(local)-1:] + "@" + parts[1]
 }
 
-// GenerateEmailVerificationToken generates a JWT token for email verification
-func GenerateEmailVerificationToken(userID string) (string, error) {
-	return GenerateJWT(userID, "email_verification", time.Hour*24) // Token valid for 24 hours
+// GenerateEmailVerificationToken generates a single-use JWT token for email verification
+func GenerateEmailVerificationToken(tokens *OneTimeTokenService, userID uuid.UUID) (string, error) {
+	return tokens.Issue(userID, models.TokenPurposeEmailVerification, time.Hour*24) // Token valid for 24 hours
 }
 
 // LoadEmailConfig loads email configuration from environment variables
@@ -170,9 +172,59 @@ func SendResetEmail(email, resetLink string) error {
 	return SendEmail(email, subject, htmlBody)
 }
 
-// GeneratePasswordResetToken generates a JWT token for password reset
-func GeneratePasswordResetToken(userID string) (string, error) {
-	return GenerateJWT(userID, "password_reset", time.Hour) // Token valid for 1 hour
+func SendEmailChangeConfirmation(email, confirmLink string) error {
+	subject := "Confirm Your New Email Address"
+	htmlBody := fmt.Sprintf(`
+        <html>
+        <body style="font-family: Arial, sans-serif; background-color: #f4f4f4; padding: 20px;">
+            <div style="max-width: 600px; margin: auto; background-color: #ffffff; padding: 20px; border-radius: 10px; box-shadow: 0 4px 8px rgba(0, 0, 0, 0.1);">
+                <h2 style="text-align: center; color: #2c3e50;">Confirm Your New Email</h2>
+                <p style="color: #555; line-height: 1.6;">
+                    We received a request to use this address for your Renova account. Click the button below to confirm it:
+                </p>
+                <div style="text-align: center; margin: 30px 0;">
+                    <a href="%s" style="display: inline-block; padding: 12px 24px; background-color: #4CAF50; color: #ffffff; text-decoration: none; border-radius: 5px; font-weight: bold;">Confirm Email</a>
+                </div>
+                <p style="color: #555; line-height: 1.6;">
+                    If you did not request this change, you can ignore this email.
+                </p>
+                <hr style="border: none; border-top: 1px solid #ddd; margin: 20px 0;">
+                <p style="text-align: center; color: #aaa; font-size: 12px;">&copy; 2024 Renova, Inc. All rights reserved.</p>
+            </div>
+        </body>
+        </html>`, confirmLink)
+
+	return SendEmail(email, subject, htmlBody)
+}
+
+func SendEmailChangeAlert(email, newEmail, cancelLink string) error {
+	subject := "Your Email Address Is Being Changed"
+	htmlBody := fmt.Sprintf(`
+        <html>
+        <body style="font-family: Arial, sans-serif; background-color: #f4f4f4; padding: 20px;">
+            <div style="max-width: 600px; margin: auto; background-color: #ffffff; padding: 20px; border-radius: 10px; box-shadow: 0 4px 8px rgba(0, 0, 0, 0.1);">
+                <h2 style="text-align: center; color: #e74c3c;">Email Change Requested</h2>
+                <p style="color: #555; line-height: 1.6;">
+                    Someone asked to change the email address of your account to <strong>%s</strong>. The change only takes effect once the new address is confirmed.
+                </p>
+                <p style="color: #555; line-height: 1.6;">
+                    If this wasn't you, cancel the change now. This also logs out every device signed in to your account:
+                </p>
+                <div style="text-align: center; margin: 30px 0;">
+                    <a href="%s" style="display: inline-block; padding: 12px 24px; background-color: #e74c3c; color: #ffffff; text-decoration: none; border-radius: 5px; font-weight: bold;">Cancel Change</a>
+                </div>
+                <hr style="border: none; border-top: 1px solid #ddd; margin: 20px 0;">
+                <p style="text-align: center; color: #aaa; font-size: 12px;">&copy; 2024 Renova, Inc. All rights reserved.</p>
+            </div>
+        </body>
+        </html>`, html.EscapeString(newEmail), cancelLink)
+
+	return SendEmail(email, subject, htmlBody)
+}
+
+// GeneratePasswordResetToken generates a single-use JWT token for password reset
+func GeneratePasswordResetToken(tokens *OneTimeTokenService, userID uuid.UUID) (string, error) {
+	return tokens.Issue(userID, models.TokenPurposePasswordReset, time.Hour) // Token valid for 1 hour
 }
 func GetImage(imageKey string) (string, error) {
 	// Fetch AWS credentials and S3 bucket name from environment variables
@@ -267,3 +319,59 @@ func PutImage(imageKey string, imageData []byte) (string, error) {
 
 	return urlStr, nil
 }
+
+// newS3Client creates an S3 client from the environment and returns it with the bucket name
+func newS3Client() (*s3.S3, string, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	region := os.Getenv("AWS_REGION")
+	bucket := os.Getenv("S3_BUCKET_NAME")
+
+	if accessKey == "" || secretKey == "" || region == "" || bucket == "" {
+		return nil, "", fmt.Errorf("missing AWS credentials or configuration")
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials(accessKey, secretKey, ""),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create AWS session: %v", err)
+	}
+	return s3.New(sess), bucket, nil
+}
+
+// GetImageData downloads an image from S3
+func GetImageData(imageKey string) ([]byte, error) {
+	s3Client, bucket, err := newS3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(imageKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image from S3: %v", err)
+	}
+	defer out.Body.Close()
+
+	return io.ReadAll(out.Body)
+}
+
+// DeleteImage removes an image from S3. Deleting a key that does not exist is not an error.
+func DeleteImage(imageKey string) error {
+	s3Client, bucket, err := newS3Client()
+	if err != nil {
+		return err
+	}
+
+	if _, err := s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(imageKey),
+	}); err != nil {
+		return fmt.Errorf("failed to delete image from S3: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package token
+
+import (
+	"crypto/ed25519"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// SigningMethodEdDSA signs tokens with Ed25519 (RFC 8037). jwt-go v3 has no EdDSA support of its own.
+type SigningMethodEdDSA struct{}
+
+// EdDSA is the Ed25519 signing method, registered under the "EdDSA" alg
+var EdDSA = &SigningMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(EdDSA.Alg(), func() jwt.SigningMethod {
+		return EdDSA
+	})
+}
+
+func (m *SigningMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+// Verify checks the signature with an ed25519.PublicKey
+func (m *SigningMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// Sign signs with an ed25519.PrivateKey
+func (m *SigningMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
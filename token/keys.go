@@ -0,0 +1,206 @@
+package token
+
+import (
+	"backend/appenv"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Key is one signing key. Keys without a private half can only verify, which is how retired
+// keys are kept around until the tokens they signed have expired.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// KeySet holds every key tokens are accepted from and the one new tokens are signed with
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// NewKeySet creates a key set that signs with the key with ID activeKID
+func NewKeySet(keys []*Key, activeKID string) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		if _, exists := set.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+
+	active, ok := set.keys[activeKID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeKID)
+	}
+	if active.PrivateKey == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeKID)
+	}
+	set.active = active
+	return set, nil
+}
+
+// Lookup returns the key with the given ID
+func (s *KeySet) Lookup(kid string) (*Key, bool) {
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// Active returns the key new tokens are signed with
+func (s *KeySet) Active() *Key {
+	return s.active
+}
+
+// Keys returns all keys sorted by ID
+func (s *KeySet) Keys() []*Key {
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// LoadKeySetFromEnv loads the keys from the PEM files in JWT_KEYS_DIR. The file name without
+// extension is the key ID. Private keys (PKCS#8 RSA or Ed25519, or PKCS#1 RSA) can sign; public
+// keys only verify. JWT_ACTIVE_KID picks the signing key, defaulting to the last private key by
+// name, so date-named files like "2024-06.pem" rotate by simply adding a newer file.
+//
+// Without JWT_KEYS_DIR loading fails, unless APP_ENV is "development" or "test": then an ephemeral
+// Ed25519 key is generated. Its tokens stop working on restart and are not shared between instances.
+func LoadKeySetFromEnv() (*KeySet, error) {
+	return loadKeySetFromEnv("JWT_KEYS_DIR", "JWT_ACTIVE_KID", "ephemeral")
+}
+
+// LoadProvenanceKeySetFromEnv loads the keys provenance certificates are signed with from the PEM files in
+// PROVENANCE_KEYS_DIR, with PROVENANCE_ACTIVE_KID picking the signing key, the same way as
+// LoadKeySetFromEnv. They are kept apart from the token keys because certificates are valid for years:
+// a key that signed certificates must stay in the directory, at least as a public key, for as long as
+// the certificates it signed are valid, which is far longer than token keys need to be kept.
+func LoadProvenanceKeySetFromEnv() (*KeySet, error) {
+	return loadKeySetFromEnv("PROVENANCE_KEYS_DIR", "PROVENANCE_ACTIVE_KID", "provenance-ephemeral")
+}
+
+// loadKeySetFromEnv loads the key set from the directory named in dirVar, signing with the key named in
+// activeVar. In development and tests an ephemeral key named with ephemeralPrefix stands in for the directory.
+func loadKeySetFromEnv(dirVar, activeVar, ephemeralPrefix string) (*KeySet, error) {
+	dir := os.Getenv(dirVar)
+	if dir == "" {
+		if !appenv.IsDevelopment() {
+			return nil, fmt.Errorf("%s is not set; an ephemeral signing key is only allowed with APP_ENV=development or APP_ENV=test", dirVar)
+		}
+		log.Println(dirVar + " is not set, signing with an ephemeral key (APP_ENV=" + os.Getenv("APP_ENV") + ")")
+		key, err := GenerateEd25519Key(fmt.Sprintf("%s-%d", ephemeralPrefix, time.Now().Unix()))
+		if err != nil {
+			return nil, err
+		}
+		return NewKeySet([]*Key{key}, key.ID)
+	}
+
+	keys, err := loadKeyDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	activeKID := os.Getenv(activeVar)
+	if activeKID == "" {
+		for _, key := range keys {
+			if key.PrivateKey != nil && key.ID > activeKID {
+				activeKID = key.ID
+			}
+		}
+	}
+	if activeKID == "" {
+		return nil, fmt.Errorf("no private key found in %s", dir)
+	}
+
+	return NewKeySet(keys, activeKID)
+}
+
+// GenerateEd25519Key creates a new random Ed25519 key
+func GenerateEd25519Key(kid string) (*Key, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return &Key{ID: kid, Method: EdDSA, PrivateKey: privateKey, PublicKey: publicKey}, nil
+}
+
+// loadKeyDir parses every .pem file in dir
+func loadKeyDir(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .pem files found in %s", dir)
+	}
+
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parseKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parseKey parses a PEM encoded RSA or Ed25519 key
+func parseKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, PrivateKey: k, PublicKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, PublicKey: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: kid, Method: EdDSA, PrivateKey: k, PublicKey: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Method: EdDSA, PublicKey: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only RSA and Ed25519 keys are supported", parsed)
+	}
+}
@@ -0,0 +1,126 @@
+// Package token signs and verifies the JWTs issued by the API. Tokens are signed with RS256 or
+// EdDSA and carry the ID of their key in the "kid" header, so keys can be rotated and other
+// services can verify tokens with the public keys from /.well-known/jwks.json.
+package token
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrExpired        = errors.New("token has expired")
+	ErrUnknownKey     = errors.New("token signed with an unknown key")
+	ErrPurposeInvalid = errors.New("token purpose does not match expected purpose")
+)
+
+var (
+	defaultMu  sync.RWMutex
+	defaultSet *KeySet
+)
+
+// Default returns the key set loaded from the environment. It panics when the keys are misconfigured,
+// which happens at startup since the routes set up the auth middleware right away.
+func Default() *KeySet {
+	defaultMu.RLock()
+	set := defaultSet
+	defaultMu.RUnlock()
+	if set != nil {
+		return set
+	}
+
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultSet == nil {
+		set, err := LoadKeySetFromEnv()
+		if err != nil {
+			panic(fmt.Sprintf("failed to load JWT signing keys: %v", err))
+		}
+		defaultSet = set
+	}
+	return defaultSet
+}
+
+// SetDefault replaces the key set used by Sign and Parse, e.g. with fixed keys in tests
+func SetDefault(set *KeySet) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultSet = set
+}
+
+// Sign issues a token for the user with the given purpose and lifetime, signed with the active key
+func Sign(userID, purpose string, expiresIn time.Duration, extra jwt.MapClaims) (string, error) {
+	return Default().Sign(userID, purpose, expiresIn, extra)
+}
+
+// Parse verifies a token's signature, expiry and purpose and returns its claims
+func Parse(tokenString, expectedPurpose string) (jwt.MapClaims, error) {
+	return Default().Parse(tokenString, expectedPurpose)
+}
+
+// Sign issues a token for the user with the given purpose and lifetime, signed with the active key
+func (s *KeySet) Sign(userID, purpose string, expiresIn time.Duration, extra jwt.MapClaims) (string, error) {
+	claims := jwt.MapClaims{}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	now := time.Now()
+	claims["user_id"] = userID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(expiresIn).Unix() // Token valid for specified duration
+	claims["purpose"] = purpose
+
+	key := s.Active()
+	signed := jwt.NewWithClaims(key.Method, claims)
+	signed.Header["kid"] = key.ID
+	return signed.SignedString(key.PrivateKey)
+}
+
+// Parse verifies a token's signature, expiry and purpose and returns its claims
+func (s *KeySet) Parse(tokenString, expectedPurpose string) (jwt.MapClaims, error) {
+	parsed, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := s.Lookup(kid)
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		// The alg header must match the key, otherwise a public key could be abused as an HMAC secret
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		return key.PublicKey, nil
+	})
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrExpired
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	// Check purpose
+	if purpose, ok := claims["purpose"].(string); !ok || purpose != expectedPurpose {
+		return nil, ErrPurposeInvalid
+	}
+
+	// jwt-go only checks exp when it is present; every token we issue has one
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: expiration time not found in token claims", ErrInvalidToken)
+	}
+	if time.Now().After(time.Unix(int64(exp), 0)) {
+		return nil, ErrExpired
+	}
+
+	return claims, nil
+}